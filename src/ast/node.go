@@ -4,6 +4,8 @@ type NodeKind int
 
 const (
 	INTEGER NodeKind = iota
+	STRING // 文字列
+	FLOAT // 小数
 
 	IDENT // 識別子
 
@@ -12,6 +14,10 @@ const (
 	MUL // 掛け算
 	DIV // 割り算
 	ASSIGN // 代入演算子
+	ADD_ASSIGN // 加算代入
+	SUB_ASSIGN // 減算代入
+	MUL_ASSIGN // 乗算代入
+	DIV_ASSIGN // 除算代入
 
 	GT // 超過
 	GE // 以上
@@ -23,10 +29,22 @@ const (
 	ELSE // それ以外
 	THEN // ならば
 	FOR // 繰り返す
+	TERNARY // 条件式(なら〜でなければ)
 
 	FUNC // 関数
 	CALL // 関数呼び出し
 	BLOCK
+
+	ARRAY // 配列
+	HASH // ハッシュ
+	PAIR // ハッシュのキーと値の組
+	INDEX // 添字アクセス
+	REST // 残りの引数(…残り)
+	TUPLE // 「、」で区切られた複数の値
+	PARTICLE // 助詞の付いた引数(x に、1 を)
+	MEMBER // モジュールの要素(モジュール.名前)
+	IMPORT // 読み込む
+	NEG // 符号反転。最適化で「0 - x」を置き換える
 )
 
 type Node struct {
@@ -46,7 +64,27 @@ type Node struct {
 	Body *Node
 
 	Num int // INTEGERの時に値を格納する
+	Float float64 // FLOATの時に値を格納する
+	Str string // STRINGの時の値と、読み込むモジュール名を格納する
 	Ident string // 識別子を格納する
+	Particle string // 仮引数を受け取る助詞を格納する
+
+	InFunction bool // 関数の本体の中の「戻す」なら真。戻す値の呼び出しを末尾呼び出しにできる
+
+	Binding *Binding // 解決した変数の位置。nil なら名前で探す
+	Locals int // ブロックと関数の本体で、位置で参照する変数の数
+	Names []string // 位置で参照する変数の名前。位置の順に並べる
+
+	Line int // ソースコード上の位置。1から数え、0なら分からない
+	Column int
+	StartLine int // 文の並びに置いた文だけ、文の始めの字句の位置を持つ。関数なら「関数」の位置
+	StartColumn int
+}
+
+// 変数の参照を解決した結果
+type Binding struct {
+	Depth int // 何段外側の環境か
+	Slot  int // 環境の中の位置
 }
 
 func NewNode(nodeKind NodeKind) *Node {
@@ -60,10 +98,26 @@ func NewIntegerNode(num int) *Node {
 	return n
 }
 
+// 二項演算の位置は左辺の位置にする
 func NewNodeBinop(nodeKind NodeKind, lhs *Node, rhs *Node) *Node {
 	n := NewNode(nodeKind)
 	n.Lhs = lhs
 	n.Rhs = rhs
+	if lhs != nil {
+		n.Line, n.Column = lhs.Line, lhs.Column
+	}
+	return n
+}
+
+func NewFloatNode(num float64) *Node {
+	n := NewNode(FLOAT)
+	n.Float = num
+	return n
+}
+
+func NewStringNode(str string) *Node {
+	n := NewNode(STRING)
+	n.Str = str
 	return n
 }
 
@@ -0,0 +1,130 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+var operators = map[NodeKind]string{
+	ADD:        "+",
+	SUB:        "-",
+	MUL:        "*",
+	DIV:        "/",
+	ASSIGN:     "=",
+	ADD_ASSIGN: "+=",
+	SUB_ASSIGN: "-=",
+	MUL_ASSIGN: "*=",
+	DIV_ASSIGN: "/=",
+	GT:         "<",
+	GE:         "<=",
+	EQ:         "==",
+	NOT_EQ:     "!=",
+}
+
+func joinNodes(nodes []*Node) string {
+	strs := []string{}
+	for _, v := range nodes {
+		strs = append(strs, v.String())
+	}
+	return strings.Join(strs, ", ")
+}
+
+// 二項演算の項が二項演算であれば括弧で囲む
+func operandString(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	if _, ok := operators[n.NodeKind]; ok {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+func calleeString(n *Node) string {
+	if n.Lhs != nil {
+		return operandString(n.Lhs) + "." + n.Ident
+	}
+	return n.Ident
+}
+
+// ノードをソースコードに近い形の文字列にする。
+// 関数の形式をエラーメッセージなどで表示するために使う。
+func (n *Node) String() string {
+	if n == nil {
+		return ""
+	}
+
+	if op, ok := operators[n.NodeKind]; ok {
+		return operandString(n.Lhs) + " " + op + " " + operandString(n.Rhs)
+	}
+
+	switch n.NodeKind {
+	case INTEGER:
+		return strconv.Itoa(n.Num)
+	case FLOAT:
+		return strconv.FormatFloat(n.Float, 'f', -1, 64)
+	case STRING:
+		return strconv.Quote(n.Str)
+	case IDENT:
+		str := n.Ident
+		if n.Particle != "" {
+			str += " " + n.Particle
+		}
+		// 既定値を持つ仮引数はRhsに既定値を持つ
+		if n.Rhs != nil {
+			str += "=" + n.Rhs.String()
+		}
+		return str
+	case RETURN:
+		return n.Lhs.String() + " 戻す"
+	case IF:
+		str := "もし " + n.Condition.String() + " ならば " + n.Then.String()
+		if n.Else != nil {
+			str += " それ以外 " + n.Else.String()
+		}
+		return str
+	case FOR:
+		return n.Condition.String() + " ならば 繰り返す " + n.Then.String()
+	case TERNARY:
+		return n.Condition.String() + " なら " + n.Then.String() + " でなければ " + n.Else.String()
+	case FUNC:
+		return "関数 " + n.Ident + "(" + joinNodes(n.Params) + ") " + n.Body.String()
+	case CALL:
+		if len(n.Params) > 0 && n.Params[0].NodeKind == PARTICLE {
+			strs := []string{}
+			for _, v := range n.Params {
+				strs = append(strs, v.String())
+			}
+			return strings.Join(strs, " ") + " " + calleeString(n)
+		}
+		return calleeString(n) + "(" + joinNodes(n.Params) + ")"
+	case PARTICLE:
+		return n.Lhs.String() + " " + n.Ident
+	case BLOCK:
+		strs := []string{}
+		for _, v := range n.Stmts {
+			strs = append(strs, v.String())
+		}
+		return "{ " + strings.Join(strs, " ") + " }"
+	case ARRAY:
+		return "[" + joinNodes(n.Params) + "]"
+	case HASH:
+		return "{" + joinNodes(n.Params) + "}"
+	case PAIR:
+		return n.Lhs.String() + ": " + n.Rhs.String()
+	case INDEX:
+		return operandString(n.Lhs) + "[" + n.Rhs.String() + "]"
+	case TUPLE:
+		return joinNodes(n.Params)
+	case REST:
+		return "…" + n.Lhs.String()
+	case MEMBER:
+		return operandString(n.Lhs) + "." + n.Ident
+	case IMPORT:
+		return "読み込む " + strconv.Quote(n.Str)
+	case NEG:
+		return "-" + operandString(n.Lhs)
+	default:
+		return ""
+	}
+}
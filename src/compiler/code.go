@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant      Opcode = iota // 定数を積む
+	OpNull                        // null を積む
+	OpPop                         // 一番上の値を捨てる
+	OpDup2                        // 上の二つの値を複製する
+	OpBinary                      // 二項演算。オペランドは ast.NodeKind
+	OpArithmetic                  // 数値だけの二項演算(複合代入で使う)。オペランドは ast.NodeKind
+	OpJump                        // 無条件に飛ぶ
+	OpJumpNotTruthy               // 一番上の値が偽なら飛ぶ。値は取り除く
+	OpGetName                     // 変数か組み込み関数を積む
+	OpGetVar                      // 変数を積む。組み込み関数は探さない
+	OpSetName                     // 一番上の値を変数に代入する
+	OpGetLocal                    // 解決済みの変数を積む。オペランドは深さと位置
+	OpSetLocal                    // 一番上の値を解決済みの変数に代入する
+	OpEnterBlock                  // ブロックの環境に入る。オペランドは変数の数
+	OpLeaveBlock                  // ブロックの環境から出る
+	OpFunction                    // 関数を作って積む
+	OpResolveFunc                 // 呼び出す関数を探して積む
+	OpCall                        // 関数を呼び出す
+	OpTailCall                    // 関数を呼び出して、今のフレームを置き換える。「f(x) 戻す」で使う
+	OpReturnValue                 // 一番上の値を戻す
+	OpReturn                      // 関数の終わり。一番上の値を戻す
+	OpArray                       // 配列を作る
+	OpTuple                       // 複数の値を作る
+	OpHash                        // 連想配列を作る
+	OpIndex                       // 添字で要素を取り出す
+	OpSetIndex                    // 添字で要素に代入する。オペランドが0なら[値、対象、添字]、1なら[対象、添字、値]の順に積まれている
+	OpDestructure                 // 分割代入のために値を分解して、代入先の逆順に積む
+	OpImport                      // モジュールを読み込む
+	OpMember                      // モジュールの要素を取り出す
+	OpError                       // 定数のエラーで止める
+	OpNegate                      // 一番上の値の符号を反転する
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpNull:          {"OpNull", []int{}},
+	OpPop:           {"OpPop", []int{}},
+	OpDup2:          {"OpDup2", []int{}},
+	OpBinary:        {"OpBinary", []int{1}},
+	OpArithmetic:    {"OpArithmetic", []int{1}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGetName:       {"OpGetName", []int{2}},
+	OpGetVar:        {"OpGetVar", []int{2}},
+	OpSetName:       {"OpSetName", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{2, 2}},
+	OpSetLocal:      {"OpSetLocal", []int{2, 2}},
+	OpEnterBlock:    {"OpEnterBlock", []int{2}},
+	OpLeaveBlock:    {"OpLeaveBlock", []int{}},
+	OpFunction:      {"OpFunction", []int{2}},
+	OpResolveFunc:   {"OpResolveFunc", []int{2}},
+	OpCall:          {"OpCall", []int{2}},
+	OpTailCall:      {"OpTailCall", []int{2}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+	OpArray:         {"OpArray", []int{2}},
+	OpTuple:         {"OpTuple", []int{2}},
+	OpHash:          {"OpHash", []int{2}},
+	OpIndex:         {"OpIndex", []int{}},
+	OpSetIndex:      {"OpSetIndex", []int{1}},
+	OpDestructure:   {"OpDestructure", []int{2}},
+	OpImport:        {"OpImport", []int{2}},
+	OpMember:        {"OpMember", []int{2}},
+	OpError:         {"OpError", []int{2}},
+	OpNegate:        {"OpNegate", []int{}},
+}
+
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("命令%dは定義されていません。", op)
+	}
+	return def, nil
+}
+
+// 命令を作る。オペランドは2バイトならビッグエンディアンで書く
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, w := range def.OperandWidths {
+		switch w {
+		case 1:
+			operands[i] = int(ins[offset])
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+		offset += w
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// 逆アセンブルした命令列を返す。テストとデバッグに使う
+func (ins Instructions) String() string {
+	var out strings.Builder
+	for i := 0; i < len(ins); {
+		def, err := Lookup(Opcode(ins[i]))
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s", i, def.Name)
+		for _, v := range operands {
+			fmt.Fprintf(&out, " %d", v)
+		}
+		out.WriteString("\n")
+		i += 1 + read
+	}
+	return out.String()
+}
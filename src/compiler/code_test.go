@@ -0,0 +1,53 @@
+package compiler
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expect   []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpBinary, []int{3}, []byte{byte(OpBinary), 3}},
+		{OpPop, []int{}, []byte{byte(OpPop)}},
+	}
+
+	for i, v := range tests {
+		ins := Make(v.op, v.operands...)
+		if string(ins) != string(v.expect) {
+			t.Fatalf("test%d : got=%v expect=%v\n", i, ins, v.expect)
+		}
+
+		def, err := Lookup(v.op)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		operands, read := ReadOperands(def, Instructions(ins[1:]))
+		if read != len(ins)-1 {
+			t.Fatalf("test%d : read got=%d expect=%d\n", i, read, len(ins)-1)
+		}
+		for j, o := range v.operands {
+			if operands[j] != o {
+				t.Fatalf("test%d : operand got=%d expect=%d\n", i, operands[j], o)
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	ins := Instructions{}
+	ins = append(ins, Make(OpConstant, 1)...)
+	ins = append(ins, Make(OpBinary, 2)...)
+	ins = append(ins, Make(OpJump, 65535)...)
+	ins = append(ins, Make(OpPop)...)
+
+	expect := `0000 OpConstant 1
+0003 OpBinary 2
+0005 OpJump 65535
+0008 OpPop
+`
+	if got := ins.String(); got != expect {
+		t.Fatalf("got=\n%s\nexpect=\n%s\n", got, expect)
+	}
+}
@@ -0,0 +1,389 @@
+package compiler
+
+import (
+	"fmt"
+
+	"jpl/ast"
+	"jpl/object"
+)
+
+// コンパイルした命令列と、命令から番号で参照する値
+type Bytecode struct {
+	Instructions Instructions
+	Constants    []object.Object
+	Names        []string      // 変数・モジュール・要素の名前
+	Calls        []*CallInfo   // 関数呼び出しの形
+	Functions    []*ast.Node   // 定義する関数。本体は呼び出す時にコンパイルする
+	Targets      [][]*ast.Node // 分割代入の代入先
+}
+
+type ArgKind int
+
+const (
+	ArgPositional ArgKind = iota // 位置で指定する引数
+	ArgNamed                     // 名前付き引数
+	ArgParticle                  // 助詞の付いた引数
+)
+
+// 関数呼び出しの形。引数は書かれた順に積まれる
+type CallInfo struct {
+	Name      string
+	HasModule bool         // モジュール.関数() の形なら、引数より前にモジュールが積まれる
+	Binding   *ast.Binding // 関数が解決済みの変数なら、その位置
+	Kinds     []ArgKind    // 引数ごとの種類
+	Labels    []string     // 名前付き引数の名前か助詞。位置で指定する引数は空
+}
+
+const maxOperand = 0xffff
+
+type Compiler struct {
+	bytecode *Bytecode
+	names    map[string]int
+}
+
+func New() *Compiler {
+	return &Compiler{bytecode: &Bytecode{}, names: map[string]int{}}
+}
+
+// プログラム全体をコンパイルする。最後の文の値が結果になる
+func Compile(program *ast.Program) (*Bytecode, error) {
+	c := New()
+	if err := c.compileStmts(program.Nodes); err != nil {
+		return nil, err
+	}
+	c.emit(OpReturn)
+	return c.finish()
+}
+
+// 関数の本体をコンパイルする。本体のブロックの変数は呼び出しごとの環境に置く
+func CompileFunction(body *ast.Node) (*Bytecode, error) {
+	c := New()
+	var err error
+	if body.NodeKind == ast.BLOCK {
+		err = c.compileStmts(body.Stmts)
+	} else {
+		err = c.compile(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.emit(OpReturn)
+	return c.finish()
+}
+
+// 飛び先や番号は2バイトで表すので、それを超える大きさのものはコンパイルできない
+func (c *Compiler) finish() (*Bytecode, error) {
+	b := c.bytecode
+	if len(b.Instructions) > maxOperand || len(b.Constants) > maxOperand || len(b.Names) > maxOperand || len(b.Calls) > maxOperand {
+		return nil, fmt.Errorf("プログラムが大きすぎてコンパイルできません。")
+	}
+	return b, nil
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	pos := len(c.bytecode.Instructions)
+	c.bytecode.Instructions = append(c.bytecode.Instructions, Make(op, operands...)...)
+	return pos
+}
+
+// 飛び先が決まったら、仮に置いたオペランドを書き換える
+func (c *Compiler) patchJump(pos int) {
+	copy(c.bytecode.Instructions[pos:], Make(Opcode(c.bytecode.Instructions[pos]), len(c.bytecode.Instructions)))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.bytecode.Constants = append(c.bytecode.Constants, obj)
+	return len(c.bytecode.Constants) - 1
+}
+
+func (c *Compiler) addName(name string) int {
+	if i, ok := c.names[name]; ok {
+		return i
+	}
+	c.bytecode.Names = append(c.bytecode.Names, name)
+	c.names[name] = len(c.bytecode.Names) - 1
+	return c.names[name]
+}
+
+func (c *Compiler) emitError(format string, a ...interface{}) {
+	c.emit(OpError, c.addConstant(&object.Error{Message: fmt.Sprintf(format, a...)}))
+}
+
+// 文を順にコンパイルする。各文の値は次の文の前に捨て、最後の文の値だけを残す
+func (c *Compiler) compileStmts(stmts []*ast.Node) error {
+	if len(stmts) == 0 {
+		c.emit(OpNull)
+		return nil
+	}
+	for i, v := range stmts {
+		if i > 0 {
+			c.emit(OpPop)
+		}
+		if err := c.compile(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 式か文をコンパイルする。どの節も値を一つだけ積む
+func (c *Compiler) compile(node *ast.Node) error {
+	switch node.NodeKind {
+	case ast.INTEGER:
+		c.emit(OpConstant, c.addConstant(&object.Integer{Value: node.Num}))
+	case ast.FLOAT:
+		c.emit(OpConstant, c.addConstant(&object.Float{Value: node.Float}))
+	case ast.STRING:
+		c.emit(OpConstant, c.addConstant(&object.String{Value: node.Str}))
+	case ast.IDENT:
+		if node.Binding != nil {
+			c.emit(OpGetLocal, node.Binding.Depth, node.Binding.Slot)
+		} else {
+			c.emit(OpGetName, c.addName(node.Ident))
+		}
+	case ast.ASSIGN:
+		if err := c.compile(node.Rhs); err != nil {
+			return err
+		}
+		if err := c.compileAssign(node.Lhs); err != nil {
+			return err
+		}
+		c.emit(OpNull)
+	case ast.ADD_ASSIGN, ast.SUB_ASSIGN, ast.MUL_ASSIGN, ast.DIV_ASSIGN:
+		return c.compileCompoundAssign(node)
+	case ast.RETURN:
+		var err error
+		if node.Lhs.NodeKind == ast.CALL {
+			err = c.compileCall(node.Lhs, OpTailCall)
+		} else {
+			err = c.compile(node.Lhs)
+		}
+		if err != nil {
+			return err
+		}
+		c.emit(OpReturnValue)
+	case ast.IF, ast.TERNARY:
+		return c.compileIf(node)
+	case ast.FOR:
+		return c.compileFor(node)
+	case ast.BLOCK:
+		c.emit(OpEnterBlock, node.Locals)
+		if err := c.compileStmts(node.Stmts); err != nil {
+			return err
+		}
+		c.emit(OpLeaveBlock)
+	case ast.FUNC:
+		c.bytecode.Functions = append(c.bytecode.Functions, node)
+		c.emit(OpFunction, len(c.bytecode.Functions)-1)
+		c.emitSetVar(node)
+		c.emit(OpNull)
+	case ast.CALL:
+		return c.compileCall(node, OpCall)
+	case ast.ARRAY, ast.TUPLE:
+		if err := c.compileNodes(node.Params); err != nil {
+			return err
+		}
+		if node.NodeKind == ast.ARRAY {
+			c.emit(OpArray, len(node.Params))
+		} else {
+			c.emit(OpTuple, len(node.Params))
+		}
+	case ast.HASH:
+		for _, pair := range node.Params {
+			if err := c.compileNodes([]*ast.Node{pair.Lhs, pair.Rhs}); err != nil {
+				return err
+			}
+		}
+		c.emit(OpHash, len(node.Params))
+	case ast.REST:
+		c.emitError("\"…\"は分割代入の左辺でのみ使えます。")
+	case ast.INDEX:
+		if err := c.compileNodes([]*ast.Node{node.Lhs, node.Rhs}); err != nil {
+			return err
+		}
+		c.emit(OpIndex)
+	case ast.IMPORT:
+		c.emit(OpImport, c.addName(node.Str))
+	case ast.MEMBER:
+		if err := c.compile(node.Lhs); err != nil {
+			return err
+		}
+		c.emit(OpMember, c.addName(node.Ident))
+	case ast.NEG:
+		if err := c.compile(node.Lhs); err != nil {
+			return err
+		}
+		c.emit(OpNegate)
+	case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.EQ, ast.NOT_EQ, ast.GT, ast.GE:
+		if err := c.compileNodes([]*ast.Node{node.Lhs, node.Rhs}); err != nil {
+			return err
+		}
+		c.emit(OpBinary, int(node.NodeKind))
+	default:
+		return fmt.Errorf("コンパイルできない式です。種類=%d", node.NodeKind)
+	}
+	return nil
+}
+
+func (c *Compiler) compileNodes(nodes []*ast.Node) error {
+	for _, v := range nodes {
+		if err := c.compile(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 一番上の値を代入先に代入する。値は取り除く
+func (c *Compiler) compileAssign(target *ast.Node) error {
+	switch target.NodeKind {
+	case ast.IDENT:
+		c.emitSetVar(target)
+	case ast.INDEX:
+		if err := c.compileNodes([]*ast.Node{target.Lhs, target.Rhs}); err != nil {
+			return err
+		}
+		c.emit(OpSetIndex, 0)
+	case ast.TUPLE, ast.ARRAY:
+		c.bytecode.Targets = append(c.bytecode.Targets, target.Params)
+		c.emit(OpDestructure, len(c.bytecode.Targets)-1)
+		for _, v := range target.Params {
+			if v.NodeKind == ast.REST {
+				v = v.Lhs
+			}
+			if err := c.compileAssign(v); err != nil {
+				return err
+			}
+		}
+	default:
+		c.emit(OpPop)
+		c.emitError("代入できない式です。")
+	}
+	return nil
+}
+
+// 一番上の値を変数に代入する。解決済みなら位置で、そうでなければ名前で代入する
+func (c *Compiler) emitSetVar(node *ast.Node) {
+	if node.Binding != nil {
+		c.emit(OpSetLocal, node.Binding.Depth, node.Binding.Slot)
+		return
+	}
+	c.emit(OpSetName, c.addName(node.Ident))
+}
+
+var compoundOperators = map[ast.NodeKind]ast.NodeKind{
+	ast.ADD_ASSIGN: ast.ADD,
+	ast.SUB_ASSIGN: ast.SUB,
+	ast.MUL_ASSIGN: ast.MUL,
+	ast.DIV_ASSIGN: ast.DIV,
+}
+
+// 添字の式が二度評価されないように、対象と添字を複製して使う
+func (c *Compiler) compileCompoundAssign(node *ast.Node) error {
+	op := int(compoundOperators[node.NodeKind])
+
+	switch node.Lhs.NodeKind {
+	case ast.IDENT:
+		if node.Lhs.Binding != nil {
+			c.emit(OpGetLocal, node.Lhs.Binding.Depth, node.Lhs.Binding.Slot)
+		} else {
+			c.emit(OpGetVar, c.addName(node.Lhs.Ident))
+		}
+		if err := c.compile(node.Rhs); err != nil {
+			return err
+		}
+		c.emit(OpArithmetic, op)
+		c.emitSetVar(node.Lhs)
+	case ast.INDEX:
+		if err := c.compileNodes([]*ast.Node{node.Lhs.Lhs, node.Lhs.Rhs}); err != nil {
+			return err
+		}
+		c.emit(OpDup2)
+		c.emit(OpIndex)
+		if err := c.compile(node.Rhs); err != nil {
+			return err
+		}
+		c.emit(OpArithmetic, op)
+		c.emit(OpSetIndex, 1)
+	default:
+		c.emitError("代入できない式です。")
+		return nil
+	}
+	c.emit(OpNull)
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.Node) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(OpJumpNotTruthy, 0xffff)
+	if err := c.compile(node.Then); err != nil {
+		return err
+	}
+	jump := c.emit(OpJump, 0xffff)
+
+	c.patchJump(jumpNotTruthy)
+	if node.Else != nil {
+		if err := c.compile(node.Else); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNull)
+	}
+	c.patchJump(jump)
+	return nil
+}
+
+// 繰り返しの値は最後に実行した本体の値。一度も実行しなければ null になる
+func (c *Compiler) compileFor(node *ast.Node) error {
+	c.emit(OpNull)
+	start := len(c.bytecode.Instructions)
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(OpJumpNotTruthy, 0xffff)
+	c.emit(OpPop)
+	if err := c.compile(node.Then); err != nil {
+		return err
+	}
+	c.emit(OpJump, start)
+	c.patchJump(jumpNotTruthy)
+	return nil
+}
+
+// 関数を先に探してから、引数を書かれた順に積む
+func (c *Compiler) compileCall(node *ast.Node, op Opcode) error {
+	info := &CallInfo{Name: node.Ident, HasModule: node.Lhs != nil, Binding: node.Binding}
+	if node.Lhs != nil {
+		if err := c.compile(node.Lhs); err != nil {
+			return err
+		}
+	}
+	c.bytecode.Calls = append(c.bytecode.Calls, info)
+	index := len(c.bytecode.Calls) - 1
+	c.emit(OpResolveFunc, index)
+
+	for _, v := range node.Params {
+		var value *ast.Node
+		switch v.NodeKind {
+		case ast.PAIR:
+			info.Kinds = append(info.Kinds, ArgNamed)
+			info.Labels = append(info.Labels, v.Lhs.Ident)
+			value = v.Rhs
+		case ast.PARTICLE:
+			info.Kinds = append(info.Kinds, ArgParticle)
+			info.Labels = append(info.Labels, v.Ident)
+			value = v.Lhs
+		default:
+			info.Kinds = append(info.Kinds, ArgPositional)
+			info.Labels = append(info.Labels, "")
+			value = v
+		}
+		if err := c.compile(value); err != nil {
+			return err
+		}
+	}
+	c.emit(op, index)
+	return nil
+}
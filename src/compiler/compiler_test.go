@@ -0,0 +1,116 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"jpl/ast"
+	"jpl/parser"
+	"jpl/token"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"1 + 2", []string{
+			"0000 OpConstant 0",
+			"0003 OpConstant 1",
+			fmt.Sprintf("0006 OpBinary %d", ast.ADD),
+			"0008 OpReturn",
+		}},
+		{"a = 1 a", []string{
+			"0000 OpConstant 0",
+			"0003 OpSetName 0",
+			"0006 OpNull",
+			"0007 OpPop",
+			"0008 OpGetName 0",
+			"0011 OpReturn",
+		}},
+		{"a += 1", []string{
+			"0000 OpGetVar 0",
+			"0003 OpConstant 0",
+			fmt.Sprintf("0006 OpArithmetic %d", ast.ADD),
+			"0008 OpSetName 0",
+			"0011 OpNull",
+			"0012 OpReturn",
+		}},
+		{"もし a ならば 1 それ以外 2", []string{
+			"0000 OpGetName 0",
+			"0003 OpJumpNotTruthy 12",
+			"0006 OpConstant 0",
+			"0009 OpJump 15",
+			"0012 OpConstant 1",
+			"0015 OpReturn",
+		}},
+		{"a < 5 ならば 繰り返す a = a + 1", []string{
+			"0000 OpNull",
+			"0001 OpGetName 0",
+			"0004 OpConstant 0",
+			fmt.Sprintf("0007 OpBinary %d", ast.GT),
+			"0009 OpJumpNotTruthy 28",
+			"0012 OpPop",
+			"0013 OpGetName 0",
+			"0016 OpConstant 1",
+			fmt.Sprintf("0019 OpBinary %d", ast.ADD),
+			"0021 OpSetName 0",
+			"0024 OpNull",
+			"0025 OpJump 1",
+			"0028 OpReturn",
+		}},
+		{"f(1) 戻す", []string{
+			"0000 OpResolveFunc 0",
+			"0003 OpConstant 0",
+			"0006 OpTailCall 0",
+			"0009 OpReturnValue",
+			"0010 OpReturn",
+		}},
+		{"足す(1、b: 2)", []string{
+			"0000 OpResolveFunc 0",
+			"0003 OpConstant 0",
+			"0006 OpConstant 1",
+			"0009 OpCall 0",
+			"0012 OpReturn",
+		}},
+	}
+
+	for i, v := range tests {
+		program, errors := parser.Parse(token.Tokenize(v.input))
+		if len(errors) > 0 {
+			t.Fatalf("test%d : %v\n", i, errors)
+		}
+
+		bytecode, err := Compile(program)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		expect := strings.Join(v.expect, "\n") + "\n"
+		if got := bytecode.Instructions.String(); got != expect {
+			t.Fatalf("test%d : got=\n%s\nexpect=\n%s\n", i, got, expect)
+		}
+	}
+}
+
+func TestCompileCallInfo(t *testing.T) {
+	program, errors := parser.Parse(token.Tokenize("数学.最大(1、上限: 2)"))
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	bytecode, err := Compile(program)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+	if len(bytecode.Calls) != 1 {
+		t.Fatalf("calls : got=%d expect=%d\n", len(bytecode.Calls), 1)
+	}
+	info := bytecode.Calls[0]
+	if info.Name != "最大" || !info.HasModule {
+		t.Fatalf("got=%s %t\n", info.Name, info.HasModule)
+	}
+	if len(info.Kinds) != 2 || info.Kinds[0] != ArgPositional || info.Kinds[1] != ArgNamed || info.Labels[1] != "上限" {
+		t.Fatalf("got=%v %v\n", info.Kinds, info.Labels)
+	}
+}
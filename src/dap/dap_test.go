@@ -0,0 +1,328 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"jpl/evaluator"
+	"jpl/transport"
+)
+
+const script = `関数 階乗(n) {
+	もし n <= 1 ならば 1 戻す
+	r = n * 階乗(n - 1)
+	r 戻す
+}
+x = 3
+一覧 = [x、4]
+y = 階乗(x)
+表示(y)
+`
+
+type message struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// 台本どおりに要求を送るクライアント
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	msgs   chan *message
+	events []*message // まだ待っていないイベント
+	seq    int
+	served chan error
+}
+
+func startServer(t *testing.T, src string) (*client, string) {
+	path := filepath.Join(t.TempDir(), "main.jpl")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+	c := &client{t: t, w: requestWriter, msgs: make(chan *message, 100), served: make(chan error, 1)}
+	go func() {
+		c.served <- Serve(requests, responses, evaluator.New())
+		responses.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(responseReader)
+		for {
+			body, err := transport.ReadMessage(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+			}
+			c.msgs <- &msg
+		}
+	}()
+	return c, path
+}
+
+func (c *client) next() *message {
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("サーバが接続を閉じました。")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("応答がありません。")
+		return nil
+	}
+}
+
+// 要求を送って応答を待つ。間に来たイベントは取っておく
+func (c *client) request(command string, args interface{}) *message {
+	c.t.Helper()
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+	if err := transport.WriteMessage(c.w, req); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq || msg.Command != command {
+			c.t.Fatalf("%sの応答ではありません。%+v", command, msg)
+		}
+		return msg
+	}
+}
+
+func (c *client) success(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	res := c.request(command, args)
+	if !res.Success {
+		c.t.Fatalf("%sが失敗しました。%s", command, res.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(res.Body, body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// イベントを待つ。途中の別のイベントは読み飛ばす
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Type == "event" && msg.Event == name {
+			if body != nil {
+				if err := json.Unmarshal(msg.Body, body); err != nil {
+					c.t.Fatal(err)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+	var body struct{ Reason string }
+	c.event("stopped", &body)
+	if body.Reason != reason {
+		c.t.Fatalf("止まった理由: got=%s expect=%s", body.Reason, reason)
+	}
+}
+
+type stackTrace struct {
+	StackFrames []StackFrame
+}
+
+func (c *client) stackTrace() []StackFrame {
+	c.t.Helper()
+	var body stackTrace
+	c.success("stackTrace", map[string]interface{}{"threadId": threadID}, &body)
+	return body.StackFrames
+}
+
+func (c *client) variables(ref int) map[string]Variable {
+	c.t.Helper()
+	var body struct{ Variables []Variable }
+	c.success("variables", map[string]interface{}{"variablesReference": ref}, &body)
+	vars := map[string]Variable{}
+	for _, v := range body.Variables {
+		vars[v.Name] = v
+	}
+	return vars
+}
+
+func (c *client) scopes(frameID int) map[string]int {
+	c.t.Helper()
+	var body struct{ Scopes []Scope }
+	c.success("scopes", map[string]interface{}{"frameId": frameID}, &body)
+	refs := map[string]int{}
+	for _, scope := range body.Scopes {
+		refs[scope.Name] = scope.VariablesReference
+	}
+	return refs
+}
+
+func (c *client) disconnect() {
+	c.t.Helper()
+	c.success("disconnect", nil, nil)
+	select {
+	case err := <-c.served:
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("サーバが終わりません。")
+	}
+}
+
+func TestDebugSession(t *testing.T) {
+	c, path := startServer(t, script)
+
+	var capabilities map[string]bool
+	c.success("initialize", map[string]interface{}{"adapterID": "jpl"}, &capabilities)
+	if !capabilities["supportsConfigurationDoneRequest"] {
+		t.Fatalf("capabilities: %v", capabilities)
+	}
+	c.success("launch", map[string]interface{}{"program": path, "stopOnEntry": true}, nil)
+	c.event("initialized", nil)
+
+	var breakpoints struct{ Breakpoints []Breakpoint }
+	c.success("setBreakpoints", map[string]interface{}{
+		"source":      Source{Path: path},
+		"breakpoints": []map[string]int{{"line": 3}, {"line": 5}},
+	}, &breakpoints)
+	if bps := breakpoints.Breakpoints; len(bps) != 2 || !bps[0].Verified || bps[1].Verified {
+		t.Fatalf("breakpoints: %+v", bps)
+	}
+
+	c.success("configurationDone", nil, nil)
+	c.stopped("entry")
+	if frames := c.stackTrace(); len(frames) != 1 || frames[0].Name != "メイン" || frames[0].Line != 1 || frames[0].Source.Path != path {
+		t.Fatalf("frames: %+v", frames)
+	}
+
+	c.success("next", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("step")
+	if frames := c.stackTrace(); frames[0].Line != 6 {
+		t.Fatalf("frames: %+v", frames)
+	}
+
+	c.success("continue", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("breakpoint")
+	frames := c.stackTrace()
+	if len(frames) != 2 || frames[0].Name != "階乗" || frames[0].Line != 3 || frames[1].Name != "メイン" || frames[1].Line != 8 {
+		t.Fatalf("frames: %+v", frames)
+	}
+
+	locals := c.variables(c.scopes(frames[0].ID)["局所変数"])
+	if len(locals) != 1 || locals["n"].Value != "3" || locals["n"].Type != "INTEGER" {
+		t.Fatalf("locals: %+v", locals)
+	}
+	globals := c.variables(c.scopes(frames[1].ID)["大域変数"])
+	if globals["x"].Value != "3" || globals["一覧"].Value != "[3, 4]" {
+		t.Fatalf("globals: %+v", globals)
+	}
+	elements := c.variables(globals["一覧"].VariablesReference)
+	if len(elements) != 2 || elements["[0]"].Value != "3" || elements["[1]"].Value != "4" {
+		t.Fatalf("elements: %+v", elements)
+	}
+
+	var result struct{ Result string }
+	c.success("evaluate", map[string]interface{}{"expression": "n * 10", "frameId": frames[0].ID}, &result)
+	if result.Result != "30" {
+		t.Fatalf("evaluate: %s", result.Result)
+	}
+	if res := c.request("evaluate", map[string]interface{}{"expression": "未定義", "frameId": frames[0].ID}); res.Success || res.Message == "" {
+		t.Fatalf("evaluate: %+v", res)
+	}
+
+	// ブレークポイントを消して関数から出る
+	c.success("setBreakpoints", map[string]interface{}{"source": Source{Path: path}, "breakpoints": []int{}}, nil)
+	c.success("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("step")
+	if frames := c.stackTrace(); len(frames) != 1 || frames[0].Line != 9 {
+		t.Fatalf("frames: %+v", frames)
+	}
+
+	c.success("continue", map[string]interface{}{"threadId": threadID}, nil)
+	var output struct{ Category, Output string }
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "6\n" {
+		t.Fatalf("output: %+v", output)
+	}
+	var exited struct{ ExitCode int }
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Fatalf("exitCode: %d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	// 終わった後は止まっている時の要求を受け付けない
+	if res := c.request("stackTrace", map[string]interface{}{"threadId": threadID}); res.Success {
+		t.Fatalf("stackTrace: %+v", res)
+	}
+	c.disconnect()
+}
+
+func TestPauseAndDisconnect(t *testing.T) {
+	c, path := startServer(t, "a = 0\n1 == 1 ならば 繰り返す {\n\ta += 1\n}\n")
+
+	c.success("initialize", nil, nil)
+	c.success("launch", map[string]interface{}{"program": path}, nil)
+	c.success("configurationDone", nil, nil)
+
+	// 止まっていない時は変数を見られない
+	if res := c.request("stackTrace", map[string]interface{}{"threadId": threadID}); res.Success {
+		t.Fatalf("stackTrace: %+v", res)
+	}
+
+	c.success("pause", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("pause")
+	if frames := c.stackTrace(); frames[0].Line != 3 {
+		t.Fatalf("frames: %+v", frames)
+	}
+	// 止まったまま切断するとプログラムも止める
+	c.disconnect()
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c, path := startServer(t, "x = (")
+
+	c.success("initialize", nil, nil)
+	if res := c.request("launch", map[string]interface{}{}); res.Success {
+		t.Fatalf("launch: %+v", res)
+	}
+	if res := c.request("launch", map[string]interface{}{"program": path}); res.Success {
+		t.Fatalf("launch: %+v", res)
+	}
+	if res := c.request("unknown", nil); res.Success || res.Message != "「unknown」という要求には対応していません。" {
+		t.Fatalf("unknown: %+v", res)
+	}
+	c.disconnect()
+}
@@ -0,0 +1,515 @@
+// Debug Adapter Protocol のサーバ。エディタから debugger.Session を操作する。
+//
+// 要求は一つのゴルーチンで順に読み、プログラムは別のゴルーチンで実行する。
+// 止まっている間の変数の参照や評価は、プログラムを実行するゴルーチンに渡して行う。
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+
+	"jpl/ast"
+	"jpl/debugger"
+	"jpl/evaluator"
+	"jpl/object"
+	"jpl/transport"
+)
+
+// スレッドは一つしかない
+const threadID = 1
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	e   *evaluator.Evaluator
+
+	wmu sync.Mutex // out に書くメッセージが混ざらないようにする
+	seq int
+
+	session     *debugger.Session
+	stopOnEntry bool
+	started     bool
+	finished    chan struct{} // プログラムが終わると閉じる
+
+	mu       sync.Mutex
+	paused   bool
+	commands chan func() bool // 止まっている間に実行する。真を返すと実行を続ける
+
+	// variables の参照。止まっている間だけ有効で、プログラムを実行するゴルーチンだけが使う
+	refs []func() []Variable
+}
+
+func NewServer(in io.Reader, out io.Writer, e *evaluator.Evaluator) *Server {
+	return &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		e:        e,
+		finished: make(chan struct{}),
+		commands: make(chan func() bool),
+	}
+}
+
+// in から要求を読んで応答する。disconnect を受け取るか in が終わると戻る
+func Serve(in io.Reader, out io.Writer, e *evaluator.Evaluator) error {
+	return NewServer(in, out, e).Serve()
+}
+
+// addr で接続を待ち、接続ごとに newEvaluator で作った評価器でデバッグする。接続は一つずつ受け付ける
+func ListenAndServe(addr string, newEvaluator func() *evaluator.Evaluator) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		err = Serve(conn, conn, newEvaluator())
+		conn.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) Serve() error {
+	defer s.shutdown()
+	for {
+		body, err := transport.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("要求が正しくありません。%s", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.shutdown()
+			s.respond(&req, nil)
+			if req.Command == "disconnect" {
+				return nil
+			}
+			continue
+		}
+		s.handle(&req)
+	}
+}
+
+func (s *Server) send(msg interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *Response:
+		m.Seq = s.seq
+	case *Event:
+		m.Seq = s.seq
+	}
+	transport.WriteMessage(s.out, msg)
+}
+
+func (s *Server) respond(req *Request, body interface{}) {
+	s.send(&Response{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *Server) fail(req *Request, format string, args ...interface{}) {
+	s.send(&Response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: fmt.Sprintf(format, args...)})
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.send(&Event{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(req *Request) {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+	case "launch":
+		s.launch(req)
+	case "setBreakpoints":
+		s.setBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.respond(req, map[string]interface{}{"breakpoints": []Breakpoint{}})
+	case "configurationDone":
+		s.configurationDone(req)
+	case "threads":
+		s.respond(req, map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "メイン"}}})
+	case "pause":
+		if s.session == nil {
+			s.fail(req, "プログラムを起動していません。")
+			return
+		}
+		s.session.Pause()
+		s.respond(req, nil)
+	case "continue":
+		s.resume(req, debugger.Continue)
+	case "next":
+		s.resume(req, debugger.StepOver)
+	case "stepIn":
+		s.resume(req, debugger.StepIn)
+	case "stepOut":
+		s.resume(req, debugger.StepOut)
+	case "stackTrace":
+		s.whilePaused(req, s.stackTrace)
+	case "scopes":
+		s.whilePaused(req, s.scopes)
+	case "variables":
+		s.whilePaused(req, s.variables)
+	case "evaluate":
+		s.whilePaused(req, s.evaluate)
+	default:
+		s.fail(req, "「%s」という要求には対応していません。", req.Command)
+	}
+}
+
+func (s *Server) launch(req *Request) {
+	var args launchArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil || args.Program == "" {
+		s.fail(req, "実行するファイルをprogramに指定してください。")
+		return
+	}
+	if s.session != nil {
+		s.fail(req, "プログラムはもう起動しています。")
+		return
+	}
+	session := debugger.NewSession(s.e)
+	if err := session.Load(args.Program); err != nil {
+		s.fail(req, "%s", err)
+		return
+	}
+	session.OnPause = s.pause
+	s.session = session
+	s.stopOnEntry = args.StopOnEntry
+	s.respond(req, nil)
+	// ブレークポイントは実行するファイルを読み込んでから受け付ける
+	s.event("initialized", nil)
+}
+
+func (s *Server) setBreakpoints(req *Request) {
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "引数が正しくありません。%s", err)
+		return
+	}
+	if s.session == nil {
+		s.fail(req, "プログラムを起動していません。")
+		return
+	}
+
+	breakpoints := []Breakpoint{}
+	if !s.samePath(args.Source.Path) {
+		// 読み込んだモジュールにはブレークポイントを置けない
+		for _, bp := range args.Breakpoints {
+			breakpoints = append(breakpoints, Breakpoint{Line: bp.Line, Message: "実行するファイルにだけ置けます。"})
+		}
+		s.respond(req, map[string]interface{}{"breakpoints": breakpoints})
+		return
+	}
+	s.session.ClearBreakpoints()
+	for _, bp := range args.Breakpoints {
+		if s.session.SetBreakpoint(bp.Line) {
+			breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: bp.Line})
+		} else {
+			breakpoints = append(breakpoints, Breakpoint{Line: bp.Line, Message: fmt.Sprintf("%d行目には止まれる文がありません。", bp.Line)})
+		}
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": breakpoints})
+}
+
+func (s *Server) samePath(path string) bool {
+	a, err1 := filepath.Abs(path)
+	b, err2 := filepath.Abs(s.session.Path())
+	return err1 == nil && err2 == nil && a == b
+}
+
+func (s *Server) configurationDone(req *Request) {
+	if s.session == nil {
+		s.fail(req, "プログラムを起動していません。")
+		return
+	}
+	if s.started {
+		s.respond(req, nil)
+		return
+	}
+	s.started = true
+	if !s.stopOnEntry {
+		s.session.SetMode(debugger.Continue)
+	}
+	s.respond(req, nil)
+	go s.run()
+}
+
+// 表示を output イベントにして送る
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]interface{}{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
+
+func (s *Server) run() {
+	defer close(s.finished)
+
+	out := s.e.Out
+	s.e.Out = outputWriter{s}
+	res := s.session.Run(object.NewEnvironment())
+	s.e.Out = out
+
+	exitCode := 0
+	if res.Type() == object.ERROR {
+		exitCode = 1
+		s.event("output", map[string]interface{}{"category": "stderr", "output": res.Inspect() + "\n"})
+	} else if res.Type() != object.NULL {
+		s.event("output", map[string]interface{}{"category": "console", "output": res.Inspect() + "\n"})
+	}
+	s.event("exited", map[string]interface{}{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// 実行中のプログラムを止めて終わるのを待つ
+func (s *Server) shutdown() {
+	if !s.started {
+		return
+	}
+	s.session.Stop()
+	// 止まっていれば実行を続けさせる。続けると次の文で止まる
+	for done := false; !done; {
+		select {
+		case <-s.finished:
+			done = true
+		case s.commands <- func() bool { return true }:
+		}
+	}
+}
+
+// 止まった時に呼ばれる。実行を続ける要求が来るまで、送られた処理を実行する
+func (s *Server) pause(node *ast.Node, reason debugger.Reason) object.Object {
+	s.refs = nil
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+
+	name := "step"
+	switch {
+	case s.stopOnEntry:
+		name = "entry"
+		s.stopOnEntry = false
+	case reason == debugger.BreakpointReason:
+		name = "breakpoint"
+	case reason == debugger.PauseReason:
+		name = "pause"
+	}
+	s.event("stopped", map[string]interface{}{"reason": name, "threadId": threadID, "allThreadsStopped": true})
+
+	for command := range s.commands {
+		if command() {
+			break
+		}
+	}
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+	return nil
+}
+
+// 止まっている時だけ、プログラムを実行するゴルーチンで fn を実行して応答する
+func (s *Server) whilePaused(req *Request, fn func(*Request) (interface{}, error)) {
+	s.mu.Lock()
+	paused := s.paused
+	s.mu.Unlock()
+	if !paused {
+		s.fail(req, "プログラムが止まっている時だけ受け付けます。")
+		return
+	}
+
+	done := make(chan struct{})
+	s.commands <- func() bool {
+		defer close(done)
+		body, err := fn(req)
+		if err != nil {
+			s.fail(req, "%s", err)
+		} else {
+			s.respond(req, body)
+		}
+		return false
+	}
+	<-done
+}
+
+func (s *Server) resume(req *Request, mode debugger.Mode) {
+	s.mu.Lock()
+	paused := s.paused
+	s.mu.Unlock()
+	if !paused {
+		s.fail(req, "プログラムが止まっている時だけ受け付けます。")
+		return
+	}
+
+	done := make(chan struct{})
+	s.commands <- func() bool {
+		defer close(done)
+		s.session.SetMode(mode)
+		if mode == debugger.Continue {
+			s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+		} else {
+			s.respond(req, nil)
+		}
+		return true
+	}
+	<-done
+}
+
+// frameId は呼び出し履歴の外側から数えた番号に1を足したもの
+func (s *Server) frame(id int) (*debugger.Frame, error) {
+	frames := s.session.Frames()
+	if id == 0 {
+		return frames[len(frames)-1], nil
+	}
+	if id < 1 || len(frames) < id {
+		return nil, fmt.Errorf("呼び出し履歴に%d番の段はありません。", id)
+	}
+	return frames[id-1], nil
+}
+
+func (s *Server) stackTrace(req *Request) (interface{}, error) {
+	frames := s.session.Frames()
+	stack := []StackFrame{}
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		frame := StackFrame{ID: i + 1, Name: f.Name, Line: f.Line, Column: 1}
+		if f.Main {
+			path, _ := filepath.Abs(s.session.Path())
+			frame.Source = &Source{Name: filepath.Base(path), Path: path}
+		} else {
+			// 読み込んだモジュールの中はソースを示せない
+			frame.PresentationHint = "subtle"
+		}
+		stack = append(stack, frame)
+	}
+	return map[string]interface{}{"stackFrames": stack, "totalFrames": len(stack)}, nil
+}
+
+func (s *Server) scopes(req *Request) (interface{}, error) {
+	var args frameArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, fmt.Errorf("引数が正しくありません。%s", err)
+	}
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	locals := s.reference(func() []Variable { return s.toVariables(s.session.Locals(f)) })
+	globals := s.reference(func() []Variable { return s.toVariables(s.session.Globals(f)) })
+	return map[string]interface{}{"scopes": []Scope{
+		{Name: "局所変数", VariablesReference: locals},
+		{Name: "大域変数", VariablesReference: globals},
+	}}, nil
+}
+
+func (s *Server) variables(req *Request) (interface{}, error) {
+	var args variablesArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, fmt.Errorf("引数が正しくありません。%s", err)
+	}
+	ref := args.VariablesReference
+	if ref < 1 || len(s.refs) < ref {
+		return nil, fmt.Errorf("%d番の参照はありません。", ref)
+	}
+	return map[string]interface{}{"variables": s.refs[ref-1]()}, nil
+}
+
+func (s *Server) evaluate(req *Request) (interface{}, error) {
+	var args evaluateArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, fmt.Errorf("引数が正しくありません。%s", err)
+	}
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.session.Eval(f, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if res.Type() == object.ERROR {
+		return nil, fmt.Errorf("%s", res.Inspect())
+	}
+	return map[string]interface{}{
+		"result":             res.Inspect(),
+		"type":               string(res.Type()),
+		"variablesReference": s.objectReference(res),
+	}, nil
+}
+
+func (s *Server) reference(children func() []Variable) int {
+	s.refs = append(s.refs, children)
+	return len(s.refs)
+}
+
+// 配列、組、連想配列は中身を開けるように参照を作る。それ以外は0
+func (s *Server) objectReference(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return s.elementsReference(obj.Elements)
+	case *object.Tuple:
+		return s.elementsReference(obj.Elements)
+	case *object.Hash:
+		if len(obj.Keys) == 0 {
+			return 0
+		}
+		return s.reference(func() []Variable {
+			vars := []Variable{}
+			for _, key := range obj.Keys {
+				pair := obj.Pairs[key]
+				vars = append(vars, s.toVariable(pair.Key.Inspect(), pair.Value))
+			}
+			return vars
+		})
+	default:
+		return 0
+	}
+}
+
+func (s *Server) elementsReference(elements []object.Object) int {
+	if len(elements) == 0 {
+		return 0
+	}
+	return s.reference(func() []Variable {
+		vars := []Variable{}
+		for i, element := range elements {
+			vars = append(vars, s.toVariable(fmt.Sprintf("[%d]", i), element))
+		}
+		return vars
+	})
+}
+
+func (s *Server) toVariable(name string, obj object.Object) Variable {
+	return Variable{Name: name, Value: obj.Inspect(), Type: string(obj.Type()), VariablesReference: s.objectReference(obj)}
+}
+
+func (s *Server) toVariables(vars []object.Variable) []Variable {
+	res := []Variable{}
+	for _, v := range vars {
+		res = append(res, s.toVariable(v.Name, v.Value))
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+// 対話型のデバッガ。評価器の Hook を使って文ごとに止まり、コマンドを受け付ける
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"jpl/ast"
+	"jpl/evaluator"
+	"jpl/object"
+)
+
+const PROMPT = "(jpl) "
+
+const help = `c          次のブレークポイントまで進む
+s          次の文へ進む。関数の中にも入る
+n          次の文へ進む。関数の呼び出しは一度に実行する
+o          今の関数から戻るまで進む
+b 行       ブレークポイントを置く。行を省くと一覧を表示する
+d 行       ブレークポイントを消す
+l          変数を表示する
+p 式       止まった場所で式を評価する
+bt         呼び出し履歴を表示する
+list       止まった場所の前後の行を表示する
+q          実行を止めて終わる
+空行       前のコマンドを繰り返す`
+
+type Debugger struct {
+	*Session
+	in   *bufio.Scanner
+	out  io.Writer
+	last string // 前のコマンド
+}
+
+func New(e *evaluator.Evaluator, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{Session: NewSession(e), in: bufio.NewScanner(in), out: out}
+	d.OnPause = d.pause
+	return d
+}
+
+// ファイルをデバッガの下で実行する。最初の文で止まる
+func (d *Debugger) Run(path string, env *object.Environment) object.Object {
+	if err := d.Load(path); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return d.Session.Run(env)
+}
+
+// 止まった場所を表示してコマンドを受け付ける。実行を続けるコマンドで戻る
+func (d *Debugger) pause(node *ast.Node, reason Reason) object.Object {
+	d.printLocation(node)
+	for {
+		fmt.Fprint(d.out, PROMPT)
+		if !d.in.Scan() {
+			// 入力が終わったら最後まで実行する
+			fmt.Fprintln(d.out)
+			d.SetMode(Continue)
+			d.ClearBreakpoints()
+			return nil
+		}
+
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd {
+		case "c":
+			d.SetMode(Continue)
+			return nil
+		case "s":
+			d.SetMode(StepIn)
+			return nil
+		case "n":
+			d.SetMode(StepOver)
+			return nil
+		case "o":
+			d.SetMode(StepOut)
+			return nil
+		case "q":
+			d.stopped = true
+			return &object.Error{Message: "デバッガで実行を止めました。"}
+		case "b":
+			d.setBreakpoint(arg)
+		case "d":
+			d.deleteBreakpoint(arg)
+		case "l":
+			d.printVariables()
+		case "p":
+			d.print(arg)
+		case "bt":
+			d.printBacktrace()
+		case "list":
+			d.printSource(d.top().Line)
+		case "h", "help":
+			fmt.Fprintln(d.out, help)
+		case "":
+		default:
+			fmt.Fprintf(d.out, "「%s」というコマンドはありません。h で一覧を表示します。\n", cmd)
+		}
+	}
+}
+
+func (d *Debugger) printLocation(node *ast.Node) {
+	if d.IsMain(node) {
+		fmt.Fprintf(d.out, "[%s] %d行目: %s\n", d.top().Name, node.Line, d.SourceLine(node.Line))
+	} else {
+		fmt.Fprintf(d.out, "[%s] %d行目 (読み込んだモジュール): %s\n", d.top().Name, node.Line, node.String())
+	}
+}
+
+func (d *Debugger) printSource(line int) {
+	lines := d.Lines()
+	for i := line - 3; i <= line+3; i++ {
+		if i < 1 || len(lines) < i {
+			continue
+		}
+		marker := "  "
+		if i == line {
+			marker = "→ "
+		} else if d.HasBreakpoint(i) {
+			marker = "● "
+		}
+		fmt.Fprintf(d.out, "%s%4d  %s\n", marker, i, lines[i-1])
+	}
+}
+
+func (d *Debugger) lineArg(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintln(d.out, "行の番号が必要です。")
+		return 0, false
+	}
+	return line, true
+}
+
+func (d *Debugger) setBreakpoint(arg string) {
+	if arg == "" {
+		lines := d.Breakpoints()
+		if len(lines) == 0 {
+			fmt.Fprintln(d.out, "ブレークポイントはありません。")
+			return
+		}
+		for _, line := range lines {
+			fmt.Fprintf(d.out, "%d行目: %s\n", line, d.SourceLine(line))
+		}
+		return
+	}
+
+	line, ok := d.lineArg(arg)
+	if !ok {
+		return
+	}
+	if !d.SetBreakpoint(line) {
+		fmt.Fprintf(d.out, "%d行目には止まれる文がありません。\n", line)
+		return
+	}
+	fmt.Fprintf(d.out, "%d行目にブレークポイントを置きました。\n", line)
+}
+
+func (d *Debugger) deleteBreakpoint(arg string) {
+	line, ok := d.lineArg(arg)
+	if !ok {
+		return
+	}
+	if !d.ClearBreakpoint(line) {
+		fmt.Fprintf(d.out, "%d行目にブレークポイントはありません。\n", line)
+		return
+	}
+	fmt.Fprintf(d.out, "%d行目のブレークポイントを消しました。\n", line)
+}
+
+func (d *Debugger) printBacktrace() {
+	frames := d.Frames()
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		fmt.Fprintf(d.out, "#%d %s %d行目\n", len(frames)-1-i, f.Name, f.Line)
+	}
+}
+
+// 内側の環境から順に変数を表示する。関数とモジュールは表示しない
+func (d *Debugger) printVariables() {
+	for env := d.top().Env; env != nil; env = env.Outer() {
+		label := "局所変数"
+		if env.Outer() == nil {
+			label = "大域変数"
+		}
+		vars := []object.Variable{}
+		for _, v := range env.Variables() {
+			if isShown(v.Value) {
+				vars = append(vars, v)
+			}
+		}
+		if len(vars) == 0 {
+			continue
+		}
+		fmt.Fprintf(d.out, "%s:\n", label)
+		for _, v := range vars {
+			fmt.Fprintf(d.out, "  %s = %s\n", v.Name, v.Value.Inspect())
+		}
+	}
+}
+
+func (d *Debugger) print(src string) {
+	res, err := d.Eval(d.top(), src)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	fmt.Fprintln(d.out, res.Inspect())
+}
@@ -0,0 +1,143 @@
+package debugger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jpl/evaluator"
+	"jpl/object"
+)
+
+const script = `関数 階乗(n) {
+	もし n <= 1 ならば 1 戻す
+	r = n * 階乗(n - 1)
+	r 戻す
+}
+x = 3
+y = 階乗(x)
+表示(y)
+`
+
+func runDebugger(t *testing.T, files map[string]string, commands string) (string, object.Object) {
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out strings.Builder
+	e := evaluator.New()
+	e.Out = &out
+	res := New(e, strings.NewReader(commands), &out).Run(filepath.Join(dir, "main.jpl"), object.NewEnvironment())
+	return out.String(), res
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		commands string
+		expect   string
+	}{
+		// 入力が終われば最後まで実行する
+		{"", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) 
+6
+`},
+		{"b 3\nc\nbt\nl\np n * 10\nc\nd 3\nc\n", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) 3行目にブレークポイントを置きました。
+(jpl) [階乗] 3行目: r = n * 階乗(n - 1)
+(jpl) #0 階乗 3行目
+#1 メイン 7行目
+(jpl) 局所変数:
+  n = 3
+大域変数:
+  x = 3
+(jpl) 30
+(jpl) [階乗] 3行目: r = n * 階乗(n - 1)
+(jpl) 3行目のブレークポイントを消しました。
+(jpl) 6
+`},
+		// s は関数の中に入り、n は呼び出しを一度に実行し、o は呼び出し元まで進む
+		{"n\nn\ns\ns\ns\ns\nn\no\nl\nc\n", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) [メイン] 6行目: x = 3
+(jpl) [メイン] 7行目: y = 階乗(x)
+(jpl) [階乗] 2行目: もし n <= 1 ならば 1 戻す
+(jpl) [階乗] 3行目: r = n * 階乗(n - 1)
+(jpl) [階乗] 2行目: もし n <= 1 ならば 1 戻す
+(jpl) [階乗] 3行目: r = n * 階乗(n - 1)
+(jpl) [階乗] 4行目: r 戻す
+(jpl) [階乗] 4行目: r 戻す
+(jpl) 局所変数:
+  n = 3
+  r = 6
+大域変数:
+  x = 3
+(jpl) 6
+`},
+		// 空行は前のコマンドを繰り返す
+		{"n\n\n\nc\n", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) [メイン] 6行目: x = 3
+(jpl) [メイン] 7行目: y = 階乗(x)
+(jpl) [メイン] 8行目: 表示(y)
+(jpl) 6
+`},
+		// 関数の中で止まっていても、評価した式の呼び出しは最後まで実行する
+		{"b 3\nc\np 階乗(2) 戻す\nq\n", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) 3行目にブレークポイントを置きました。
+(jpl) [階乗] 3行目: r = n * 階乗(n - 1)
+(jpl) 2
+(jpl) `},
+		{"b 5\nb 1\nb\nx\np )\nq\n", `[メイン] 1行目: 関数 階乗(n) {
+(jpl) 5行目には止まれる文がありません。
+(jpl) 1行目にブレークポイントを置きました。
+(jpl) 1行目: 関数 階乗(n) {
+(jpl) 「x」というコマンドはありません。h で一覧を表示します。
+(jpl) 整数ではありません。 取得した文字=)
+(jpl) `},
+	}
+
+	for i, v := range tests {
+		out, _ := runDebugger(t, map[string]string{"main.jpl": script}, v.commands)
+		if out != v.expect {
+			t.Fatalf("test%d : got=\n%s\nexpect=\n%s\n", i, out, v.expect)
+		}
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	_, res := runDebugger(t, map[string]string{"main.jpl": script}, "q\n")
+	if val := res.Inspect(); val != "Error:デバッガで実行を止めました。" {
+		t.Fatalf("got=%s\n", val)
+	}
+}
+
+// 読み込んだモジュールの関数の中にも入れる。ブレークポイントは実行するファイルの行にだけ効く
+func TestDebuggerModule(t *testing.T) {
+	files := map[string]string{
+		"main.jpl": `読み込む "./lib.jpl"
+a = lib.倍(2)
+a`,
+		"lib.jpl": `関数 倍(x) {
+	y = x * 2
+	y 戻す
+}`,
+	}
+	out, res := runDebugger(t, files, "b 2\nc\ns\nl\nbt\nc\n")
+	expect := `[メイン] 1行目: 読み込む "./lib.jpl"
+(jpl) 2行目にブレークポイントを置きました。
+(jpl) [メイン] 2行目: a = lib.倍(2)
+(jpl) [倍] 2行目 (読み込んだモジュール): y = (x * 2)
+(jpl) 局所変数:
+  x = 2
+(jpl) #0 倍 2行目
+#1 メイン 2行目
+(jpl) `
+	if out != expect {
+		t.Fatalf("got=\n%s\nexpect=\n%s\n", out, expect)
+	}
+	if val := res.Inspect(); val != "4" {
+		t.Fatalf("got=%s expect=4\n", val)
+	}
+}
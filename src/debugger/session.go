@@ -0,0 +1,391 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"jpl/ast"
+	"jpl/evaluator"
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+// 次にどこで止まるか
+type Mode int
+
+const (
+	Continue Mode = iota // ブレークポイントまで止まらない
+	StepIn               // 次の文で止まる
+	StepOver             // 今の関数か、その呼び出し元の次の文で止まる
+	StepOut              // 呼び出し元の次の文で止まる
+)
+
+// 止まった理由
+type Reason int
+
+const (
+	StepReason       Reason = iota // SetMode で決めた場所に来た
+	BreakpointReason               // ブレークポイントに来た
+	PauseReason                    // Pause で止めた
+)
+
+// 呼び出し履歴の一段
+type Frame struct {
+	Name string
+	Env  *object.Environment // 今実行している文の環境
+	Line int                 // 今実行している文の行
+	Main bool                // 実行するファイルの文を実行している。偽なら読み込んだモジュールの中
+}
+
+// 評価器の Hook になり、止まる場所と呼び出し履歴を管理する。
+// 止まった時の操作は OnPause に任せるので、端末のデバッガと DAP のサーバで共有できる
+type Session struct {
+	e *evaluator.Evaluator
+
+	// 止まった時に呼ぶ。戻ると実行を続け、エラーを返すと実行を止める
+	OnPause func(node *ast.Node, reason Reason) object.Object
+
+	path      string
+	program   *ast.Program
+	lines     []string           // 実行するファイルの行
+	main      map[*ast.Node]bool // 実行するファイルの文。読み込んだモジュールの文は含まない
+	stops     map[*ast.Node]bool // 止まれる文。同じ並びの中で行の最初にある文
+	breakable map[int]bool       // 止まれる文のある行
+	scanned   map[*ast.Node]bool // 調べたブロック
+
+	frames []*Frame
+	mode   Mode
+	depth  int // 実行を続けた時の呼び出しの深さ
+
+	// 以下は実行中に別のゴルーチンから変えてよい
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pausing     bool // Pause で止めようとしている
+	stopped     bool // Stop で止めた
+}
+
+func NewSession(e *evaluator.Evaluator) *Session {
+	return &Session{
+		e:           e,
+		main:        map[*ast.Node]bool{},
+		stops:       map[*ast.Node]bool{},
+		breakable:   map[int]bool{},
+		scanned:     map[*ast.Node]bool{},
+		breakpoints: map[int]bool{},
+		mode:        StepIn,
+	}
+}
+
+// 実行するファイルを読み込む。ブレークポイントを置く前に呼ぶ
+func (s *Session) Load(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ファイルを開けません。%s", err)
+	}
+	program, errors := parser.Parse(token.Tokenize(string(src)))
+	if len(errors) > 0 {
+		return fmt.Errorf("構文が正しくありません。%s", strings.Join(errors, " "))
+	}
+
+	s.path = path
+	s.program = program
+	s.lines = strings.Split(string(src), "\n")
+	s.scan(program.Nodes, true)
+	return nil
+}
+
+// 読み込んだファイルを実行する。SetMode で決めた場所で止まる。既定では最初の文で止まる
+func (s *Session) Run(env *object.Environment) object.Object {
+	s.frames = []*Frame{{Name: "メイン", Env: env, Main: true}}
+
+	// 最適化すると文が消えたり変わったりするので、書いた通りに実行する
+	hook, noOptimize := s.e.Hook, s.e.NoOptimize
+	s.e.Hook, s.e.NoOptimize = s, true
+	defer func() { s.e.Hook, s.e.NoOptimize = hook, noOptimize }()
+	return s.e.EvalFileProgram(s.program, s.path, env)
+}
+
+// 文の並びを調べて止まれる文を覚える。ブロックと関数の本体の中も調べる
+func (s *Session) scan(stmts []*ast.Node, main bool) {
+	line := 0
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+		if stmt.Line > 0 && stmt.Line != line {
+			s.stops[stmt] = true
+			if main {
+				s.breakable[stmt.Line] = true
+			}
+		}
+		line = stmt.Line
+		if main {
+			s.main[stmt] = true
+		}
+		s.scanNode(stmt, main)
+	}
+}
+
+func (s *Session) scanNode(node *ast.Node, main bool) {
+	if node == nil {
+		return
+	}
+	if node.NodeKind == ast.BLOCK {
+		s.scanned[node] = true
+		s.scan(node.Stmts, main)
+		return
+	}
+	for _, child := range []*ast.Node{node.Lhs, node.Rhs, node.Condition, node.Then, node.Else, node.Body} {
+		s.scanNode(child, main)
+	}
+	for _, param := range node.Params {
+		s.scanNode(param, main)
+	}
+}
+
+func (s *Session) top() *Frame {
+	return s.frames[len(s.frames)-1]
+}
+
+func (s *Session) Statement(node *ast.Node, env *object.Environment) object.Object {
+	top := s.top()
+	top.Env = env
+	top.Main = s.main[node]
+	if node.Line > 0 {
+		top.Line = node.Line
+	}
+
+	s.mu.Lock()
+	stopped := s.stopped
+	breakpoint := s.main[node] && s.breakpoints[node.Line]
+	pausing := s.pausing && s.stops[node]
+	if pausing {
+		s.pausing = false
+	}
+	s.mu.Unlock()
+
+	if stopped {
+		return &object.Error{Message: "デバッガで実行を止めました。"}
+	}
+	if !s.stops[node] {
+		return nil
+	}
+	switch {
+	case breakpoint:
+		return s.OnPause(node, BreakpointReason)
+	case pausing:
+		return s.OnPause(node, PauseReason)
+	case s.shouldStep():
+		return s.OnPause(node, StepReason)
+	default:
+		return nil
+	}
+}
+
+func (s *Session) shouldStep() bool {
+	switch s.mode {
+	case StepIn:
+		return true
+	case StepOver:
+		return len(s.frames) <= s.depth
+	case StepOut:
+		return len(s.frames) < s.depth
+	default:
+		return false
+	}
+}
+
+func (s *Session) EnterFunction(fn *object.Function, env *object.Environment) {
+	// 読み込んだモジュールの関数は呼び出された時に調べる
+	if !s.scanned[fn.Body] {
+		s.scanNode(fn.Body, false)
+	}
+	name := fn.Name
+	if name == "" {
+		name = "(無名関数)"
+	}
+	s.frames = append(s.frames, &Frame{Name: name, Env: env})
+}
+
+func (s *Session) LeaveFunction(fn *object.Function) {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// 次に止まる場所を決める。止まっている時に呼ぶと、今の呼び出しの深さを基準にする
+func (s *Session) SetMode(mode Mode) {
+	s.mode = mode
+	s.depth = len(s.frames)
+}
+
+// 次の止まれる文で止まる
+func (s *Session) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pausing = true
+}
+
+// 次の文で実行を止める
+func (s *Session) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+}
+
+// 呼び出し履歴を返す。最後が一番内側
+func (s *Session) Frames() []*Frame {
+	return s.frames
+}
+
+func (s *Session) Path() string {
+	return s.path
+}
+
+// 実行するファイルの行を返す。前後の空白は除く
+func (s *Session) SourceLine(line int) string {
+	if line < 1 || len(s.lines) < line {
+		return ""
+	}
+	return strings.TrimSpace(s.lines[line-1])
+}
+
+func (s *Session) Lines() []string {
+	return s.lines
+}
+
+// 実行するファイルの文かどうか
+func (s *Session) IsMain(node *ast.Node) bool {
+	return s.main[node]
+}
+
+// 行に止まれる文があるかどうか
+func (s *Session) Breakable(line int) bool {
+	return s.breakable[line]
+}
+
+// ブレークポイントを置く。止まれる文のない行には置かずに偽を返す
+func (s *Session) SetBreakpoint(line int) bool {
+	if !s.breakable[line] {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints[line] = true
+	return true
+}
+
+func (s *Session) ClearBreakpoint(line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.breakpoints[line] {
+		return false
+	}
+	delete(s.breakpoints, line)
+	return true
+}
+
+func (s *Session) ClearBreakpoints() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints = map[int]bool{}
+}
+
+func (s *Session) HasBreakpoint(line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.breakpoints[line]
+}
+
+func (s *Session) Breakpoints() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := []int{}
+	for line := range s.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// 関数とモジュールは変数の一覧に出さない
+func isShown(obj object.Object) bool {
+	switch obj.Type() {
+	case object.FUNCTION, object.BUILTIN, object.MODULE:
+		return false
+	default:
+		return true
+	}
+}
+
+// 段の局所変数を内側から順に返す。同じ名前は内側のものだけ返す
+func (s *Session) Locals(f *Frame) []object.Variable {
+	vars := []object.Variable{}
+	seen := map[string]bool{}
+	for env := f.Env; env != nil && env.Outer() != nil; env = env.Outer() {
+		for _, v := range env.Variables() {
+			if !seen[v.Name] && isShown(v.Value) {
+				seen[v.Name] = true
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}
+
+// 大域変数を返す
+func (s *Session) Globals(f *Frame) []object.Variable {
+	env := f.Env
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	vars := []object.Variable{}
+	for _, v := range env.Variables() {
+		if isShown(v.Value) {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// 段の変数を使って式を評価する。位置で参照する変数は名前で参照できる環境に写す。
+// 式が正しくない時はエラーを返し、実行中のエラーは Error の値で返す
+func (s *Session) Eval(f *Frame, src string) (object.Object, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("式が必要です。")
+	}
+	program, errors := parser.Parse(token.Tokenize(src))
+	if len(errors) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errors, " "))
+	}
+
+	chain := []*object.Environment{}
+	for env := f.Env; env != nil; env = env.Outer() {
+		chain = append(chain, env)
+	}
+	scope := object.NewEnclosedEnvironment(chain[len(chain)-1])
+	for i := len(chain) - 2; i >= 0; i-- {
+		for _, v := range chain[i].Variables() {
+			scope.Define(v.Name, v.Value)
+		}
+	}
+
+	// 評価している間は止まらない
+	hook := s.e.Hook
+	s.e.Hook = nil
+	defer func() { s.e.Hook = hook }()
+
+	var res object.Object = &object.Null{}
+	for _, node := range program.Nodes {
+		res = s.e.Eval(node, scope)
+		if returnValue, ok := res.(*object.ReturnValue); ok {
+			return returnValue.Value, nil
+		}
+		if res.Type() == object.ERROR {
+			break
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"jpl/ast"
+	"jpl/object"
+	"jpl/vm"
+)
+
+// プログラムを実行する方法
+type Backend int
+
+const (
+	TreeWalker Backend = iota // 構文木をそのまま評価する
+	VM                        // バイトコードにコンパイルして仮想機械で実行する
+)
+
+func (e *Evaluator) machine() *vm.VM {
+	if e.vm == nil {
+		e.vm = vm.New(evaluatorRuntime{e})
+	}
+	e.vm.MaxDepth = e.maxDepth()
+	return e.vm
+}
+
+// 仮想機械や最適化から評価器の処理を呼ぶための型
+type evaluatorRuntime struct {
+	e *Evaluator
+}
+
+func (r evaluatorRuntime) LookUp(name string, env *object.Environment) (object.Object, bool) {
+	return r.e.lookUp(name, env)
+}
+
+func (r evaluatorRuntime) Callee(name string, binding *ast.Binding, module object.Object, env *object.Environment) object.Object {
+	return r.e.lookUpCallee(name, binding, module, env)
+}
+
+func (r evaluatorRuntime) Bind(fn *object.Function, args []object.Object, named map[string]object.Object, particles []vm.Particle) (*object.Environment, object.Object) {
+	if len(particles) > 0 {
+		if named == nil {
+			named = map[string]object.Object{}
+		}
+		converted := make([]particleArg, len(particles))
+		for i, v := range particles {
+			converted[i] = particleArg{particle: v.Particle, value: v.Value}
+		}
+		particleArgs, err := bindParticles(fn, converted, named)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, particleArgs...)
+	}
+	return r.e.bindArguments(fn, args, named)
+}
+
+func (r evaluatorRuntime) Infix(op ast.NodeKind, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(op, left, right)
+}
+
+func (r evaluatorRuntime) Step() object.Object {
+	if err := r.e.step(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r evaluatorRuntime) Allocate(obj object.Object) object.Object {
+	return r.e.allocate(obj)
+}
+
+func (r evaluatorRuntime) Arithmetic(op ast.NodeKind, left object.Object, right object.Object) object.Object {
+	return evalNumberExpression(op, left, right)
+}
+
+func (r evaluatorRuntime) IsTruthy(obj object.Object) bool {
+	return isTruthly(obj)
+}
+
+func (r evaluatorRuntime) Index(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+func (r evaluatorRuntime) SetIndex(left object.Object, index object.Object, val object.Object) object.Object {
+	return setIndex(left, index, val)
+}
+
+func (r evaluatorRuntime) Destructure(targets []*ast.Node, val object.Object) ([]object.Object, object.Object) {
+	return splitValues(targets, val)
+}
+
+func (r evaluatorRuntime) Import(name string, env *object.Environment) object.Object {
+	return r.e.importModule(name, env)
+}
+
+func (r evaluatorRuntime) Member(module object.Object, name string) object.Object {
+	return member(module, name)
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"testing"
+
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+const benchmarkFib = `
+関数 フィボナッチ(n) {
+	もし n < 2 ならば n 戻す
+	フィボナッチ(n - 1) + フィボナッチ(n - 2) 戻す
+}
+フィボナッチ(20)
+`
+
+const benchmarkLoop = `
+合計 = 0
+i = 0
+i < 100000 ならば 繰り返す {
+	合計 += i * 2
+	i += 1
+}
+合計
+`
+
+func benchmarkProgram(b *testing.B, backend Backend, input string) {
+	program, errors := parser.Parse(token.Tokenize(input))
+	if len(errors) > 0 {
+		b.Fatalf("%v\n", errors)
+	}
+
+	e := New()
+	e.Backend = backend
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if res := e.EvalProgram(program, object.NewEnvironment()); isError(res) {
+			b.Fatalf("%s\n", res.Inspect())
+		}
+	}
+}
+
+func BenchmarkFibTreeWalker(b *testing.B) {
+	benchmarkProgram(b, TreeWalker, benchmarkFib)
+}
+
+func BenchmarkFibVM(b *testing.B) {
+	benchmarkProgram(b, VM, benchmarkFib)
+}
+
+func BenchmarkLoopTreeWalker(b *testing.B) {
+	benchmarkProgram(b, TreeWalker, benchmarkLoop)
+}
+
+func BenchmarkLoopVM(b *testing.B) {
+	benchmarkProgram(b, VM, benchmarkLoop)
+}
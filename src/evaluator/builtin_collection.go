@@ -0,0 +1,313 @@
+package evaluator
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"jpl/object"
+	"jpl/utils"
+)
+
+func (e *Evaluator) collectionBuiltins() map[string]object.Object {
+	return map[string]object.Object{
+		"写像":   newBuiltin("写像", e.builtinMap),
+		"絞り込み": newBuiltin("絞り込み", e.builtinFilter),
+		"畳み込み": newBuiltin("畳み込み", e.builtinReduce),
+		"並べ替え": newBuiltin("並べ替え", e.builtinSort),
+		"全て":   newBuiltin("全て", e.builtinAll),
+		"いずれか": newBuiltin("いずれか", e.builtinAny),
+		"範囲":   newBuiltin("範囲", e.builtinRange),
+		"逆順":   newBuiltin("逆順", builtinReverse),
+	}
+}
+
+func isCallable(obj object.Object) bool {
+	return obj.Type() == object.FUNCTION || obj.Type() == object.BUILTIN
+}
+
+// (配列、関数) の形の引数を取り出す
+func arrayAndFuncArgs(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newArgCountError(name, name+"(配列、関数)")
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newArgTypeError(name, "配列")
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, newArgTypeError(name, "関数")
+	}
+	return array, args[1], nil
+}
+
+// 写像([1、2]、倍) は各要素に関数を適用した新しい配列を返す
+func (e *Evaluator) builtinMap(args ...object.Object) object.Object {
+	array, fn, err := arrayAndFuncArgs("写像", args)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, v := range array.Elements {
+		res := e.Apply(fn, v)
+		if isError(res) {
+			return res
+		}
+		elements = append(elements, res)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 関数が真を返した要素だけを集める
+func (e *Evaluator) builtinFilter(args ...object.Object) object.Object {
+	array, fn, err := arrayAndFuncArgs("絞り込み", args)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, v := range array.Elements {
+		res := e.Apply(fn, v)
+		if isError(res) {
+			return res
+		}
+		if isTruthly(res) {
+			elements = append(elements, v)
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 畳み込み(配列、関数、初期値) は 関数(累積、要素) を順に適用する。初期値を省略すると最初の要素から始める
+func (e *Evaluator) builtinReduce(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newArgCountError("畳み込み", "畳み込み(配列、関数、初期値)")
+	}
+	array, fn, err := arrayAndFuncArgs("畳み込み", args[:2])
+	if err != nil {
+		return err
+	}
+
+	elements := array.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("空の配列を初期値なしで畳み込むことはできません。")
+		}
+		acc, elements = elements[0], elements[1:]
+	}
+
+	for _, v := range elements {
+		acc = e.Apply(fn, acc, v)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// 比較関数を渡さないときの順番。数は数同士、文字列は文字列同士で比べる
+func compareObjects(a object.Object, b object.Object) (int, *object.Error) {
+	if isNumber(a) && isNumber(b) {
+		af, _ := toFloat(a)
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1, nil
+		case af > bf:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	as, ok := a.(*object.String)
+	bs, ok2 := b.(*object.String)
+	if ok && ok2 {
+		switch {
+		case as.Value < bs.Value:
+			return -1, nil
+		case as.Value > bs.Value:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	ad, ok := a.(*object.DateTime)
+	bd, ok2 := b.(*object.DateTime)
+	if ok && ok2 {
+		return ad.Value.Compare(bd.Value), nil
+	}
+	return 0, newError("%sと%sは比べられません。", a.Inspect(), b.Inspect())
+}
+
+// 並べ替え(配列、比較) は並べ替えた新しい配列を返す。
+// 比較(a、b) は a を b より前にするとき真を返すか、負・0・正の整数を返す。同じ順位の要素の順番は保つ
+func (e *Evaluator) builtinSort(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("並べ替え", "並べ替え(配列、比較)")
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newArgTypeError("並べ替え", "配列")
+	}
+	if len(args) == 2 && !isCallable(args[1]) {
+		return newArgTypeError("並べ替え", "関数")
+	}
+
+	elements := append([]object.Object{}, array.Elements...)
+	var sortErr object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		if len(args) == 1 {
+			res, err := compareObjects(elements[i], elements[j])
+			if err != nil {
+				sortErr = err
+			}
+			return res < 0
+		}
+
+		res := e.Apply(args[1], elements[i], elements[j])
+		switch res := res.(type) {
+		case *object.Boolean:
+			return res.Value
+		case *object.Integer:
+			return res.Value < 0
+		case *object.Error:
+			sortErr = res
+		default:
+			sortErr = newError("並べ替えの比較関数は真偽値か整数を返す必要があります。")
+		}
+		return false
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 要素が一つでも want と違う結果になれば止める。全てとは want が真、いずれかとは偽で使う
+func (e *Evaluator) testElements(name string, want bool, args []object.Object) object.Object {
+	array, fn, err := arrayAndFuncArgs(name, args)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range array.Elements {
+		res := e.Apply(fn, v)
+		if isError(res) {
+			return res
+		}
+		if isTruthly(res) != want {
+			return &object.Boolean{Value: !want}
+		}
+	}
+	return &object.Boolean{Value: want}
+}
+
+func (e *Evaluator) builtinAll(args ...object.Object) object.Object {
+	return e.testElements("全て", true, args)
+}
+
+func (e *Evaluator) builtinAny(args ...object.Object) object.Object {
+	return e.testElements("いずれか", false, args)
+}
+
+// 範囲が期限を確かめる間隔
+const rangeChunk = 1 << 16
+
+// 範囲(5) は [0、1、2、3、4] を、範囲(始め、終わり、刻み) は始めから刻みごとに終わりの手前までを返す
+func (e *Evaluator) builtinRange(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newArgCountError("範囲", "範囲(始め=0、終わり、刻み=1)")
+	}
+	nums, err := integerArgs("範囲", args)
+	if err != nil {
+		return err
+	}
+
+	start, end, step := 0, nums[0], 1
+	if len(nums) >= 2 {
+		start, end = nums[0], nums[1]
+	}
+	if len(nums) == 3 {
+		step = nums[2]
+	}
+	if step == 0 {
+		return newError("関数範囲の刻みに0は使えません。")
+	}
+	// 大きな配列は作る前に上限を確かめる
+	n, ok := rangeLength(start, end, step)
+	if !ok {
+		return newError("関数範囲の要素が多すぎます。")
+	}
+	if err := e.reserve(n); err != nil {
+		return err
+	}
+
+	// 終わりと比べると足した時にあふれることがあるので、要素の数だけ繰り返す。
+	// 上限がなくても一度に確保せず、少しずつ作りながら期限を確かめる
+	size := n
+	if size > rangeChunk {
+		size = rangeChunk
+	}
+	elements := make([]object.Object, 0, size)
+	for k, i := 0, start; k < n; k, i = k+1, i+step {
+		if k%rangeChunk == 0 {
+			if err := e.checkContext(); err != nil {
+				return err
+			}
+		}
+		elements = append(elements, &object.Integer{Value: i})
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 要素の数。差や刻みが int に収まらないことがあるので符号なしで数える。
+// 数が int に収まらなければ ok は false になる
+func rangeLength(start, end, step int) (n int, ok bool) {
+	var diff, stride uint64
+	switch {
+	case step > 0 && start < end:
+		diff, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		diff, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0, true
+	}
+	count := (diff-1)/stride + 1
+	if count > math.MaxInt {
+		return 0, false
+	}
+	return int(count), true
+}
+
+// 配列か文字列を逆にする。文字列は見た目の一文字ごとに逆にする
+func builtinReverse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("逆順", "逆順(配列)")
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Array:
+		elements := make([]object.Object, 0, len(arg.Elements))
+		for i := len(arg.Elements) - 1; i >= 0; i-- {
+			elements = append(elements, arg.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		graphemes := utils.Graphemes(arg.Value)
+		var out strings.Builder
+		out.Grow(len(arg.Value))
+		for i := len(graphemes) - 1; i >= 0; i-- {
+			out.WriteString(graphemes[i])
+		}
+		return &object.String{Value: out.String()}
+	default:
+		return newArgTypeError("逆順", "配列か文字列")
+	}
+}
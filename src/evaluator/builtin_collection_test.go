@@ -0,0 +1,131 @@
+package evaluator
+
+import (
+	"io"
+	"testing"
+
+	"jpl/object"
+)
+
+func TestCollectionBuiltins(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		funcs := `
+	関数 倍(x) { 戻す x * 2 }
+	関数 偶数(x) { 戻す x / 2 * 2 == x }
+	関数 和(a、b) { 戻す a + b }
+	関数 降順(a、b) { 戻す b < a }
+	関数 長さ順(a、b) { 戻す a[1] - b[1] }
+	`
+
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"写像([1、2、3]、倍)", "[2, 4, 6]"},
+			{"写像([]、倍)", "[]"},
+			{"写像([1、2]、範囲)", "[[0], [0, 1]]"},
+			{"絞り込み(範囲(10)、偶数)", "[0, 2, 4, 6, 8]"},
+			{"畳み込み([1、2、3、4]、和)", "10"},
+			{"畳み込み([1、2、3]、和、100)", "106"},
+			{"畳み込み([]、和、0)", "0"},
+			{"畳み込み([\"あ\"、\"い\"、\"う\"]、和)", "あいう"},
+			{"並べ替え([3、1.5、2])", "[1.5, 2, 3]"},
+			{"並べ替え([\"う\"、\"あ\"、\"い\"])", "[あ, い, う]"},
+			{"並べ替え([3、1、2]、降順)", "[3, 2, 1]"},
+			{"並べ替え([[\"a\"、2]、[\"b\"、1]、[\"c\"、2]、[\"d\"、1]]、長さ順)", "[[b, 1], [d, 1], [a, 2], [c, 2]]"},
+			{"a = [3、1、2] 並べ替え(a) a", "[3, 1, 2]"},
+			{"全て([2、4]、偶数)", "true"},
+			{"全て([2、3]、偶数)", "false"},
+			{"全て([]、偶数)", "true"},
+			{"いずれか([1、4]、偶数)", "true"},
+			{"いずれか([1、3]、偶数)", "false"},
+			{"いずれか([]、偶数)", "false"},
+			{"範囲(5)", "[0, 1, 2, 3, 4]"},
+			{"範囲(2、5)", "[2, 3, 4]"},
+			{"範囲(10、0、-3)", "[10, 7, 4, 1]"},
+			{"範囲(5、2)", "[]"},
+			{"範囲(0、9223372036854775807、4611686018427387904)", "[0, 4611686018427387904]"},
+			{"範囲(9223372036854775807、-9223372036854775807 - 1、-9223372036854775807 - 1)", "[9223372036854775807, -1]"},
+			{"逆順([1、2、3])", "[3, 2, 1]"},
+			{"逆順(\"日本🇯🇵\")", "🇯🇵本日"},
+			{"[1、2、3] を 倍 で 写像する", "[2, 4, 6]"},
+			{"写像([[1、2]、[3、4]]、逆順)", "[[2, 1], [4, 3]]"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, funcs+v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestCollectionBuiltinErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		funcs := `
+	関数 倍(x) { 戻す x * 2 }
+	関数 和(a、b) { 戻す a + b }
+	関数 文字(a、b) { 戻す "a" }
+	`
+
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"写像(1、倍)", "Error:関数写像の引数には配列が必要です。"},
+			{"写像([1]、1)", "Error:関数写像の引数には関数が必要です。"},
+			{"写像([1])", "Error:関数写像の引数の個数が正しくありません。期待される形式: 写像(配列、関数)"},
+			{"写像([1、\"a\"]、倍)", "Error:数値が必要です。"},
+			{"写像([1]、和)", "Error:関数和の引数bが指定されていません。期待される形式: 和(a, b)"},
+			{"畳み込み([]、和)", "Error:空の配列を初期値なしで畳み込むことはできません。"},
+			{"並べ替え([1、\"a\"])", "Error:aと1は比べられません。"},
+			{"並べ替え([1、2]、文字)", "Error:並べ替えの比較関数は真偽値か整数を返す必要があります。"},
+			{"範囲(1、5、0)", "Error:関数範囲の刻みに0は使えません。"},
+			{"範囲(-9223372036854775807 - 1、9223372036854775807)", "Error:関数範囲の要素が多すぎます。"},
+			{"範囲(\"a\")", "Error:関数範囲の引数には整数が必要です。"},
+			{"逆順(1)", "Error:関数逆順の引数には配列か文字列が必要です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, funcs+v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		e := New()
+		fn := testEvalWith(t, e, "関数 足す(a、b=10) { 戻す a + b } 足す")
+
+		tests := []struct {
+			fn     string
+			args   []int
+			expect string
+		}{
+			{"足す", []int{1, 2}, "3"},
+			{"足す", []int{1}, "11"},
+			{"表示", []int{}, "null"},
+		}
+
+		for i, v := range tests {
+			f := fn
+			if v.fn == "表示" {
+				f = e.builtins["表示"]
+				e.Out = io.Discard
+			}
+			args := []object.Object{}
+			for _, a := range v.args {
+				args = append(args, &object.Integer{Value: a})
+			}
+			if res := e.Apply(f, args...).Inspect(); res != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, res, v.expect)
+			}
+		}
+
+		if res := e.Apply(&object.Integer{Value: 1}).Inspect(); res != "Error:1は関数ではありません。" {
+			t.Fatalf("got=%s\n", res)
+		}
+	})
+}
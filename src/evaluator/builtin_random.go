@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"math"
+	"math/rand"
+
+	"jpl/object"
+)
+
+// 乱数の種を決める。同じ種からは同じ乱数が同じ順番で出る
+func (e *Evaluator) Seed(seed int64) {
+	e.rand = rand.New(rand.NewSource(seed))
+}
+
+// 乱数(最小、最大) は最小以上最大以下の整数を返す
+func (e *Evaluator) builtinRandom(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newArgCountError("乱数", "乱数(最小、最大)")
+	}
+	nums, err := integerArgs("乱数", args)
+	if err != nil {
+		return err
+	}
+	if nums[0] > nums[1] {
+		return newError("関数乱数の最小(%d)が最大(%d)より大きいです。", nums[0], nums[1])
+	}
+	// 最大-最小は int に収まらないことがあるので、符号なしで数える
+	span := uint64(nums[1]) - uint64(nums[0])
+	if span == math.MaxUint64 {
+		return &object.Integer{Value: int(e.rand.Uint64())}
+	}
+	return &object.Integer{Value: nums[0] + int(e.randUint64n(span+1))}
+}
+
+// 0以上n未満の一様な乱数。int64 に収まらない n では、n 以上の値を捨てて引き直す
+func (e *Evaluator) randUint64n(n uint64) uint64 {
+	if n <= math.MaxInt64 {
+		return uint64(e.rand.Int63n(int64(n)))
+	}
+	for {
+		if v := e.rand.Uint64(); v < n {
+			return v
+		}
+	}
+}
+
+// 乱数小数() は0以上1未満の小数を、乱数小数(最小、最大) は最小以上最大未満の小数を返す
+func (e *Evaluator) builtinRandomFloat(args ...object.Object) object.Object {
+	if len(args) != 0 && len(args) != 2 {
+		return newArgCountError("乱数小数", "乱数小数() または 乱数小数(最小、最大)")
+	}
+	if len(args) == 0 {
+		return &object.Float{Value: e.rand.Float64()}
+	}
+
+	min, ok := toFloat(args[0])
+	max, ok2 := toFloat(args[1])
+	if !ok || !ok2 {
+		return newArgTypeError("乱数小数", "数値")
+	}
+	if min > max {
+		return newError("関数乱数小数の最小(%s)が最大(%s)より大きいです。", args[0].Inspect(), args[1].Inspect())
+	}
+	return &object.Float{Value: min + e.rand.Float64()*(max-min)}
+}
+
+func (e *Evaluator) builtinChoose(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("選ぶ", "選ぶ(配列)")
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newArgTypeError("選ぶ", "配列")
+	}
+	if len(array.Elements) == 0 {
+		return newError("空の配列からは選べません。")
+	}
+	return array.Elements[e.rand.Intn(len(array.Elements))]
+}
+
+// 並べ替えた新しい配列を返す。元の配列は変えない
+func (e *Evaluator) builtinShuffle(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("シャッフル", "シャッフル(配列)")
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newArgTypeError("シャッフル", "配列")
+	}
+
+	elements := append([]object.Object{}, array.Elements...)
+	e.rand.Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+	return &object.Array{Elements: elements}
+}
+
+func (e *Evaluator) builtinSeed(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("乱数の種", "乱数の種(整数)")
+	}
+	nums, err := integerArgs("乱数の種", args)
+	if err != nil {
+		return err
+	}
+	e.Seed(int64(nums[0]))
+	return NULL
+}
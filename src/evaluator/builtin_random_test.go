@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"sort"
+	"testing"
+
+	"jpl/object"
+)
+
+func TestRandom(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		e := New()
+		e.Seed(1)
+		for i := 0; i < 100; i++ {
+			res := testEvalWith(t, e, "乱数(-2、2)").(*object.Integer).Value
+			if res < -2 || 2 < res {
+				t.Fatalf("test%d : got=%d expect -2..2\n", i, res)
+			}
+			f := testEvalWith(t, e, "乱数小数(1、1.5)").(*object.Float).Value
+			if f < 1 || 1.5 <= f {
+				t.Fatalf("test%d : got=%f expect 1..1.5\n", i, f)
+			}
+		}
+
+		// 最大-最小が int に収まらなくても止まらない
+		ranges := []struct {
+			min string
+			max string
+		}{
+			{"0", "9223372036854775807"},
+			{"-9223372036854775807", "9223372036854775807"},
+			{"(-9223372036854775807 - 1)", "9223372036854775807"},
+		}
+		for i, v := range ranges {
+			input := "x = 乱数(" + v.min + "、" + v.max + ") (" + v.min + ") <= x なら x <= " + v.max + " でなければ x"
+			for j := 0; j < 20; j++ {
+				if res := testEvalWith(t, e, input).Inspect(); res != "true" {
+					t.Fatalf("range%d : got=%s\n", i, res)
+				}
+			}
+		}
+
+		if res := testEvalWith(t, e, "乱数(3、3)").Inspect(); res != "3" {
+			t.Fatalf("got=%s expect=3\n", res)
+		}
+		if res := testEvalWith(t, e, `選ぶ(["あ"])`).Inspect(); res != "あ" {
+			t.Fatalf("got=%s expect=あ\n", res)
+		}
+	})
+}
+
+func TestRandomSeed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
+	結果 = [乱数(1、100)、乱数(1、100)、乱数小数()、選ぶ([1、2、3、4、5])、シャッフル([1、2、3、4、5、6、7、8])]
+	結果
+	`
+
+		a, b := New(), New()
+		a.Seed(42)
+		b.Seed(42)
+		res := testEvalWith(t, a, input)
+		if isError(res) {
+			t.Fatalf("%s\n", res.Inspect())
+		}
+		resA := res.Inspect()
+		if resB := testEvalWith(t, b, input).Inspect(); resA != resB {
+			t.Fatalf("同じ種なのに結果が違います。 %s %s\n", resA, resB)
+		}
+
+		// 種が違えば結果も違う
+		other := New()
+		other.Seed(43)
+		if resOther := testEvalWith(t, other, input).Inspect(); resA == resOther {
+			t.Fatalf("違う種なのに結果が同じです。 %s\n", resA)
+		}
+
+		// スクリプトから種を決めても同じになる
+		c := New()
+		if resC := testEvalWith(t, c, "乱数の種(42)"+input).Inspect(); resA != resC {
+			t.Fatalf("同じ種なのに結果が違います。 %s %s\n", resA, resC)
+		}
+
+		// 他の評価器が乱数を使っても影響しない
+		d, another := New(), New()
+		d.Seed(42)
+		another.Seed(42)
+		testEvalWith(t, another, "乱数(1、100)")
+		if resD := testEvalWith(t, d, input).Inspect(); resA != resD {
+			t.Fatalf("他の評価器の影響を受けています。 %s %s\n", resA, resD)
+		}
+	})
+}
+
+func TestShuffle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		e := New()
+		e.Seed(7)
+		res := testEvalWith(t, e, `
+	元 = [1、2、3、4、5]
+	混ぜた = シャッフル(元)
+	混ぜた
+	`).(*object.Array)
+
+		values := []int{}
+		for _, v := range res.Elements {
+			values = append(values, v.(*object.Integer).Value)
+		}
+		sort.Ints(values)
+		for i, v := range values {
+			if v != i+1 {
+				t.Fatalf("要素が変わっています。 got=%v\n", values)
+			}
+		}
+
+		if orig := testEvalWith(t, e, `元 = [1、2、3] シャッフル(元) 元`).Inspect(); orig != "[1, 2, 3]" {
+			t.Fatalf("元の配列が変わっています。 got=%s\n", orig)
+		}
+	})
+}
+
+func TestRandomErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"乱数(5、1)", "Error:関数乱数の最小(5)が最大(1)より大きいです。"},
+			{"乱数(1)", "Error:関数乱数の引数の個数が正しくありません。期待される形式: 乱数(最小、最大)"},
+			{"乱数(1、2.5)", "Error:関数乱数の引数には整数が必要です。"},
+			{"乱数小数(2、1)", "Error:関数乱数小数の最小(2)が最大(1)より大きいです。"},
+			{"乱数小数(1)", "Error:関数乱数小数の引数の個数が正しくありません。期待される形式: 乱数小数() または 乱数小数(最小、最大)"},
+			{"選ぶ([])", "Error:空の配列からは選べません。"},
+			{"選ぶ(1)", "Error:関数選ぶの引数には配列が必要です。"},
+			{"シャッフル(\"abc\")", "Error:関数シャッフルの引数には配列が必要です。"},
+			{"乱数の種(\"a\")", "Error:関数乱数の種の引数には整数が必要です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"jpl/object"
+)
+
+// 標準モジュール。読み込む "数学" のように名前だけで読み込める
+var stdModules = map[string]func(e *Evaluator) map[string]object.Object{
+	"数学":   (*Evaluator).mathModule,
+	"文字列":  (*Evaluator).stringModule,
+	"ファイル": (*Evaluator).fileModule,
+	"日時":   (*Evaluator).dateTimeModule,
+	"正規表現": (*Evaluator).regexpModule,
+}
+
+func (e *Evaluator) newBuiltins() map[string]object.Object {
+	builtins := map[string]object.Object{
+		"表示":     newBuiltin("表示", e.builtinPrint),
+		"JSON解析": newBuiltin("JSON解析", builtinJSONParse),
+		"JSON化":  newBuiltin("JSON化", builtinJSONStringify),
+		"乱数":     newBuiltin("乱数", e.builtinRandom),
+		"乱数小数":   newBuiltin("乱数小数", e.builtinRandomFloat),
+		"選ぶ":     newBuiltin("選ぶ", e.builtinChoose),
+		"シャッフル":  newBuiltin("シャッフル", e.builtinShuffle),
+		"乱数の種":   newBuiltin("乱数の種", e.builtinSeed),
+	}
+	for k, v := range e.collectionBuiltins() {
+		builtins[k] = v
+	}
+	return builtins
+}
+
+// 組み込み関数を加える。同じ名前があれば置き換える
+func (e *Evaluator) Register(name string, fn object.BuiltinFunction) {
+	e.builtins[name] = newBuiltin(name, fn)
+}
+
+// 組み込み関数の名前を並べて返す
+func (e *Evaluator) BuiltinNames() []string {
+	names := []string{}
+	for name := range e.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 名前だけで読み込める標準モジュールの名前を並べて返す
+func StdModuleNames() []string {
+	names := []string{}
+	for name := range stdModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newBuiltin(name string, fn object.BuiltinFunction) *object.Builtin {
+	return &object.Builtin{Name: name, Fn: fn}
+}
+
+func newArgCountError(name string, signature string) *object.Error {
+	return newError("関数%sの引数の個数が正しくありません。期待される形式: %s", name, signature)
+}
+
+func newArgTypeError(name string, want string) *object.Error {
+	return newError("関数%sの引数には%sが必要です。", name, want)
+}
+
+func integerArgs(name string, args []object.Object) ([]int, *object.Error) {
+	res := []int{}
+	for _, v := range args {
+		integer, ok := v.(*object.Integer)
+		if !ok {
+			return nil, newArgTypeError(name, "整数")
+		}
+		res = append(res, integer.Value)
+	}
+	return res, nil
+}
+
+func (e *Evaluator) stdModule(name string) (*object.Module, bool) {
+	if module, ok := e.modules[name]; ok {
+		return module, true
+	}
+
+	build, ok := stdModules[name]
+	if !ok {
+		return nil, false
+	}
+
+	module := &object.Module{Name: name, Env: object.NewEnvironment()}
+	for k, v := range build(e) {
+		module.Env.Define(k, v)
+	}
+	e.modules[name] = module
+	return module, true
+}
+
+// 引数を空白で区切って一行に出力する
+func (e *Evaluator) builtinPrint(args ...object.Object) object.Object {
+	strs := []string{}
+	for _, v := range args {
+		strs = append(strs, v.Inspect())
+	}
+
+	fmt.Fprintln(e.Out, strings.Join(strs, " "))
+	return NULL
+}
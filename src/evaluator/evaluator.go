@@ -1,16 +1,76 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"jpl/ast"
 	"jpl/object"
+	"jpl/vm"
 )
 
 var (
 	NULL = &object.Null{}
 )
 
+type Evaluator struct {
+	// 読み込むモジュールを探すディレクトリ
+	SearchPath []string
+	// 表示の出力先
+	Out io.Writer
+	// 実行する前に見つかった問題の出力先。nil なら出力しない
+	Warnings io.Writer
+	// ファイルモジュールの権限
+	Files FilePolicy
+	// ファイルモジュールで使える文字コード。UTF-8以外は組み込む側で登録する
+	Encodings map[string]Encoding
+	// 日時モジュールが現在時刻を得る関数。テストでは固定の時刻を返すものに差し替える
+	Clock func() time.Time
+	// プログラムを実行する方法。既定では構文木を評価する
+	Backend Backend
+	// 実行する前に構文木を最適化しない。最適化の不具合を切り分ける時に使う
+	NoOptimize bool
+	// 実行の上限。0の項目は制限しない
+	Limits Limits
+	// 実行を見張る。設定すると Backend によらず構文木を評価して実行する
+	Hook Hook
+
+	modules map[string]*object.Module // 読み込み済みのモジュール。絶対パスをキーにする
+	loading []string // 読み込み中のファイル。循環の検出と相対パスの解決に使う
+	builtins map[string]object.Object
+	rand *rand.Rand // 乱数の生成器。評価器ごとに持つ
+	regexps map[string]*compiledRegexp // コンパイル済みの正規表現
+	vm *vm.VM // Backend が VM の時に使う。初めて使う時に作る
+	depth int // 今の関数呼び出しの深さ
+	ctx context.Context // EvalProgramContext で渡された実行の期限。なければ nil
+	running int // 実行中の EvalProgram の入れ子の深さ。一番外側で数を数え直す
+	steps int // 実行した繰り返しと関数呼び出しの数
+	allocations int // 作った値の数
+}
+
+func New() *Evaluator {
+	e := &Evaluator{modules: make(map[string]*object.Module), Out: os.Stdout}
+	e.Encodings = map[string]Encoding{"UTF-8": utf8Encoding}
+	e.Clock = time.Now
+	e.regexps = make(map[string]*compiledRegexp)
+	e.Seed(time.Now().UnixNano())
+	e.builtins = e.newBuiltins()
+	return e
+}
+
+var defaultEvaluator = New()
+
+// 既定の評価器で評価する
+func Eval(node *ast.Node, env *object.Environment) object.Object {
+	return defaultEvaluator.Eval(node, env)
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
@@ -28,6 +88,8 @@ func isTruthly(obj object.Object) bool {
 		return obj.(*object.Boolean).Value
 	case object.INTEGER:
 		return obj.(*object.Integer).Value != 0
+	case object.FLOAT:
+		return obj.(*object.Float).Value != 0
 	case object.NULL:
 		return false
 	default:
@@ -51,6 +113,9 @@ func evalIntegerExpression(nodeKind ast.NodeKind, left object.Object, right obje
 	case ast.MUL:
 		return &object.Integer{Value: lval * rval}
 	case ast.DIV:
+		if rval == 0 {
+			return newError("0で割ることはできません。")
+		}
 		return &object.Integer{Value: lval / rval}
 	case ast.EQ:
 		return &object.Boolean{Value: lval == rval}
@@ -65,43 +130,162 @@ func evalIntegerExpression(nodeKind ast.NodeKind, left object.Object, right obje
 	}
 }
 
-func evalIfStatement(node *ast.Node, env *object.Environment) object.Object {
-	condition := Eval(node.Condition, env)
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.FLOAT
+}
+
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// 小数を含む計算。整数は小数に変換してから計算する
+func evalFloatExpression(nodeKind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	lval, lok := toFloat(left)
+	rval, rok := toFloat(right)
+	if !lok || !rok {
+		return newError("数値が必要です。")
+	}
+
+	switch nodeKind {
+	case ast.ADD:
+		return &object.Float{Value: lval + rval}
+	case ast.SUB:
+		return &object.Float{Value: lval - rval}
+	case ast.MUL:
+		return &object.Float{Value: lval * rval}
+	case ast.DIV:
+		if rval == 0 {
+			return newError("0で割ることはできません。")
+		}
+		return &object.Float{Value: lval / rval}
+	case ast.EQ:
+		return &object.Boolean{Value: lval == rval}
+	case ast.NOT_EQ:
+		return &object.Boolean{Value: lval != rval}
+	case ast.GT:
+		return &object.Boolean{Value: lval < rval}
+	case ast.GE:
+		return &object.Boolean{Value: lval <= rval}
+	default:
+		return newError("対応していない演算子です")
+	}
+}
+
+func evalNumberExpression(nodeKind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	if left.Type() == object.FLOAT || right.Type() == object.FLOAT {
+		return evalFloatExpression(nodeKind, left, right)
+	}
+	return evalIntegerExpression(nodeKind, left, right)
+}
+
+func evalDateTimeExpression(nodeKind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	lval := left.(*object.DateTime).Value
+	rval := right.(*object.DateTime).Value
+
+	switch nodeKind {
+	case ast.EQ:
+		return &object.Boolean{Value: lval.Equal(rval)}
+	case ast.NOT_EQ:
+		return &object.Boolean{Value: !lval.Equal(rval)}
+	case ast.GT:
+		return &object.Boolean{Value: lval.Before(rval)}
+	case ast.GE:
+		return &object.Boolean{Value: !lval.After(rval)}
+	default:
+		return newError("日時に使えない演算子です。日時の計算には日時.足すと日時.差を使ってください。")
+	}
+}
+
+func evalStringExpression(nodeKind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	lval := left.(*object.String).Value
+	rval := right.(*object.String).Value
+
+	switch nodeKind {
+	case ast.ADD:
+		return &object.String{Value: lval + rval}
+	case ast.EQ:
+		return &object.Boolean{Value: lval == rval}
+	case ast.NOT_EQ:
+		return &object.Boolean{Value: lval != rval}
+	default:
+		return newError("文字列に使えない演算子です。")
+	}
+}
+
+func (e *Evaluator) evalIfStatement(node *ast.Node, env *object.Environment) object.Object {
+	condition := e.Eval(node.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthly(condition) {
-		return Eval(node.Then, env)
+		return e.Eval(node.Then, env)
 	} else if node.Else != nil {
-		return Eval(node.Else, env)
+		return e.Eval(node.Else, env)
 	}
 	return NULL
 }
 
-func evalForStatement(node *ast.Node, env *object.Environment) object.Object {
-	var fnode object.Object
+func (e *Evaluator) evalTernaryExpression(node *ast.Node, env *object.Environment) object.Object {
+	condition := e.Eval(node.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthly(condition) {
+		return e.Eval(node.Then, env)
+	}
+	return e.Eval(node.Else, env)
+}
+
+// 繰り返しの値は最後に実行した本体の値。一度も実行しなければ null になる
+func (e *Evaluator) evalForStatement(node *ast.Node, env *object.Environment) object.Object {
+	var res object.Object = NULL
 
 	for {
-		condition := Eval(node.Condition, env)
+		condition := e.Eval(node.Condition, env)
 		if isError(condition) {
 			return condition
 		}
+		if !isTruthly(condition) {
+			return res
+		}
+		if err := e.step(); err != nil {
+			return err
+		}
 
-		if isTruthly(condition) {
-			fnode = Eval(node.Then, env)
-		} else {
-			return fnode
+		res = e.Eval(node.Then, env)
+		if rt := res.Type(); rt == object.RETURN_VALUE || rt == object.ERROR {
+			return res
 		}
 	}
 }
 
-func evalBlock(node *ast.Node, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlock(node *ast.Node, env *object.Environment) object.Object {
+	blockEnv := object.NewScopeEnvironment(env, node.Locals)
+	if e.Hook != nil {
+		blockEnv.SetNames(node.Names)
+	}
+	return e.evalStmts(node.Stmts, blockEnv)
+}
+
+func (e *Evaluator) evalStmts(stmts []*ast.Node, env *object.Environment) object.Object {
 	var res object.Object
-	blockEnv := object.NewEnclosedEnvironment(env)
 
-	for _, stmt:= range node.Stmts {
-		res = Eval(stmt, blockEnv)
+	for _, stmt:= range stmts {
+		if e.Hook != nil {
+			if err := e.Hook.Statement(stmt, env); err != nil {
+				return err
+			}
+		}
+		res = e.Eval(stmt, env)
 
 		if res == nil {
 			continue
@@ -119,74 +303,680 @@ func evalBlock(node *ast.Node, env *object.Environment) object.Object {
 	return res
 }
 
-func genFuncObj(node *ast.Node, env *object.Environment) object.Object {
-	funcObj := &object.Function{}
-	funcObj.Env = object.NewEnclosedEnvironment(env)
+// 変数を探す。解決済みなら位置で、そうでなければ名前で探す
+func getVar(node *ast.Node, env *object.Environment) (object.Object, bool) {
+	if node.Binding != nil {
+		return env.GetAt(node.Binding.Depth, node.Binding.Slot)
+	}
+	return env.Get(node.Ident)
+}
 
-	for _, v := range node.Params {
-		funcObj.Params = append(funcObj.Params, v)
-		funcObj.Env.Set(v.Ident, NULL)
+func setVar(node *ast.Node, env *object.Environment, val object.Object) {
+	if node.Binding != nil {
+		env.SetAt(node.Binding.Depth, node.Binding.Slot, val)
+		return
 	}
+	env.Set(node.Ident, val)
+}
 
+// 仮引数を呼び出しごとの環境に定義する
+func defineParam(ident *ast.Node, env *object.Environment, val object.Object) {
+	if ident.Binding != nil {
+		env.SetAt(ident.Binding.Depth, ident.Binding.Slot, val)
+		return
+	}
+	env.Define(ident.Ident, val)
+}
+
+func genFuncObj(node *ast.Node, env *object.Environment) object.Object {
+	funcObj := &object.Function{}
+	funcObj.Name = node.Ident
+	funcObj.Env = env
+	funcObj.Params = append(funcObj.Params, node.Params...)
 	funcObj.Body = node.Body
 	return funcObj
 }
 
-func evalCallFunc(node *ast.Node, env *object.Environment) object.Object {
-	obj, ok := env.Get(node.Ident)
-	if !ok || obj.Type() != object.FUNCTION {
+// 実引数を仮引数に束縛した、呼び出しごとの環境を作る
+func (e *Evaluator) bindArguments(fn *object.Function, args []object.Object, named map[string]object.Object) (*object.Environment, object.Object) {
+	callEnv := object.NewScopeEnvironment(fn.Env, fn.Body.Locals)
+
+	// 知らない名前がいくつあっても同じ名前を報告するよう、名前の順に調べる
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		found := false
+		for _, param := range fn.Params {
+			if param.NodeKind == ast.IDENT && param.Ident == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, newError("関数%sに引数%sはありません。期待される形式: %s", fn.Name, name, fn.Signature())
+		}
+	}
+
+	pos := 0
+	for _, param := range fn.Params {
+		if param.NodeKind == ast.REST {
+			rest := &object.Array{Elements: []object.Object{}}
+			rest.Elements = append(rest.Elements, args[pos:]...)
+			pos = len(args)
+			defineParam(param.Lhs, callEnv, rest)
+			continue
+		}
+
+		val, isNamed := named[param.Ident]
+		if pos < len(args) {
+			if isNamed {
+				return nil, newError("関数%sの引数%sが重複して指定されています。", fn.Name, param.Ident)
+			}
+			val = args[pos]
+			pos++
+		} else if !isNamed {
+			if param.Rhs == nil {
+				return nil, newError("関数%sの引数%sが指定されていません。期待される形式: %s", fn.Name, param.Ident, fn.Signature())
+			}
+			// 既定値は呼び出しごとに評価するので、前の引数を参照できる
+			val = e.Eval(param.Rhs, callEnv)
+			if isError(val) {
+				return nil, val
+			}
+		}
+		defineParam(param, callEnv, val)
+	}
+
+	if pos < len(args) {
+		return nil, newError("関数%sの引数の個数が正しくありません。期待される形式: %s", fn.Name, fn.Signature())
+	}
+
+	return callEnv, nil
+}
+
+// 末尾呼び出しはGoのスタックを伸ばさずに、同じループの中で次の関数を実行する
+func (e *Evaluator) applyFunction(fn *object.Function, args []object.Object, named map[string]object.Object) object.Object {
+	if e.depth >= e.maxDepth() {
+		return newLimitError("再帰が深すぎます。")
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	for {
+		if err := e.step(); err != nil {
+			return err
+		}
+		callEnv, err := e.bindArguments(fn, args, named)
+		if err != nil {
+			return err
+		}
+
+		// 本体のブロックは呼び出しごとの環境でそのまま実行する
+		var res object.Object
+		if e.Hook != nil {
+			callEnv.SetNames(fn.Body.Names)
+			e.Hook.EnterFunction(fn, callEnv)
+			res = e.evalStmts(fn.Body.Stmts, callEnv)
+			e.Hook.LeaveFunction(fn)
+		} else {
+			res = e.evalStmts(fn.Body.Stmts, callEnv)
+		}
+		returnValue, ok := res.(*object.ReturnValue)
+		if !ok {
+			return res
+		}
+		call, ok := returnValue.Value.(*tailCall)
+		if !ok {
+			return returnValue.Value
+		}
+		fn, args, named = call.fn, call.args, call.named
+	}
+}
+
+// 「f(x) 戻す」のように戻す値が関数呼び出しの時、呼び出す前の関数と引数を呼び出し元に返す
+type tailCall struct {
+	fn    *object.Function
+	args  []object.Object
+	named map[string]object.Object
+}
+
+func (t *tailCall) Type() object.ObjectType {
+	return "TAIL_CALL"
+}
+
+func (t *tailCall) Inspect() string {
+	return t.fn.Inspect()
+}
+
+// 関数オブジェクトを呼び出す。組み込み関数からスクリプトの関数を呼ぶときに使う
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	if e.Backend == VM && e.Hook == nil {
+		return e.machine().Call(fn, args)
+	}
+	switch fn := fn.(type) {
+	case *object.Function:
+		return e.applyFunction(fn, args, nil)
+	case *object.Builtin:
+		return fn.Fn(args...)
+	default:
+		return newError("%sは関数ではありません。", fn.Inspect())
+	}
+}
+
+type particleArg struct {
+	particle string
+	value    object.Object
+}
+
+// 助詞の付いた引数を、同じ助詞を持つ仮引数に名前付き引数として渡す。
+// 助詞を持つ仮引数がない関数には、書かれた順に位置で指定する引数として渡す
+func bindParticles(fn *object.Function, particles []particleArg, named map[string]object.Object) ([]object.Object, object.Object) {
+	hasParticle := false
+	for _, param := range fn.Params {
+		if param.Particle != "" {
+			hasParticle = true
+			break
+		}
+	}
+
+	args := []object.Object{}
+	for _, arg := range particles {
+		if !hasParticle {
+			args = append(args, arg.value)
+			continue
+		}
+
+		var param *ast.Node
+		for _, v := range fn.Params {
+			if v.Particle == arg.particle {
+				param = v
+				break
+			}
+		}
+		if param == nil {
+			return nil, newError("関数%sには助詞「%s」で受け取る引数がありません。期待される形式: %s", fn.Name, arg.particle, fn.Signature())
+		}
+		if _, ok := named[param.Ident]; ok {
+			return nil, newError("関数%sの助詞「%s」が重複しています。", fn.Name, arg.particle)
+		}
+		named[param.Ident] = arg.value
+	}
+
+	return args, nil
+}
+
+// 変数を探す。環境になければ組み込み関数から探す
+func (e *Evaluator) lookUp(name string, env *object.Environment) (object.Object, bool) {
+	if obj, ok := env.Get(name); ok {
+		return obj, true
+	}
+	obj, ok := e.builtins[name]
+	return obj, ok
+}
+
+// 関数を探す。見つからなければ「表示する」のような動詞の形から「する」を除いた名前でも探す
+func lookUpFunc(name string, get func(string) (object.Object, bool)) (object.Object, bool) {
+	if obj, ok := get(name); ok {
+		return obj, true
+	}
+	if stem := strings.TrimSuffix(name, "する"); stem != name && stem != "" {
+		return get(stem)
+	}
+	return nil, false
+}
+
+// 呼び出す関数を探す。module が nil でなければモジュールの中から探す
+func (e *Evaluator) lookUpCallee(name string, binding *ast.Binding, module object.Object, env *object.Environment) object.Object {
+	var obj object.Object
+	var ok bool
+	if binding != nil {
+		obj, ok = env.GetAt(binding.Depth, binding.Slot)
+	} else if module != nil {
+		m, isModule := module.(*object.Module)
+		if !isModule {
+			return newError("\".\"はモジュールにしか使えません。")
+		}
+		obj, ok = lookUpFunc(name, m.Get)
+	} else {
+		obj, ok = lookUpFunc(name, func(name string) (object.Object, bool) {
+			return e.lookUp(name, env)
+		})
+	}
+	if !ok || (obj.Type() != object.FUNCTION && obj.Type() != object.BUILTIN) {
 		return newError("関数が宣言されていません。")
 	}
-	if len(obj.(*object.Function).Params) != len(node.Params) {
-		return newError("引数の個数が正しくありません。")
+	return obj
+}
+
+// tail が真なら、スクリプトの関数は呼び出さずに tailCall を返す
+func (e *Evaluator) evalCallFunc(node *ast.Node, env *object.Environment, tail bool) object.Object {
+	var module object.Object
+	if node.Lhs != nil {
+		module = e.Eval(node.Lhs, env)
+		if isError(module) {
+			return module
+		}
+	}
+	obj := e.lookUpCallee(node.Ident, node.Binding, module, env)
+	if isError(obj) {
+		return obj
+	}
+
+	args := []object.Object{}
+	named := map[string]object.Object{}
+	particles := []particleArg{}
+	for _, v := range node.Params {
+		switch v.NodeKind {
+		case ast.PAIR:
+			if _, ok := named[v.Lhs.Ident]; ok {
+				return newError("引数%sが重複して指定されています。", v.Lhs.Ident)
+			}
+			arg := e.Eval(v.Rhs, env)
+			if isError(arg) {
+				return arg
+			}
+			named[v.Lhs.Ident] = arg
+		case ast.PARTICLE:
+			arg := e.Eval(v.Lhs, env)
+			if isError(arg) {
+				return arg
+			}
+			particles = append(particles, particleArg{particle: v.Ident, value: arg})
+		default:
+			arg := e.Eval(v, env)
+			if isError(arg) {
+				return arg
+			}
+			args = append(args, arg)
+		}
+	}
+
+	if builtin, ok := obj.(*object.Builtin); ok {
+		if len(named) > 0 {
+			return newError("組み込み関数%sには名前付き引数を使えません。", builtin.Name)
+		}
+		for _, v := range particles {
+			args = append(args, v.value)
+		}
+		return e.allocate(builtin.Fn(args...))
+	}
+
+	fn := obj.(*object.Function)
+	if len(particles) > 0 {
+		particleArgs, err := bindParticles(fn, particles, named)
+		if err != nil {
+			return err
+		}
+		args = append(args, particleArgs...)
+	}
+
+	if tail {
+		return &tailCall{fn: fn, args: args, named: named}
 	}
+	return e.applyFunction(fn, args, named)
+}
+
+func (e *Evaluator) evalArray(node *ast.Node, env *object.Environment) object.Object {
+	array := &object.Array{}
 
-	for i, v := range obj.(*object.Function).Params {
-		p := Eval(node.Params[i], env)
-		if isError(p) {
-			return p
+	for _, v := range node.Params {
+		elem := e.Eval(v, env)
+		if isError(elem) {
+			return elem
 		}
-		obj.(*object.Function).Env.Set(v.Ident, p)
+		array.Elements = append(array.Elements, elem)
 	}
 
-	return Eval(obj.(*object.Function).Body, obj.(*object.Function).Env)
+	return e.allocate(array)
 }
 
-func Eval(node *ast.Node, env *object.Environment) object.Object {
+func (e *Evaluator) evalTuple(node *ast.Node, env *object.Environment) object.Object {
+	tuple := &object.Tuple{}
+
+	for _, v := range node.Params {
+		elem := e.Eval(v, env)
+		if isError(elem) {
+			return elem
+		}
+		tuple.Elements = append(tuple.Elements, elem)
+	}
+
+	return e.allocate(tuple)
+}
+
+func (e *Evaluator) evalHash(node *ast.Node, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Params {
+		key := e.Eval(pair.Lhs, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("ハッシュのキーとして使えません。")
+		}
+
+		value := e.Eval(pair.Rhs, env)
+		if isError(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return e.allocate(hash)
+}
+
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("配列の添字には数値が必要です。")
+		}
+		if i.Value < 0 || len(left.Elements) <= i.Value {
+			return newError("添字が範囲外です。")
+		}
+		return left.Elements[i.Value]
+	case *object.Tuple:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("添字には数値が必要です。")
+		}
+		if i.Value < 0 || len(left.Elements) <= i.Value {
+			return newError("添字が範囲外です。")
+		}
+		return left.Elements[i.Value]
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("ハッシュのキーとして使えません。")
+		}
+		if value, ok := left.Get(key); ok {
+			return value
+		}
+		return NULL
+	default:
+		return newError("添字を使えない値です。")
+	}
+}
+
+func setIndex(left object.Object, index object.Object, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("配列の添字には数値が必要です。")
+		}
+		if i.Value < 0 || len(left.Elements) <= i.Value {
+			return newError("添字が範囲外です。")
+		}
+		left.Elements[i.Value] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("ハッシュのキーとして使えません。")
+		}
+		left.Set(key, val)
+	default:
+		return newError("添字を使えない値です。")
+	}
+	return NULL
+}
+
+func (e *Evaluator) assign(target *ast.Node, val object.Object, env *object.Environment) object.Object {
+	switch target.NodeKind {
+	case ast.IDENT:
+		setVar(target, env, val)
+		return NULL
+	case ast.INDEX:
+		left := e.Eval(target.Lhs, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(target.Rhs, env)
+		if isError(index) {
+			return index
+		}
+		return setIndex(left, index, val)
+	case ast.TUPLE, ast.ARRAY:
+		return e.destructure(target.Params, val, env)
+	default:
+		return newError("代入できない式です。")
+	}
+}
+
+// 配列や複数の値を分解して、それぞれの代入先に代入する
+func (e *Evaluator) destructure(targets []*ast.Node, val object.Object, env *object.Environment) object.Object {
+	values, err := splitValues(targets, val)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range targets {
+		if v.NodeKind == ast.REST {
+			v = v.Lhs
+		}
+		if res := e.assign(v, values[i], env); isError(res) {
+			return res
+		}
+	}
+
+	return NULL
+}
+
+// 分割代入の代入先ごとの値を返す。"…"の付いた代入先には残りの値の配列を返す
+func splitValues(targets []*ast.Node, val object.Object) ([]object.Object, object.Object) {
+	var elems []object.Object
+	switch val := val.(type) {
+	case *object.Tuple:
+		elems = val.Elements
+	case *object.Array:
+		elems = val.Elements
+	default:
+		return nil, newError("分割代入には配列か複数の値が必要です。")
+	}
+
+	rest := -1
+	for i, v := range targets {
+		if v.NodeKind != ast.REST {
+			continue
+		}
+		if rest >= 0 {
+			return nil, newError("\"…\"は分割代入の左辺で一つだけ使えます。")
+		}
+		rest = i
+	}
+
+	if rest < 0 && len(targets) != len(elems) {
+		return nil, newError("値の個数が正しくありません。代入先=%d 値=%d", len(targets), len(elems))
+	}
+	if rest >= 0 && len(elems) < len(targets)-1 {
+		return nil, newError("値の個数が足りません。代入先=%d 値=%d", len(targets)-1, len(elems))
+	}
+
+	values := make([]object.Object, len(targets))
+	for i := range targets {
+		if rest < 0 || i < rest {
+			values[i] = elems[i]
+		} else if i == rest {
+			restElems := []object.Object{}
+			restElems = append(restElems, elems[i:len(elems)-(len(targets)-1-i)]...)
+			values[i] = &object.Array{Elements: restElems}
+		} else {
+			values[i] = elems[len(elems)-(len(targets)-i)]
+		}
+	}
+	return values, nil
+}
+
+var compoundOperators = map[ast.NodeKind]ast.NodeKind{
+	ast.ADD_ASSIGN: ast.ADD,
+	ast.SUB_ASSIGN: ast.SUB,
+	ast.MUL_ASSIGN: ast.MUL,
+	ast.DIV_ASSIGN: ast.DIV,
+}
+
+// 添字の式が二度評価されないように、代入先を一度だけ評価してから演算する
+func (e *Evaluator) evalCompoundAssign(node *ast.Node, env *object.Environment) object.Object {
+	op := compoundOperators[node.NodeKind]
+
+	switch node.Lhs.NodeKind {
+	case ast.IDENT:
+		cur, ok := getVar(node.Lhs, env)
+		if !ok {
+			return newError("変数が宣言されていません")
+		}
+		rhs := e.Eval(node.Rhs, env)
+		if isError(rhs) {
+			return rhs
+		}
+		val := evalNumberExpression(op, cur, rhs)
+		if isError(val) {
+			return val
+		}
+		setVar(node.Lhs, env, val)
+		return NULL
+	case ast.INDEX:
+		left := e.Eval(node.Lhs.Lhs, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(node.Lhs.Rhs, env)
+		if isError(index) {
+			return index
+		}
+		cur := evalIndexExpression(left, index)
+		if isError(cur) {
+			return cur
+		}
+		rhs := e.Eval(node.Rhs, env)
+		if isError(rhs) {
+			return rhs
+		}
+		val := evalNumberExpression(op, cur, rhs)
+		if isError(val) {
+			return val
+		}
+		return setIndex(left, index, val)
+	default:
+		return newError("代入できない式です。")
+	}
+}
+
+func (e *Evaluator) Eval(node *ast.Node, env *object.Environment) object.Object {
 	switch node.NodeKind {
 	case ast.ASSIGN:
-		val := Eval(node.Rhs, env)
-		env.Set(node.Lhs.Ident, val)
-		return NULL
+		val := e.Eval(node.Rhs, env)
+		if isError(val) {
+			return val
+		}
+		return e.assign(node.Lhs, val, env)
+	case ast.ADD_ASSIGN, ast.SUB_ASSIGN, ast.MUL_ASSIGN, ast.DIV_ASSIGN:
+		return e.evalCompoundAssign(node, env)
 	case ast.IDENT:
-		object, ok := env.Get(node.Ident)
+		if node.Binding != nil {
+			val, ok := env.GetAt(node.Binding.Depth, node.Binding.Slot)
+			if !ok {
+				return newError("変数が宣言されていません")
+			}
+			return val
+		}
+		object, ok := e.lookUp(node.Ident, env)
 		if !ok {
 			return newError("変数が宣言されていません")
 		}
 		return object
 	case ast.INTEGER:
 		return &object.Integer{Value: node.Num}
+	case ast.FLOAT:
+		return &object.Float{Value: node.Float}
+	case ast.STRING:
+		return &object.String{Value: node.Str}
 	case ast.RETURN:
-		val := Eval(node.Lhs, env)
+		var val object.Object
+		// 関数の本体の中なら applyFunction が受け取って呼び出す
+		if node.Lhs.NodeKind == ast.CALL && node.InFunction {
+			val = e.evalCallFunc(node.Lhs, env, true)
+		} else {
+			val = e.Eval(node.Lhs, env)
+		}
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case ast.IF:
-		return evalIfStatement(node, env)
+		return e.evalIfStatement(node, env)
 	case ast.FOR:
-		return evalForStatement(node, env)
+		return e.evalForStatement(node, env)
+	case ast.TERNARY:
+		return e.evalTernaryExpression(node, env)
 	case ast.BLOCK:
-		return evalBlock(node, env)
+		return e.evalBlock(node, env)
 	case ast.FUNC:
-		env.Set(node.Ident, genFuncObj(node, env))
+		setVar(node, env, genFuncObj(node, env))
 		return NULL
 	case ast.CALL:
-		return evalCallFunc(node, env)
+		return e.evalCallFunc(node, env, false)
+	case ast.ARRAY:
+		return e.evalArray(node, env)
+	case ast.HASH:
+		return e.evalHash(node, env)
+	case ast.TUPLE:
+		return e.evalTuple(node, env)
+	case ast.REST:
+		return newError("\"…\"は分割代入の左辺でのみ使えます。")
+	case ast.INDEX:
+		left := e.Eval(node.Lhs, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(node.Rhs, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case ast.IMPORT:
+		return e.importModule(node.Str, env)
+	case ast.MEMBER:
+		return e.evalMember(node, env)
+	case ast.NEG:
+		val := e.Eval(node.Lhs, env)
+		if isError(val) {
+			return val
+		}
+		return evalNegation(val)
 	}
 
-	lhs := Eval(node.Lhs, env)
-	rhs := Eval(node.Rhs, env)
+	lhs := e.Eval(node.Lhs, env)
+	if isError(lhs) {
+		return lhs
+	}
+	rhs := e.Eval(node.Rhs, env)
+	if isError(rhs) {
+		return rhs
+	}
 
-	return evalIntegerExpression(node.NodeKind, lhs, rhs)
+	return e.allocate(evalInfixExpression(node.NodeKind, lhs, rhs))
+}
+
+// 「0 - x」と同じ結果になるように符号を反転する
+func evalNegation(val object.Object) object.Object {
+	if integer, ok := val.(*object.Integer); ok {
+		return &object.Integer{Value: -integer.Value}
+	}
+	return evalInfixExpression(ast.SUB, &object.Integer{Value: 0}, val)
+}
+
+func evalInfixExpression(nodeKind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	if left.Type() == object.STRING && right.Type() == object.STRING {
+		return evalStringExpression(nodeKind, left, right)
+	}
+	if left.Type() == object.DATETIME && right.Type() == object.DATETIME {
+		return evalDateTimeExpression(nodeKind, left, right)
+	}
+	return evalNumberExpression(nodeKind, left, right)
 }
@@ -1,159 +1,208 @@
 package evaluator
 
 import (
+	"bytes"
 	"testing"
 
+	"jpl/ast"
 	"jpl/token"
 	"jpl/parser"
 	"jpl/object"
 )
 
-func TestCalc(t *testing.T) {
-	tests := []struct {
-		input string
-		expectNum int
-	} {
-		{"5 + 5", 10},
-		{"５＋１９", 24},
-		{"6 - 3", 3},
-		{"7 * 8", 56},
-		{"９÷３", 3},
-		{"9 * 9 * 0", 0},
-		{"(9 + 9) * 7", 126},
-		{"-9 * (-8)", 72},
+// テストを実行している方法。forEachBackend が方法ごとに設定する
+var testBackend Backend
+
+var backends = []struct {
+	name    string
+	backend Backend
+}{
+	{"TreeWalker", TreeWalker},
+	{"VM", VM},
+}
+
+// 全ての実行方法で f を試す。方法ごとにサブテストにする。
+// 方法は testBackend で共有するので、f の中で t.Parallel は使えない
+func forEachBackend(t *testing.T, f func(t *testing.T, b Backend)) {
+	for _, v := range backends {
+		t.Run(v.name, func(t *testing.T) {
+			prev := testBackend
+			testBackend = v.backend
+			defaultEvaluator.Backend = v.backend
+			defer func() {
+				testBackend = prev
+				defaultEvaluator.Backend = prev
+			}()
+			f(t, v.backend)
+		})
 	}
+}
 
-	for i, v := range tests {
-		head := token.Tokenize(v.input)
-		program, errors := parser.Parse(head)
-		if len(errors) > 0 {
-			t.Fatalf("Error.\n")
+// 文を一つ既定の評価器で評価する。仮想機械ではその文だけのプログラムとして実行する
+func testEvalNode(node *ast.Node, env *object.Environment) object.Object {
+	if testBackend == VM {
+		return defaultEvaluator.machine().Run(&ast.Program{Nodes: []*ast.Node{node}}, env)
+	}
+	return Eval(node, env)
+}
+
+func TestCalc(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expectNum int
+		} {
+			{"5 + 5", 10},
+			{"５＋１９", 24},
+			{"6 - 3", 3},
+			{"7 * 8", 56},
+			{"９÷３", 3},
+			{"9 * 9 * 0", 0},
+			{"(9 + 9) * 7", 126},
+			{"-9 * (-8)", 72},
 		}
 
-		env := object.NewEnvironment()
-		o := Eval(program.Nodes[0], env)
-		if val := o.(*object.Integer).Value; val != v.expectNum {
-			t.Fatalf("test%d : got=%d expect=%d", i, val, v.expectNum)
+		for i, v := range tests {
+			head := token.Tokenize(v.input)
+			program, errors := parser.Parse(head)
+			if len(errors) > 0 {
+				t.Fatalf("Error.\n")
+			}
+
+			env := object.NewEnvironment()
+			o := testEvalNode(program.Nodes[0], env)
+			if val := o.(*object.Integer).Value; val != v.expectNum {
+				t.Fatalf("test%d : got=%d expect=%d", i, val, v.expectNum)
+			}
 		}
-	}
+	})
 }
 
 func TestComparisonOperators(t *testing.T) {
-	tests := []struct {
-		input string
-		expect bool
-	} {
-		{"5 < 7", true},
-		{"5 <= 8", true},
-		{"5 <= 5", true},
-		{"7 > 5", true},
-		{"7 >= 5", true},
-		{"7 >= 7", true},
-		{"7 == 7", true},
-		{"8 != 9", true},
-		{"7 < 5", false},
-		{"5 < 5", false},
-		{"7 <= 5", false},
-		{"5 > 7", false},
-		{"5 > 5", false},
-		{"5 >= 7", false},
-		{"8 == 9", false},
-		{"9 != 9", false},
-	}
-	for i, v := range tests {
-		head := token.Tokenize(v.input)
-		program, errors := parser.Parse(head)
-		if len(errors) > 0 {
-			t.Fatalf("Error.\n")
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect bool
+		} {
+			{"5 < 7", true},
+			{"5 <= 8", true},
+			{"5 <= 5", true},
+			{"7 > 5", true},
+			{"7 >= 5", true},
+			{"7 >= 7", true},
+			{"7 == 7", true},
+			{"8 != 9", true},
+			{"7 < 5", false},
+			{"5 < 5", false},
+			{"7 <= 5", false},
+			{"5 > 7", false},
+			{"5 > 5", false},
+			{"5 >= 7", false},
+			{"8 == 9", false},
+			{"9 != 9", false},
 		}
+		for i, v := range tests {
+			head := token.Tokenize(v.input)
+			program, errors := parser.Parse(head)
+			if len(errors) > 0 {
+				t.Fatalf("Error.\n")
+			}
 
-		env := object.NewEnvironment()
-		o := Eval(program.Nodes[0], env)
-		if val := o.(*object.Boolean).Value; val != v.expect {
-			t.Fatalf("test%d : got=%t expect=%t\n", i, val, v.expect)
+			env := object.NewEnvironment()
+			o := testEvalNode(program.Nodes[0], env)
+			if val := o.(*object.Boolean).Value; val != v.expect {
+				t.Fatalf("test%d : got=%t expect=%t\n", i, val, v.expect)
+			}
 		}
-	}
+	})
 }
 
 
 func TestIdentifier(t *testing.T) {
-	tests := []struct{
-		input string
-		expect int
-	}{
-		{"a = 5 a", 5},
-		{"test=10 test", 10},
-		{"test1=10 test1", 10},
-		{"こんにちは＝１００ こんにちは", 100},
-		{"世界 ＝ ２３８ 世界", 238},
-		{"ワールド ＝ ２３５ ワールド", 235},
-	}
-
-	for i, v := range tests {
-		head := token.Tokenize(v.input)
-		program, errors := parser.Parse(head)
-		if len(errors) > 0 {
-			t.Fatalf("Error.\n")
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct{
+			input string
+			expect int
+		}{
+			{"a = 5 a", 5},
+			{"test=10 test", 10},
+			{"test1=10 test1", 10},
+			{"こんにちは＝１００ こんにちは", 100},
+			{"世界 ＝ ２３８ 世界", 238},
+			{"ワールド ＝ ２３５ ワールド", 235},
 		}
 
-		env := object.NewEnvironment()
-		Eval(program.Nodes[0], env)
-		e2 := Eval(program.Nodes[1], env)
-		if val := e2.(*object.Integer).Value; val != v.expect {
-			t.Fatalf("test%d : got=%d expect=%d\n", i, val, v.expect)
+		for i, v := range tests {
+			head := token.Tokenize(v.input)
+			program, errors := parser.Parse(head)
+			if len(errors) > 0 {
+				t.Fatalf("Error.\n")
+			}
+
+			env := object.NewEnvironment()
+			testEvalNode(program.Nodes[0], env)
+			e2 := testEvalNode(program.Nodes[1], env)
+			if val := e2.(*object.Integer).Value; val != v.expect {
+				t.Fatalf("test%d : got=%d expect=%d\n", i, val, v.expect)
+			}
 		}
-	}
+	})
 }
 
 func TestReturnStatement(t *testing.T) {
-	tests := []struct {
-		input string
-		expect string
-	} {
-		{"5+5 戻す", "10"},
-	}
-
-	for i, v := range tests {
-		head := token.Tokenize(v.input)
-		program, errors := parser.Parse(head)
-		if len(errors) > 0 {
-			t.Fatalf("Error.\n")
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"5+5 戻す", "10"},
 		}
 
-		env := object.NewEnvironment()
-		e := Eval(program.Nodes[0], env)
-		if val := e.Inspect(); val != v.expect {
-			t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+		for i, v := range tests {
+			head := token.Tokenize(v.input)
+			program, errors := parser.Parse(head)
+			if len(errors) > 0 {
+				t.Fatalf("Error.\n")
+			}
+
+			env := object.NewEnvironment()
+			e := testEvalNode(program.Nodes[0], env)
+			if val := e.Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
 		}
-	}
+	})
 }
 
 func TestIfStatement(t *testing.T) {
-	tests := []struct {
-		input string
-		expect string
-	} {
-		{"もし 5==5 ならば 10 戻す", "10"},
-		{"もし 5!=5 10 戻す それ以外 15 戻す", "15"},
-	}
-	
-	for i, v := range tests {
-		head := token.Tokenize(v.input)
-		program, errors := parser.Parse(head)
-		if len(errors) > 0 {
-			t.Fatalf("Error.\n")
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"もし 5==5 ならば 10 戻す", "10"},
+			{"もし 5!=5 10 戻す それ以外 15 戻す", "15"},
 		}
+		
+		for i, v := range tests {
+			head := token.Tokenize(v.input)
+			program, errors := parser.Parse(head)
+			if len(errors) > 0 {
+				t.Fatalf("Error.\n")
+			}
 
-		env := object.NewEnvironment()
-		e := Eval(program.Nodes[0], env)
-		if val := e.Inspect(); val != v.expect {
-			t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			env := object.NewEnvironment()
+			e := testEvalNode(program.Nodes[0], env)
+			if val := e.Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
 		}
-	}
+	})
 }
 
 func TestIfStatements(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 		a = 1
 		もし a==1 ならば
 			a = a + 10
@@ -161,44 +210,70 @@ func TestIfStatements(t *testing.T) {
 			a = a - 10
 		a 戻す
 		`
-	head := token.Tokenize(input)
-	program, errors := parser.Parse(head)
-	if len(errors) > 0 {
-		t.Fatalf("Error\n")
-	}
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error\n")
+		}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	Eval(program.Nodes[1], env)
-	v := Eval(program.Nodes[2], env)
-	if val := v.Inspect(); val != "11" {
-		t.Fatalf("got=%s expect=%s\n", val, "11")
-	}
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		testEvalNode(program.Nodes[1], env)
+		v := testEvalNode(program.Nodes[2], env)
+		if val := v.Inspect(); val != "11" {
+			t.Fatalf("got=%s expect=%s\n", val, "11")
+		}
+	})
 }
 
 func TestForStatement(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 	a = 1
 	a < 5 ならば 繰り返す a = a + 1
 	a 戻す
 	`
-	head := token.Tokenize(input)
-	program, errors := parser.Parse(head)
-	if len(errors) > 0 {
-		t.Fatalf("Error,\n")
-	}
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error,\n")
+		}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	Eval(program.Nodes[1], env)
-	v := Eval(program.Nodes[2], env)
-	if val := v.Inspect(); val != "5" {
-		t.Fatalf("got=%s expect=%s\n", val, "5")
-	}
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		testEvalNode(program.Nodes[1], env)
+		v := testEvalNode(program.Nodes[2], env)
+		if val := v.Inspect(); val != "5" {
+			t.Fatalf("got=%s expect=%s\n", val, "5")
+		}
+	})
+}
+
+func TestForStatementValue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		}{
+			{"a = 0 a < 3 ならば 繰り返す a += 1", "null"},
+			{"a = 0 a < 3 ならば 繰り返す { a += 1 a * 10 }", "30"},
+			{"a = 5 a < 3 ならば 繰り返す { a += 1 a }", "null"},
+			{"a = 0 a < 10 ならば 繰り返す { a += 1 もし a == 3 ならば a 戻す } 100", "3"},
+			{"関数 探す(n) { i = 0 i < 10 ならば 繰り返す { もし i * i == n ならば i 戻す i += 1 } -1 } 探す(16)", "4"},
+			{"a = 0 a < 3 ならば 繰り返す { a += 1 b + 1 } a", "Error:変数が宣言されていません"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
 }
 
 func TestBlockStatement(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 	a = 0
 	{
 		a = 5
@@ -207,73 +282,79 @@ func TestBlockStatement(t *testing.T) {
 	}
 	a 戻す
 	`
-	head := token.Tokenize(input)
-	program, errors := parser.Parse(head)
-	if len(errors) > 0 {
-		t.Fatalf("Error\n")
-	}
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error\n")
+		}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	v1 := Eval(program.Nodes[1], env)
-	v2 := Eval(program.Nodes[2], env)
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		v1 := testEvalNode(program.Nodes[1], env)
+		v2 := testEvalNode(program.Nodes[2], env)
 
-	if val := v1.Inspect(); val != "9" {
-		t.Fatalf("got=%s expect=%s\n", val, "9")
-	}
-	if val := v2.Inspect(); val != "5" {
-		t.Fatalf("got=%s expect=%s\n", val, "5")
-	}
+		if val := v1.Inspect(); val != "9" {
+			t.Fatalf("got=%s expect=%s\n", val, "9")
+		}
+		if val := v2.Inspect(); val != "5" {
+			t.Fatalf("got=%s expect=%s\n", val, "5")
+		}
+	})
 }
 
 func TestFuncCall(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 	関数 abc(a, b, c) {
 		a + b - c 戻す
 	}
 	c = 90
 	abc(10, 5, c)
 	`
-	head := token.Tokenize(input)
-	program, errors := parser.Parse(head)
-	if len(errors) > 0 {
-		t.Fatalf("Error\n")
-	}
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error\n")
+		}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	Eval(program.Nodes[1], env)
-	v := Eval(program.Nodes[2], env)
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		testEvalNode(program.Nodes[1], env)
+		v := testEvalNode(program.Nodes[2], env)
 
-	if val := v.Inspect(); val != "-75" {
-		t.Fatalf("got=%s expect=%s\n", val, "-75")
-	}
+		if val := v.Inspect(); val != "-75" {
+			t.Fatalf("got=%s expect=%s\n", val, "-75")
+		}
+	})
 }
 
 func TestRowComment(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 	// こんにちは = 100
 	こんにちは = 800
 	／／ こんにちは ＝ こんにちは ＋ 100
 	こんにちは
 	`
-	head := token.Tokenize(input)
-	program, errors := parser.Parse(head)
-	if len(errors) > 0 {
-		t.Fatalf("Error\n")
-	}
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error\n")
+		}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	v := Eval(program.Nodes[1], env)
-	
-	if val := v.Inspect(); val != "800" {
-		t.Fatalf("got=%s expect%s\n", val, "800")
-	}
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		v := testEvalNode(program.Nodes[1], env)
+		
+		if val := v.Inspect(); val != "800" {
+			t.Fatalf("got=%s expect%s\n", val, "800")
+		}
+	})
 }
 
 func TestBlockComment(t *testing.T) {
-	input := `
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
 	こんにちは = 800
 	/*
 	こんにちは = 100
@@ -281,17 +362,423 @@ func TestBlockComment(t *testing.T) {
 	＊／
 	こんにちは
 	`
+		head := token.Tokenize(input)
+		program, errors := parser.Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("Error\n")
+		}
+
+		env := object.NewEnvironment()
+		testEvalNode(program.Nodes[0], env)
+		v := testEvalNode(program.Nodes[1], env)
+		
+		if val := v.Inspect(); val != "800" {
+			t.Fatalf("got=%s expect%s\n", val, "800")
+		}
+	})
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	return testEvalWith(t, New(), input)
+}
+
+func testEvalWith(t *testing.T, e *Evaluator, input string) object.Object {
 	head := token.Tokenize(input)
 	program, errors := parser.Parse(head)
 	if len(errors) > 0 {
-		t.Fatalf("Error\n")
+		t.Fatalf("%v\n", errors)
 	}
 
-	env := object.NewEnvironment()
-	Eval(program.Nodes[0], env)
-	v := Eval(program.Nodes[1], env)
-	
-	if val := v.Inspect(); val != "800" {
-		t.Fatalf("got=%s expect%s\n", val, "800")
+	e.Backend = testBackend
+	return e.EvalProgram(program, object.NewEnvironment())
+}
+
+func TestCompoundAssign(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"a = 5 a += 3 a", "8"},
+			{"a = 5 a ー＝ 3 a", "2"},
+			{"a = 5 a *= 3 a", "15"},
+			{"a = 9 a ／＝ 3 a", "3"},
+			{"a = 5 a 増やす a", "6"},
+			{"a = 5 a 減らす a", "4"},
+			{"a = 5 a を 10 増やす a", "15"},
+			{"a = [1, 2, 3] a[1] += 10 a", "[1, 12, 3]"},
+			{"a = [1, 2, 3] a[2] を 3 減らす a", "[1, 2, 0]"},
+			{"a = {1: 10, 2: 20} a[2] *= 2 a", "{1: 10, 2: 40}"},
+			{"a = {1: 10} a[1] 増やす a[1]", "11"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestCompoundAssignIndexEvaluatedOnce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
+	i = 0
+	関数 次() {
+		i 増やす
+		i 戻す
 	}
-}
\ No newline at end of file
+	a = [0, 0, 0]
+	a[次()] += 5
+	a
+	`
+		if val := testEval(t, input).Inspect(); val != "[0, 5, 0]" {
+			t.Fatalf("got=%s expect=%s\n", val, "[0, 5, 0]")
+		}
+	})
+}
+
+func TestArrayAndHash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"[1, 2＋3、4]", "[1, 5, 4]"},
+			{"[1, 2, 3][2]", "3"},
+			{"a = [1, 2] a[0] = 9 a", "[9, 2]"},
+			{"a = {1: 2、3: 4} a[3]", "4"},
+			{"a = {} a[1 == 1] = 5 a", "{true: 5}"},
+			{"[1, 2][5]", "Error:添字が範囲外です。"},
+			{"a = 5 a[0] += 1", "Error:添字を使えない値です。"},
+			{"5 += 1", "Error:代入できない式です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestTernaryExpression(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"x = 5 x > 0 なら 1 でなければ -1", "1"},
+			{"x = -5 x > 0 なら 1 でなければ -1", "-1"},
+			{"x = 0 x > 0 なら 1 でなければ x == 0 なら 0 でなければ -1", "0"},
+			{"(1 == 1 なら 2 でなければ 3) * 10", "20"},
+			{"x = 3 y = x < 5 なら x * 2 でなければ x y", "6"},
+			{"[1 なら 2 でなければ 3、0 なら 2 でなければ 3]", "[2, 3]"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestFuncArguments(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"関数 f(a、b=10) { a + b 戻す } f(1)", "11"},
+			{"関数 f(a、b=10) { a + b 戻す } f(1, 2)", "3"},
+			{"関数 f(a、b=a*2) { a + b 戻す } f(3)", "9"},
+			{"関数 f(a、b) { a - b 戻す } f(b: 3、a: 10)", "7"},
+			{"関数 f(a、b=1、c=2) { [a、b、c] 戻す } f(0、c: 5)", "[0, 1, 5]"},
+			{"関数 f(a、…残り) { 残り 戻す } f(1、2、3)", "[2, 3]"},
+			{"関数 f(a、…残り) { 残り 戻す } f(1)", "[]"},
+			{"関数 f(a、b=2、...残り) { [a、b、残り] 戻す } f(1、5、6、7)", "[1, 5, [6, 7]]"},
+			{"a = 1 関数 f(a) { a 戻す } f(5) a", "1"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestFuncArgumentErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"関数 f(a、b=10) { a 戻す } f()", "Error:関数fの引数aが指定されていません。期待される形式: f(a, b=10)"},
+			{"関数 f(a、b=10) { a 戻す } f(1、2、3)", "Error:関数fの引数の個数が正しくありません。期待される形式: f(a, b=10)"},
+			{"関数 f(a) { a 戻す } f(c: 1)", "Error:関数fに引数cはありません。期待される形式: f(a)"},
+			{"関数 f(a) { a 戻す } f(z: 1、y: 2、x: 3)", "Error:関数fに引数xはありません。期待される形式: f(a)"},
+			{"関数 f(a) { a 戻す } f(1、a: 1)", "Error:関数fの引数aが重複して指定されています。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestRecursiveFuncCall(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `
+	関数 階乗(n) {
+		もし n == 0 ならば 1 戻す
+		n * 階乗(n - 1) 戻す
+	}
+	階乗(5)
+	`
+		if val := testEval(t, input).Inspect(); val != "120" {
+			t.Fatalf("got=%s expect=%s\n", val, "120")
+		}
+	})
+}
+
+func TestScope(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		}{
+			{"a = 1 関数 f() { a = 2 } f() a", "2"},
+			{"関数 f() { b = 2 b } f() b", "Error:変数が宣言されていません"},
+			{"関数 f(x) { { x = 3 } x } f(1)", "3"},
+			{"関数 f(x) { { y = x * 2 } y } f(1)", "Error:変数が宣言されていません"},
+			{"関数 f(比較) { 比較(1、2) } 関数 g(a、b) { a + b } f(g)", "3"},
+			{"関数 f() { 読み込む \"数学\" 数学.絶対値(-3) } f()", "3"},
+			{"関数 f(a、b=a*2、…残り) { [a、b、残り] } f(1)", "[1, 2, []]"},
+			{"関数 f(a、b=a*2、…残り) { [a、b、残り] } f(1、2、3、4)", "[1, 2, [3, 4]]"},
+			{"関数 f() { [x、y] = [1、2] x + y } f()", "3"},
+			{"関数 f() { i = 0 合計 = 0 i < 5 ならば 繰り返す { 合計 += i i += 1 } 合計 } f()", "10"},
+			{"関数 f() { 合計 = 合計 + 1 } 合計 = 10 f() f() 合計", "12"},
+			{"関数 f() { 回数 = 1 回数 } f() f()", "1"},
+			{"関数 f(n) { { 結果 = n * 2 結果 } } 写像([1、2]、f)", "[2, 4]"},
+			{"x = 1 関数 f(x) { x } [f(2)、f(3)、x]", "[2, 3, 1]"},
+			{"関数 f() { 1 } a = f() 関数 f() { 2 } [a、f()]", "[1, 2]"},
+			{"関数 長さ(x) { 0 } 長さ([1、2])", "0"},
+			{"長さ = 1 長さ([1])", "Error:関数が宣言されていません。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestWarnings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		var warnings bytes.Buffer
+		e := New()
+		e.Warnings = &warnings
+		testEvalWith(t, e, "関数 f(x) { 一時 = x 1 } 表示する(未定義)")
+
+		expect := "警告: 変数「一時」は使われていません。\n警告: 変数「未定義」が宣言されていません。\n"
+		if warnings.String() != expect {
+			t.Fatalf("got=%q expect=%q\n", warnings.String(), expect)
+		}
+	})
+}
+
+func TestTailCall(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		}{
+			{"関数 合計(n、acc) { もし n == 0 ならば acc 戻す 合計(n - 1、acc + n) 戻す } 合計(100000、0)", "5000050000"},
+			{"関数 偶数(n) { もし n == 0 ならば 1 戻す 奇数(n - 1) 戻す } 関数 奇数(n) { もし n == 0 ならば 0 戻す 偶数(n - 1) 戻す } 偶数(50001)", "0"},
+			{"関数 数える(n、acc=0) { もし n == 0 ならば acc 戻す 数える(n - 1、acc: acc + 1) 戻す } 数える(30000)", "30000"},
+			{"関数 f(n) { もし n == 0 ならば \"終\" 戻す { f(n - 1) 戻す } } f(20000)", "終"},
+			{"関数 f(n) { もし n == 0 ならば 0 戻す 1 + f(n - 1) } f(100000)", "Error:再帰が深すぎます。"},
+			{"関数 f(n) { もし n == 0 ならば 0 戻す f(n - 1) + 1 戻す } f(100000)", "Error:再帰が深すぎます。"},
+			{"関数 f(n) { もし n == 0 ならば 0 戻す 1 + f(n - 1) } f(500)", "500"},
+			{"関数 f(n) { 写像([n]、f) } f(1)", "Error:再帰が深すぎます。"},
+			{"関数 f(x) { x * 2 } f(3) 戻す", "6"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := "関数 f(n) { もし n == 0 ならば 0 戻す 1 + f(n - 1) } f(50)"
+
+		e := New()
+		e.Limits.MaxDepth = 50
+		if val := testEvalWith(t, e, input).Inspect(); val != "Error:再帰が深すぎます。" {
+			t.Fatalf("got=%s expect=%s\n", val, "Error:再帰が深すぎます。")
+		}
+
+		// 上限に達してエラーになっても、深さは元に戻る
+		e.Limits.MaxDepth = 51
+		if val := testEvalWith(t, e, input).Inspect(); val != "50" {
+			t.Fatalf("got=%s expect=%s\n", val, "50")
+		}
+	})
+}
+
+func TestMultipleReturnValues(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"関数 f() { 1、2 戻す } f()", "(1, 2)"},
+			{"関数 f() { 戻す 1、2 } f()[1]", "2"},
+			{"関数 f() { 1、2 戻す } x、y = f() x * 10 + y", "12"},
+			{"関数 割り算(a、b) { a / b、a - a / b * b 戻す } 商、余り = 割り算(7、2) 商 * 10 + 余り", "31"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestDestructuringAssign(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"x、y = 1、2 x * 10 + y", "12"},
+			{"x = 1 y = 2 x、y = y、x x * 10 + y", "21"},
+			{"[先頭、…残り] = [1、2、3] 結果 = [先頭、残り] 結果", "[1, [2, 3]]"},
+			{"[先頭、…残り] = [1] 結果 = [先頭、残り] 結果", "[1, []]"},
+			{"関数 f(p、q) { p } f(1、2)\n[先頭、…残り] = [1、2、3]\n[先頭、残り]", "[1, [2, 3]]"},
+			{"a = [1、2]\n[x、y] = a\nx + y", "3"},
+			{"a = [[1、2]] a\n[0]", "[0]"},
+			{"[a、…中、b] = [1、2、3、4] 結果 = [a、中、b] 結果", "[1, [2, 3], 4]"},
+			{"a、[b、c] = 1、[2、3] 結果 = [a、b、c] 結果", "[1, 2, 3]"},
+			{"a = [0、0] a[0]、a[1] = 5、6 a", "[5, 6]"},
+			{"x = 1、2 x", "(1, 2)"},
+			{"x、y = 1、2、3", "Error:値の個数が正しくありません。代入先=2 値=3"},
+			{"[a、b、…c] = [1]", "Error:値の個数が足りません。代入先=2 値=1"},
+			{"x、y = 1", "Error:分割代入には配列か複数の値が必要です。"},
+			{"[…a、…b] = [1]", "Error:\"…\"は分割代入の左辺で一つだけ使えます。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestParticleCall(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 10 から 3 を 引く", "7"},
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 3 を 10 から 引く", "7"},
+			{"関数 引く(元 から、数 を=1) { 元 - 数 戻す } 10 から 引く", "9"},
+			{"関数 倍(x) { x * 2 戻す } 5 を 倍する", "10"},
+			{"関数 組(a、b) { [a、b] 戻す } 1 と 2 を 組", "[1, 2]"},
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 結果 = 1 + 9 から 3 を 引く 結果", "7"},
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 10 に 3 を 引く", "Error:関数引くには助詞「に」で受け取る引数がありません。期待される形式: 引く(元 から, 数 を)"},
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 10 から 3 から 引く", "Error:関数引くの助詞「から」が重複しています。"},
+			{"a = 1 a を 2 増やす a", "3"},
+			// 助詞を前後の語に付けて書く
+			{"関数 引く(元 から、数 を) { 元 - 数 戻す } 10から3を引く", "7"},
+			{"関数 倍(x) { x * 2 戻す } 値 = 5 値を倍する", "10"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestString(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{`"こんにちは"`, "こんにちは"},
+			{`＂全角＂`, "全角"},
+			{`"改行\nと\"引用符\""`, "改行\nと\"引用符\""},
+			{`"こんにちは、" + "世界"`, "こんにちは、世界"},
+			{`"あ" == "あ"`, "true"},
+			{`"あ" != "あ"`, "false"},
+			{`a = {"キー": 1} a["キー"]`, "1"},
+			{`"あ" - "い"`, "Error:文字列に使えない演算子です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestFloat(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"1.5 + 1", "2.5"},
+			{"３．５ * 2", "7.0"},
+			{"7 / 2.0", "3.5"},
+			{"-0.5", "-0.5"},
+			{"0.1 < 0.2", "true"},
+			{"2.0 == 2", "true"},
+			{"a = 1 a += 0.5 a", "1.5"},
+			{"1.0 / 0", "Error:0で割ることはできません。"},
+			{"1 / 0", "Error:0で割ることはできません。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestPrint(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		var out bytes.Buffer
+		e := New()
+		e.Out = &out
+
+		testEvalWith(t, e, `
+	表示("こんにちは"、1、[2、3])
+	"世界" を 表示する
+	「こんにちは」を表示する
+	f = 表示
+	f(1.5)
+	`)
+		expect := "こんにちは 1 [2, 3]\n世界\nこんにちは\n1.5\n"
+		if out.String() != expect {
+			t.Fatalf("got=%q expect=%q\n", out.String(), expect)
+		}
+	})
+}
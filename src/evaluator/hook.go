@@ -0,0 +1,16 @@
+package evaluator
+
+import (
+	"jpl/ast"
+	"jpl/object"
+)
+
+// 実行を見張る。デバッガが使う。
+// 設定しなければ呼び出す前に nil かどうかを確かめるだけなので、普段の実行は遅くならない
+type Hook interface {
+	// 文を実行する前に呼ぶ。エラーを返すとそこで実行を止める
+	Statement(node *ast.Node, env *object.Environment) object.Object
+	// スクリプトの関数の本体を実行する前と後に呼ぶ。末尾呼び出しでは一度戻ってから次の関数に入る
+	EnterFunction(fn *object.Function, env *object.Environment)
+	LeaveFunction(fn *object.Function)
+}
@@ -0,0 +1,72 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"jpl/ast"
+	"jpl/object"
+)
+
+type recordHook struct {
+	events []string
+	stopAt int
+}
+
+func (h *recordHook) Statement(node *ast.Node, env *object.Environment) object.Object {
+	vars := []string{}
+	for _, v := range env.Variables() {
+		if v.Value.Type() != object.FUNCTION {
+			vars = append(vars, v.Name+"="+v.Value.Inspect())
+		}
+	}
+	h.events = append(h.events, fmt.Sprintf("%d%v", node.Line, vars))
+	if node.Line == h.stopAt {
+		return newError("止めました。")
+	}
+	return nil
+}
+
+func (h *recordHook) EnterFunction(fn *object.Function, env *object.Environment) {
+	h.events = append(h.events, "→"+fn.Name)
+}
+
+func (h *recordHook) LeaveFunction(fn *object.Function) {
+	h.events = append(h.events, "←"+fn.Name)
+}
+
+func TestHook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		input := `関数 f(n) {
+	m = n + 1
+	m 戻す
+}
+x = f(1)
+{
+	y = x
+	y
+}`
+
+		tests := []struct {
+			stopAt int
+			expect string
+			events string
+		}{
+			{0, "2", "1[] 5[] →f 2[n=1] 3[m=2 n=1] ←f 6[x=2] 7[] 8[y=2]"},
+			{3, "Error:止めました。", "1[] 5[] →f 2[n=1] 3[m=2 n=1] ←f"},
+		}
+
+		for i, v := range tests {
+			hook := &recordHook{stopAt: v.stopAt}
+			e := New()
+			e.Hook = hook
+			if val := testEvalWith(t, e, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+			if events := strings.Join(hook.events, " "); events != v.events {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, events, v.events)
+			}
+		}
+	})
+}
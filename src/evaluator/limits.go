@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+
+	"jpl/ast"
+	"jpl/object"
+)
+
+// 関数呼び出しの深さの既定の上限
+const DefaultMaxDepth = 10000
+
+// 信頼できないスクリプトを実行する時の上限
+type Limits struct {
+	MaxSteps       int // 繰り返しと関数呼び出しの回数
+	MaxDepth       int // 関数呼び出しの深さ。0なら DefaultMaxDepth。末尾呼び出しは深さに数えない
+	MaxAllocations int // 作る値の数。配列や連想配列は要素の数も数える
+	MaxStringSize  int // 作る文字列のバイト数
+}
+
+func newLimitError(format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Kind = object.LimitError
+	return err
+}
+
+// 上限や期限に達して止まったエラーかどうか
+func IsLimitError(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	return ok && err.Kind == object.LimitError
+}
+
+// 期限付きで実行する。期限を過ぎたり取り消されたりすると、上限に達した時と同じ種類のエラーを返す
+func (e *Evaluator) EvalProgramContext(ctx context.Context, program *ast.Program, env *object.Environment) object.Object {
+	prev := e.ctx
+	e.ctx = ctx
+	defer func() { e.ctx = prev }()
+	return e.EvalProgram(program, env)
+}
+
+func (e *Evaluator) EvalFileContext(ctx context.Context, path string, env *object.Environment) object.Object {
+	prev := e.ctx
+	e.ctx = ctx
+	defer func() { e.ctx = prev }()
+	return e.EvalFile(path, env)
+}
+
+// 期限付きで関数を呼び出す。組み込む側からスクリプトの関数を呼ぶ時に使う
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	prev := e.ctx
+	e.ctx = ctx
+	defer func() { e.ctx = prev }()
+	defer e.enterProgram()()
+	return e.Apply(fn, args...)
+}
+
+// 一番外側の実行の始まりで数を数え直す
+func (e *Evaluator) enterProgram() func() {
+	if e.running == 0 {
+		e.steps = 0
+		e.allocations = 0
+	}
+	e.running++
+	return func() { e.running-- }
+}
+
+func (e *Evaluator) maxDepth() int {
+	if e.Limits.MaxDepth > 0 {
+		return e.Limits.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// 繰り返しと関数呼び出しのたびに呼び、上限と期限を確かめる
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.Limits.MaxSteps > 0 && e.steps > e.Limits.MaxSteps {
+		return newLimitError("実行の手順が上限(%d)を超えました。", e.Limits.MaxSteps)
+	}
+	// 一回の手順で大きな文字列を作ることもあるので、期限は毎回確かめる
+	return e.checkContext()
+}
+
+func (e *Evaluator) checkContext() *object.Error {
+	if e.ctx != nil {
+		select {
+		case <-e.ctx.Done():
+			return contextError(e.ctx.Err())
+		default:
+		}
+	}
+	return nil
+}
+
+func contextError(err error) *object.Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newLimitError("実行時間の上限を超えました。")
+	}
+	return newLimitError("実行が取り消されました。")
+}
+
+// 作った値を数える。上限を超えたらエラーを返し、そうでなければ値をそのまま返す
+func (e *Evaluator) allocate(obj object.Object) object.Object {
+	if e.Limits.MaxAllocations == 0 && e.Limits.MaxStringSize == 0 {
+		return obj
+	}
+
+	size := 1
+	switch obj := obj.(type) {
+	case *object.String:
+		if err := e.reserveString(len(obj.Value)); err != nil {
+			return err
+		}
+	case *object.Array:
+		size += len(obj.Elements)
+	case *object.Tuple:
+		size += len(obj.Elements)
+	case *object.Hash:
+		size += len(obj.Pairs)
+	case *object.Error:
+		return obj
+	}
+	if err := e.reserve(size); err != nil {
+		return err
+	}
+	e.allocations += size
+	return obj
+}
+
+// これから n 個の値を作れるか確かめる。大きな配列を作る前に使う
+func (e *Evaluator) reserve(n int) *object.Error {
+	if e.Limits.MaxAllocations > 0 && e.allocations+n > e.Limits.MaxAllocations {
+		return newLimitError("作った値の数が上限(%d)を超えました。", e.Limits.MaxAllocations)
+	}
+	return nil
+}
+
+// これから n バイトの文字列を作れるか確かめる。大きな文字列を作る前に使う
+func (e *Evaluator) reserveString(n int) *object.Error {
+	if e.Limits.MaxStringSize > 0 && n > e.Limits.MaxStringSize {
+		return newLimitError("文字列の長さが上限(%d)を超えました。", e.Limits.MaxStringSize)
+	}
+	return nil
+}
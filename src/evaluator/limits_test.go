@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+func testEvalContext(t *testing.T, ctx context.Context, e *Evaluator, input string) object.Object {
+	program, errors := parser.Parse(token.Tokenize(input))
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	e.Backend = testBackend
+	return e.EvalProgramContext(ctx, program, object.NewEnvironment())
+}
+
+func TestLimits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			limits Limits
+			expect string
+		}{
+			{"1 ならば 繰り返す { 1 }", Limits{MaxSteps: 100}, "Error:実行の手順が上限(100)を超えました。"},
+			{"関数 f(n) { f(n + 1) 戻す } f(0)", Limits{MaxSteps: 100}, "Error:実行の手順が上限(100)を超えました。"},
+			{"関数 f(n) { 1 + f(n + 1) } f(0)", Limits{MaxDepth: 30}, "Error:再帰が深すぎます。"},
+			{"a = [] 1 ならば 繰り返す { a = [1、2、3] }", Limits{MaxAllocations: 50}, "Error:作った値の数が上限(50)を超えました。"},
+			{"範囲(1000000)", Limits{MaxAllocations: 100}, "Error:作った値の数が上限(100)を超えました。"},
+			{"範囲(-9223372036854775807、9223372036854775807、3)", Limits{MaxAllocations: 100}, "Error:作った値の数が上限(100)を超えました。"},
+			{"s = \"ab\" 1 ならば 繰り返す { s = s + s }", Limits{MaxStringSize: 1000}, "Error:文字列の長さが上限(1000)を超えました。"},
+			{`読み込む "文字列" 文字列.置換("aaaa"、"a"、"bbbbbb")`, Limits{MaxStringSize: 20}, "Error:文字列の長さが上限(20)を超えました。"},
+			{`読み込む "文字列" 文字列.置換("aaaa"、""、"bbbbb")`, Limits{MaxStringSize: 20}, "Error:文字列の長さが上限(20)を超えました。"},
+			{`読み込む "文字列" 文字列.置換("aaaa"、"a"、"bbbbb")`, Limits{MaxStringSize: 20}, "bbbbbbbbbbbbbbbbbbbb"},
+			{`読み込む "正規表現" 正規表現.置換("aaaa"、"a"、"bbbbbb")`, Limits{MaxStringSize: 20}, "Error:文字列の長さが上限(20)を超えました。"},
+			{`読み込む "正規表現" 正規表現.置換("aaaa"、"(a)"、"$1$1$1$1$1$1")`, Limits{MaxStringSize: 20}, "Error:文字列の長さが上限(20)を超えました。"},
+			{`読み込む "文字列" s = 文字列.置換("aaaaaaaaaa"、"a"、"aaaaaaaaaa") s = 文字列.置換(s、"a"、s) 文字列.置換(s、"a"、s)`, Limits{MaxStringSize: 100000}, "Error:文字列の長さが上限(100000)を超えました。"},
+			{`読み込む "正規表現" s = 正規表現.置換("aaaaaaaaaa"、"a"、"aaaaaaaaaa") s = 正規表現.置換(s、"a"、s) 正規表現.置換(s、"a"、s)`, Limits{MaxStringSize: 100000}, "Error:文字列の長さが上限(100000)を超えました。"},
+			{`読み込む "正規表現" 正規表現.置換("a-a-a"、"(a)"、"<$1>")`, Limits{MaxStringSize: 20}, "<a>-<a>-<a>"},
+			{"i = 0 i < 10 ならば 繰り返す { i += 1 } i", Limits{MaxSteps: 100}, "10"},
+			{"範囲(10)[9]", Limits{MaxAllocations: 100}, "9"},
+		}
+
+		for i, v := range tests {
+			e := New()
+			e.Limits = v.limits
+			res := testEvalWith(t, e, v.input)
+			if val := res.Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+			if isError(res) && !IsLimitError(res) {
+				t.Fatalf("test%d : 上限のエラーになっていません。\n", i)
+			}
+		}
+	})
+}
+
+// 上限は実行ごとに数え直す
+func TestLimitsReset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		e := New()
+		e.Limits.MaxSteps = 100
+		for i := 0; i < 3; i++ {
+			if val := testEvalWith(t, e, "i = 0 i < 60 ならば 繰り返す { i += 1 } i").Inspect(); val != "60" {
+				t.Fatalf("test%d : got=%s expect=60\n", i, val)
+			}
+		}
+	})
+}
+
+func TestContext(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		res := testEvalContext(t, ctx, New(), "1 ならば 繰り返す { 1 }")
+		if val := res.Inspect(); val != "Error:実行時間の上限を超えました。" || !IsLimitError(res) {
+			t.Fatalf("got=%s expect=%s\n", val, "Error:実行時間の上限を超えました。")
+		}
+
+		// 一回ごとに時間のかかる繰り返しも、メモリを使い切る前に止まる
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		res = testEvalContext(t, ctx, New(), `s = "ab" 1 ならば 繰り返す { s = s + s }`)
+		if val := res.Inspect(); val != "Error:実行時間の上限を超えました。" || !IsLimitError(res) {
+			t.Fatalf("got=%s expect=%s\n", val, "Error:実行時間の上限を超えました。")
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		res = testEvalContext(t, ctx, New(), "関数 f(n) { f(n + 1) 戻す } f(0)")
+		if val := res.Inspect(); val != "Error:実行が取り消されました。" || !IsLimitError(res) {
+			t.Fatalf("got=%s expect=%s\n", val, "Error:実行が取り消されました。")
+		}
+
+		// 普通のエラーは上限のエラーではない
+		if res := testEval(t, "1 / 0"); IsLimitError(res) {
+			t.Fatalf("got=%s\n", res.Inspect())
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jpl/ast"
+	"jpl/object"
+	"jpl/optimizer"
+	"jpl/parser"
+	"jpl/resolver"
+	"jpl/token"
+)
+
+const extension = ".jpl"
+
+// プログラム全体を評価する。エラーか戻す文があればそこで止める
+func (e *Evaluator) EvalProgram(program *ast.Program, env *object.Environment) object.Object {
+	defer e.enterProgram()()
+
+	diagnostics := resolver.Resolve(program, func(name string) bool {
+		_, ok := e.lookUp(name, env)
+		return ok
+	})
+	if e.Warnings != nil {
+		for _, v := range diagnostics {
+			fmt.Fprintf(e.Warnings, "警告: %s\n", v.Message)
+		}
+	}
+
+	if !e.NoOptimize {
+		optimizer.Optimize(program, evaluatorRuntime{e})
+	}
+	if e.Backend == VM && e.Hook == nil {
+		return e.machine().Run(program, env)
+	}
+
+	var res object.Object = NULL
+
+	for _, v := range program.Nodes {
+		if e.Hook != nil {
+			if err := e.Hook.Statement(v, env); err != nil {
+				return err
+			}
+		}
+		res = e.Eval(v, env)
+		if returnValue, ok := res.(*object.ReturnValue); ok {
+			return returnValue.Value
+		}
+		if isError(res) {
+			return res
+		}
+	}
+
+	return res
+}
+
+// ファイルを読み込んで評価する。ファイル中の「./」で始まるモジュールは、このファイルのディレクトリから探す
+func (e *Evaluator) EvalFile(path string, env *object.Environment) object.Object {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return newError("ファイルを開けません。%s", err)
+	}
+
+	program, errObj := parseFile(absPath)
+	if errObj != nil {
+		return errObj
+	}
+	return e.EvalFileProgram(program, absPath, env)
+}
+
+// 構文解析の済んだファイルを評価する。path はファイル中のモジュールを探すのに使う
+func (e *Evaluator) EvalFileProgram(program *ast.Program, path string, env *object.Environment) object.Object {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return newError("ファイルを開けません。%s", err)
+	}
+
+	e.loading = append(e.loading, absPath)
+	defer func() { e.loading = e.loading[:len(e.loading)-1] }()
+
+	return e.EvalProgram(program, env)
+}
+
+func parseFile(path string) (*ast.Program, object.Object) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("ファイルを開けません。%s", err)
+	}
+
+	program, errors := parser.Parse(token.Tokenize(string(src)))
+	if len(errors) > 0 {
+		return nil, newError("%sの構文が正しくありません。%s", filepath.Base(path), strings.Join(errors, " "))
+	}
+	return program, nil
+}
+
+func isFileExist(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// 読み込むファイルの絶対パスを決める。
+// 「./」「../」で始まる名前は読み込んでいるファイルのディレクトリから、それ以外は検索パスから探す。
+// 標準モジュールはここに来る前に探す
+func (e *Evaluator) resolveModule(name string) (string, bool) {
+	if filepath.Ext(name) == "" {
+		name += extension
+	}
+
+	if filepath.IsAbs(name) {
+		return name, isFileExist(name)
+	}
+
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		dir := "."
+		if len(e.loading) > 0 {
+			dir = filepath.Dir(e.loading[len(e.loading)-1])
+		}
+		path, err := filepath.Abs(filepath.Join(dir, name))
+		if err != nil {
+			return "", false
+		}
+		return path, isFileExist(path)
+	}
+
+	for _, dir := range e.SearchPath {
+		path, err := filepath.Abs(filepath.Join(dir, name))
+		if err == nil && isFileExist(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (e *Evaluator) loadModule(path string) object.Object {
+	if module, ok := e.modules[path]; ok {
+		return module
+	}
+
+	for i, v := range e.loading {
+		if v != path {
+			continue
+		}
+		names := []string{}
+		for _, loading := range e.loading[i:] {
+			names = append(names, filepath.Base(loading))
+		}
+		names = append(names, filepath.Base(path))
+		return newError("モジュールの読み込みが循環しています。%s", strings.Join(names, " → "))
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	module := &object.Module{Name: name, Path: path, Env: object.NewEnvironment()}
+
+	res := e.EvalFile(path, module.Env)
+	if isError(res) {
+		return res
+	}
+
+	e.modules[path] = module
+	return module
+}
+
+func (e *Evaluator) importModule(name string, env *object.Environment) object.Object {
+	if module, ok := e.stdModule(name); ok {
+		env.Define(module.Name, module)
+		return NULL
+	}
+
+	path, ok := e.resolveModule(name)
+	if !ok {
+		return newError("モジュール「%s」が見つかりません。", name)
+	}
+	// ファイル操作を禁止していてもモジュールは読めるが、ディレクトリの外のものは読めない
+	path, errObj := e.confine(path)
+	if errObj != nil {
+		return errObj
+	}
+
+	module := e.loadModule(path)
+	if isError(module) {
+		return module
+	}
+
+	env.Define(module.(*object.Module).Name, module)
+	return NULL
+}
+
+func (e *Evaluator) evalMember(node *ast.Node, env *object.Environment) object.Object {
+	obj := e.Eval(node.Lhs, env)
+	if isError(obj) {
+		return obj
+	}
+	return member(obj, node.Ident)
+}
+
+func member(obj object.Object, name string) object.Object {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("\".\"はモジュールにしか使えません。")
+	}
+
+	res, ok := module.Get(name)
+	if !ok {
+		return newError("モジュール%sに%sはありません。", module.Name, name)
+	}
+	return res
+}
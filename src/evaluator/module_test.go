@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jpl/object"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"main.jpl": `
+		読み込む "./lib/util.jpl"
+		読み込む "./lib/util"
+		util.倍(util.基準) + util.倍(1)
+		`,
+			"lib/util.jpl": `
+		基準 = 10
+		関数 倍(x) {
+			x * 2 戻す
+		}
+		`,
+		})
+
+		e := New()
+		e.Backend = testBackend
+		res := e.EvalFile(filepath.Join(dir, "main.jpl"), object.NewEnvironment())
+		if val := res.Inspect(); val != "22" {
+			t.Fatalf("got=%s expect=%s\n", val, "22")
+		}
+		if len(e.modules) != 1 {
+			t.Fatalf("modules : got=%d expect=%d\n", len(e.modules), 1)
+		}
+	})
+}
+
+func TestImportRelativeToImportingFile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"main.jpl": `
+		読み込む "./a/b.jpl"
+		b.値
+		`,
+			"a/b.jpl": `
+		読み込む "./c.jpl"
+		値 = c.値 + 1
+		`,
+			"a/c.jpl": `値 = 41`,
+		})
+
+		e := New()
+		e.Backend = testBackend
+		res := e.EvalFile(filepath.Join(dir, "main.jpl"), object.NewEnvironment())
+		if val := res.Inspect(); val != "42" {
+			t.Fatalf("got=%s expect=%s\n", val, "42")
+		}
+	})
+}
+
+func TestImportSearchPath(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"lib/挨拶.jpl": `
+		関数 挨拶(名前 を) {
+			"こんにちは、" + 名前 戻す
+		}
+		`,
+		})
+
+		e := New()
+		e.SearchPath = []string{filepath.Join(dir, "none"), filepath.Join(dir, "lib")}
+		res := testEvalWith(t, e, `
+	読み込む "挨拶"
+	"世界" を 挨拶.挨拶する
+	`)
+		if val := res.Inspect(); val != "こんにちは、世界" {
+			t.Fatalf("got=%s expect=%s\n", val, "こんにちは、世界")
+		}
+	})
+}
+
+func TestImportErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"a.jpl": `読み込む "./b.jpl"`,
+			"b.jpl": `読み込む "./a.jpl"`,
+			"private.jpl": `_秘密 = 1`,
+			"broken.jpl": `関数 (`,
+			"main.jpl": `読み込む "./nothing.jpl"`,
+		})
+
+		tests := []struct {
+			input string
+			expect string
+		} {
+			{"読み込む \"./a.jpl\"", "Error:モジュールの読み込みが循環しています。a.jpl → b.jpl → a.jpl"},
+			{"読み込む \"./private.jpl\" private._秘密", "Error:モジュールprivateに_秘密はありません。"},
+			{"読み込む \"./nothing.jpl\"", "Error:モジュール「./nothing.jpl」が見つかりません。"},
+			{"x = 1 x.y", "Error:\".\"はモジュールにしか使えません。"},
+		}
+
+		for i, v := range tests {
+			e := New()
+			e.loading = []string{filepath.Join(dir, "main.jpl")}
+			if val := testEvalWith(t, e, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+
+		e := New()
+		e.loading = []string{filepath.Join(dir, "main.jpl")}
+		if res := testEvalWith(t, e, "読み込む \"./broken.jpl\""); !isError(res) {
+			t.Fatalf("expected a syntax error, got=%s\n", res.Inspect())
+		}
+	})
+}
+
+func TestImportFilePolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"root/in.jpl": `値 = 1`,
+			"outside.jpl": `値 = 2`,
+		})
+		root := filepath.Join(dir, "root")
+		outside := filepath.Join(dir, "outside.jpl")
+
+		tests := []struct {
+			policy FilePolicy
+			input  string
+			expect string
+		}{
+			{FilePolicy{Root: root}, `読み込む "./in.jpl" in.値`, "1"},
+			{FilePolicy{Root: root}, `読み込む "../outside.jpl"`, "Error:「" + outside + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Root: root}, `読み込む "` + outside + `"`, "Error:「" + outside + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Deny: true}, `読み込む "./in.jpl" in.値`, "1"},
+			{FilePolicy{ReadOnly: true}, `読み込む "./in.jpl" in.値`, "1"},
+			{FilePolicy{Deny: true, Root: root}, `読み込む "../outside.jpl"`, "Error:「" + outside + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Deny: true}, `読み込む "ファイル" ファイル.読む("./in.jpl")`, "Error:ファイル操作は許可されていません。"},
+			{FilePolicy{Deny: true}, `読み込む "数学" 数学.円周率 > 3`, "true"},
+		}
+
+		for i, v := range tests {
+			e := New()
+			e.Files = v.policy
+			e.loading = []string{filepath.Join(root, "main.jpl")}
+			if val := testEvalWith(t, e, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
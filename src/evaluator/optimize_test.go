@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"testing"
+
+	"jpl/ast"
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+// 最適化してもしなくても結果が同じになる
+func TestOptimizeEquivalence(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []string{
+			"60 * 60 * 24",
+			"1.5 * 2 + 1",
+			"-0.0",
+			"x = 0.0 -x",
+			"x = 3 -x * 2",
+			"x = \"あ\" -x",
+			"1 ÷ 0",
+			"1 ÷ 0.0",
+			"1 + \"あ\"",
+			`"あ" + "い" == "あい"`,
+			"もし 1 < 2 ならば 10 それ以外 20",
+			"もし 1 == 2 ならば 10",
+			"もし \"\" ならば 10 それ以外 20",
+			"もし 0.0 ならば 10 それ以外 20",
+			"a = 1 もし 1 ならば { a = 2 b = 3 } a",
+			"a = 1 もし 1 ならば { a = 2 b = 3 } b",
+			"1 == 1.0 なら \"同じ\" でなければ \"違う\"",
+			"a = 0 0 ならば 繰り返す a += 1 a",
+			"a = 0 a < 2 * 5 ならば 繰り返す a += 1 + 2 a",
+			"関数 f(x) { もし 1 == 1 ならば x * (60 * 60) 戻す 0 } f(2)",
+			"関数 f(x=2 * 3) { x } f()",
+			"関数 f(x=2 * 3) { x } f(1、2)",
+			"もし 1 ならば 1 ÷ 0",
+			"もし 0 ならば 1 ÷ 0 それ以外 -(2 - 5)",
+		}
+
+		for i, input := range tests {
+			program, errors := parser.Parse(token.Tokenize(input))
+			if len(errors) > 0 {
+				t.Fatalf("test%d : %v\n", i, errors)
+			}
+			expect := evalProgramWith(program, true).Inspect()
+
+			program, _ = parser.Parse(token.Tokenize(input))
+			if got := evalProgramWith(program, false).Inspect(); got != expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, got, expect)
+			}
+		}
+	})
+}
+
+func evalProgramWith(program *ast.Program, noOptimize bool) object.Object {
+	e := New()
+	e.Backend = testBackend
+	e.NoOptimize = noOptimize
+	return e.EvalProgram(program, object.NewEnvironment())
+}
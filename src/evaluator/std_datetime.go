@@ -0,0 +1,417 @@
+package evaluator
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"jpl/object"
+	"jpl/utils"
+)
+
+func (e *Evaluator) dateTimeModule() map[string]object.Object {
+	module := map[string]object.Object{
+		"現在": newBuiltin("現在", e.dateTimeNow),
+		"作成": newBuiltin("作成", e.dateTimeCreate),
+		"解析": newBuiltin("解析", e.dateTimeParse),
+		"書式": newBuiltin("書式", dateTimeFormat),
+		"和暦": newBuiltin("和暦", dateTimeJapanese),
+		"曜日": newBuiltin("曜日", dateTimeWeekday),
+		"足す": newBuiltin("足す", dateTimeAdd),
+		"差":  newBuiltin("差", dateTimeDiff),
+	}
+	for name, field := range dateTimeFields {
+		module[name] = newBuiltin(name, dateTimeField(name, field))
+	}
+	return module
+}
+
+// 元号と、元年の西暦と、扱う日付の始まり。明治は太陽暦になった明治6年から扱う
+var eras = []struct {
+	name  string
+	first int
+	start time.Time
+}{
+	{"令和", 2019, time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)},
+	{"平成", 1989, time.Date(1989, 1, 8, 0, 0, 0, 0, time.UTC)},
+	{"昭和", 1926, time.Date(1926, 12, 25, 0, 0, 0, 0, time.UTC)},
+	{"大正", 1912, time.Date(1912, 7, 30, 0, 0, 0, 0, time.UTC)},
+	{"明治", 1868, time.Date(1873, 1, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+var weekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+var dateTimeFields = map[string]func(t time.Time) int{
+	"年": func(t time.Time) int { return t.Year() },
+	"月": func(t time.Time) int { return int(t.Month()) },
+	"日": func(t time.Time) int { return t.Day() },
+	"時": func(t time.Time) int { return t.Hour() },
+	"分": func(t time.Time) int { return t.Minute() },
+	"秒": func(t time.Time) int { return t.Second() },
+}
+
+// 日付だけを比べるため、時刻と時間帯を取り除く
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// 日付が含まれる元号と、その元号での年を返す
+func japaneseEra(t time.Time) (string, int, bool) {
+	for _, v := range eras {
+		if !dateOf(t).Before(v.start) {
+			return v.name, t.Year() - v.first + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+func eraYear(year int) string {
+	if year == 1 {
+		return "元"
+	}
+	return strconv.Itoa(year)
+}
+
+func (e *Evaluator) location() *time.Location {
+	return e.Clock().Location()
+}
+
+func dateTimeArg(name string, arg object.Object) (time.Time, *object.Error) {
+	dateTime, ok := arg.(*object.DateTime)
+	if !ok {
+		return time.Time{}, newArgTypeError(name, "日時")
+	}
+	return dateTime.Value, nil
+}
+
+// 日付として正しいか確かめて日時を作る。2月30日のような日付は繰り上げずにエラーにする
+func newDateTime(loc *time.Location, year, month, day, hour, min, sec int) (*object.DateTime, *object.Error) {
+	t := time.Date(year, time.Month(month), day, hour, min, sec, 0, loc)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day ||
+		t.Hour() != hour || t.Minute() != min || t.Second() != sec {
+		return nil, newError("%d年%d月%d日 %d時%d分%d秒は存在しない日時です。", year, month, day, hour, min, sec)
+	}
+	return &object.DateTime{Value: t}, nil
+}
+
+func (e *Evaluator) dateTimeNow(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newArgCountError("現在", "現在()")
+	}
+	return &object.DateTime{Value: e.Clock()}
+}
+
+func (e *Evaluator) dateTimeCreate(args ...object.Object) object.Object {
+	if len(args) < 3 || len(args) > 6 {
+		return newArgCountError("作成", "作成(年、月、日、時=0、分=0、秒=0)")
+	}
+	nums, err := integerArgs("作成", args)
+	if err != nil {
+		return err
+	}
+	nums = append(nums, make([]int, 6-len(nums))...)
+
+	dateTime, err := newDateTime(e.location(), nums[0], nums[1], nums[2], nums[3], nums[4], nums[5])
+	if err != nil {
+		return err
+	}
+	return dateTime
+}
+
+var (
+	isoDateRegexp      = regexp.MustCompile(`^(\d{4})[-/](\d{1,2})[-/](\d{1,2})(?:[ T](\d{1,2}):(\d{1,2})(?::(\d{1,2}))?)?$`)
+	japaneseDateRegexp = regexp.MustCompile(`^(明治|大正|昭和|平成|令和)?(\d+|元)年(\d{1,2})月(\d{1,2})日\s*(?:\((.)(?:曜日?)?\))?\s*(?:(\d{1,2})時(?:(\d{1,2})分(?:(\d{1,2})秒)?)?)?$`)
+)
+
+// 「2024-10-17 09:30」「2024/10/17」「2024年10月17日」「令和6年10月17日(木) 9時30分」のような文字列を読む
+func (e *Evaluator) dateTimeParse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("解析", "解析(文字列)")
+	}
+	strs, err := stringArgs("解析", args)
+	if err != nil {
+		return err
+	}
+	str := utils.ToHalfWidth(strs[0])
+
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	if m := isoDateRegexp.FindStringSubmatch(str); m != nil {
+		dateTime, err := newDateTime(e.location(), atoi(m[1]), atoi(m[2]), atoi(m[3]), atoi(m[4]), atoi(m[5]), atoi(m[6]))
+		if err != nil {
+			return err
+		}
+		return dateTime
+	}
+
+	m := japaneseDateRegexp.FindStringSubmatch(str)
+	if m == nil {
+		return newError("「%s」は日時として読めません。", strs[0])
+	}
+	era, year := m[1], atoi(m[2])
+	if m[2] == "元" {
+		if era == "" {
+			return newError("「%s」は日時として読めません。", strs[0])
+		}
+		year = 1
+	}
+	if era != "" {
+		for _, v := range eras {
+			if v.name == era {
+				year += v.first - 1
+			}
+		}
+	}
+
+	dateTime, err := newDateTime(e.location(), year, atoi(m[3]), atoi(m[4]), atoi(m[6]), atoi(m[7]), atoi(m[8]))
+	if err != nil {
+		return err
+	}
+	if era != "" {
+		if actual, _, ok := japaneseEra(dateTime.Value); !ok || actual != era {
+			return newError("%s%s年%s月%s日は存在しない日付です。", era, m[2], m[3], m[4])
+		}
+	}
+	if m[5] != "" && m[5] != weekdays[dateTime.Value.Weekday()] {
+		return newError("%sの曜日は%sではありません。", strs[0], m[5])
+	}
+	return dateTime
+}
+
+var formatFieldRegexp = regexp.MustCompile(`\{([^{}:]*)(?::(\d+))?\}`)
+
+// 書式(日時、"{年}/{月:2}/{日:2}") のように書く。「:2」は0で埋める桁数。
+// 使える項目は年・月・日・時・分・秒・曜日・元号・和暦年
+func dateTimeFormat(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("書式", "書式(日時、書式=\"{年}-{月:2}-{日:2} {時:2}:{分:2}:{秒:2}\")")
+	}
+	t, err := dateTimeArg("書式", args[0])
+	if err != nil {
+		return err
+	}
+	format := "{年}-{月:2}-{日:2} {時:2}:{分:2}:{秒:2}"
+	if len(args) == 2 {
+		strs, err := stringArgs("書式", args[1:])
+		if err != nil {
+			return err
+		}
+		format = strs[0]
+	}
+
+	era, year, hasEra := japaneseEra(t)
+	var fieldErr *object.Error
+	res := formatFieldRegexp.ReplaceAllStringFunc(format, func(s string) string {
+		m := formatFieldRegexp.FindStringSubmatch(s)
+		var value string
+		if field, ok := dateTimeFields[m[1]]; ok {
+			value = strconv.Itoa(field(t))
+		} else {
+			switch m[1] {
+			case "曜日":
+				value = weekdays[t.Weekday()]
+			case "元号", "和暦年":
+				if !hasEra {
+					fieldErr = newError("%sは和暦に変換できません。", t.Format("2006-01-02"))
+					return s
+				}
+				value = era
+				if m[1] == "和暦年" {
+					value = eraYear(year)
+				}
+			default:
+				fieldErr = newError("書式の{%s}は日時の項目ではありません。", m[1])
+				return s
+			}
+		}
+
+		width, _ := strconv.Atoi(m[2])
+		for len(value) < width {
+			value = "0" + value
+		}
+		return value
+	})
+	if fieldErr != nil {
+		return fieldErr
+	}
+	return &object.String{Value: res}
+}
+
+// 令和6年10月17日 の形にする
+func dateTimeJapanese(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("和暦", "和暦(日時)")
+	}
+	return dateTimeFormat(args[0], &object.String{Value: "{元号}{和暦年}年{月}月{日}日"})
+}
+
+func dateTimeWeekday(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("曜日", "曜日(日時)")
+	}
+	t, err := dateTimeArg("曜日", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: weekdays[t.Weekday()] + "曜日"}
+}
+
+func dateTimeField(name string, field func(time.Time) int) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newArgCountError(name, name+"(日時)")
+		}
+		t, err := dateTimeArg(name, args[0])
+		if err != nil {
+			return err
+		}
+		return &object.Integer{Value: field(t)}
+	}
+}
+
+var dateTimeUnits = map[string]time.Duration{
+	"週":  7 * 24 * time.Hour,
+	"日":  24 * time.Hour,
+	"時間": time.Hour,
+	"分":  time.Minute,
+	"秒":  time.Second,
+}
+
+// 月を足す。1月31日の1か月後は2月の末日にする
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	first = first.AddDate(0, months, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if t.Day() < lastDay {
+		lastDay = t.Day()
+	}
+	return first.AddDate(0, 0, lastDay-1)
+}
+
+func unitArg(name string, arg object.Object) (string, *object.Error) {
+	strs, err := stringArgs(name, []object.Object{arg})
+	if err != nil {
+		return "", err
+	}
+	if _, ok := dateTimeUnits[strs[0]]; !ok && strs[0] != "年" && strs[0] != "月" {
+		return "", newError("「%s」は日時の単位ではありません。年、月、週、日、時間、分、秒のどれかを指定してください。", strs[0])
+	}
+	return strs[0], nil
+}
+
+// 足す(日時、3、"日") のように使う。負の数を渡すと戻る
+func dateTimeAdd(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newArgCountError("足す", "足す(日時、数、単位)")
+	}
+	t, err := dateTimeArg("足す", args[0])
+	if err != nil {
+		return err
+	}
+	nums, err := integerArgs("足す", args[1:2])
+	if err != nil {
+		return err
+	}
+	unit, err := unitArg("足す", args[2])
+	if err != nil {
+		return err
+	}
+
+	n := nums[0]
+	switch unit {
+	case "年", "月":
+		if unit == "年" {
+			if n > math.MaxInt/12 || n < math.MinInt/12 {
+				return newDateTimeRangeError()
+			}
+			n *= 12
+		}
+		res := addMonths(t, n)
+		// 大きすぎる年は time.Time の中で桁あふれするので、年が合っているかで確かめる
+		year, month := t.Year()+n/12, int(t.Month())-1+n%12
+		if month < 0 {
+			year--
+		} else if month >= 12 {
+			year++
+		}
+		if res.Year() != year {
+			return newDateTimeRangeError()
+		}
+		return &object.DateTime{Value: res}
+	default:
+		// time.Duration は三百年ほどまでしか表せないので、秒で足す
+		secs := int64(dateTimeUnits[unit] / time.Second)
+		if int64(n) > maxDateTimeSeconds/secs || int64(n) < -maxDateTimeSeconds/secs {
+			return newDateTimeRangeError()
+		}
+		res := t.Unix() + int64(n)*secs
+		if res > maxDateTimeSeconds || res < -maxDateTimeSeconds {
+			return newDateTimeRangeError()
+		}
+		return &object.DateTime{Value: time.Unix(res, int64(t.Nanosecond())).In(t.Location())}
+	}
+}
+
+// 足すで作れる日時の範囲。1970年からの秒数で、time.Time が桁あふれせずに扱える大きさにとどめる
+const maxDateTimeSeconds = 1 << 62
+
+func newDateTimeRangeError() *object.Error {
+	return newError("日時の計算が扱える範囲を超えました。")
+}
+
+// to から from を引いた長さを unit の数で返す。端数は0の方へ切り捨てる。
+// time.Time.Sub は三百年ほどで頭打ちになるので、秒と端数のナノ秒に分けて計算する
+func diffUnits(to time.Time, from time.Time, unit time.Duration) int {
+	secs := to.Unix() - from.Unix()
+	unitSecs := int64(unit / time.Second)
+	q := secs / unitSecs
+	r := time.Duration(secs%unitSecs)*time.Second + time.Duration(to.Nanosecond()-from.Nanosecond())
+	q += int64(r / unit)
+	r %= unit
+	if q > 0 && r < 0 {
+		q--
+	} else if q < 0 && r > 0 {
+		q++
+	}
+	return int(q)
+}
+
+// 差(後、前、単位="日") は後から前までの長さを単位の数で返す。端数は切り捨てる
+func dateTimeDiff(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newArgCountError("差", "差(日時、日時、単位=\"日\")")
+	}
+	to, err := dateTimeArg("差", args[0])
+	if err != nil {
+		return err
+	}
+	from, err := dateTimeArg("差", args[1])
+	if err != nil {
+		return err
+	}
+	unit := "日"
+	if len(args) == 3 {
+		if unit, err = unitArg("差", args[2]); err != nil {
+			return err
+		}
+	}
+
+	if unit != "年" && unit != "月" {
+		return &object.Integer{Value: diffUnits(to, from, dateTimeUnits[unit])}
+	}
+
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	if months > 0 && addMonths(from, months).After(to) {
+		months--
+	} else if months < 0 && addMonths(from, months).Before(to) {
+		months++
+	}
+	if unit == "年" {
+		return &object.Integer{Value: months / 12}
+	}
+	return &object.Integer{Value: months}
+}
@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+func newFixedClockEvaluator() *Evaluator {
+	jst := time.FixedZone("JST", 9*60*60)
+	e := New()
+	e.Clock = func() time.Time { return time.Date(2024, 10, 17, 9, 30, 15, 0, jst) }
+	return e
+}
+
+func TestDateTimeModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`日時.現在()`, "2024-10-17 09:30:15"},
+			{`日時.作成(2024、2、29)`, "2024-02-29 00:00:00"},
+			{`日時.作成(2024、2、29、23、59)`, "2024-02-29 23:59:00"},
+			{`日時.解析("2024-10-01")`, "2024-10-01 00:00:00"},
+			{`日時.解析("2024/1/2 3:04:05")`, "2024-01-02 03:04:05"},
+			{`日時.解析("２０２４年１０月１７日")`, "2024-10-17 00:00:00"},
+			{`日時.解析("令和6年10月17日(木) 9時30分")`, "2024-10-17 09:30:00"},
+			{`日時.解析("令和元年5月1日")`, "2019-05-01 00:00:00"},
+			{`日時.解析("平成31年4月30日（火曜日）")`, "2019-04-30 00:00:00"},
+			{`日時.解析("昭和64年1月7日")`, "1989-01-07 00:00:00"},
+			{`日時.和暦(日時.現在())`, "令和6年10月17日"},
+			{`日時.和暦(日時.作成(2019、5、1))`, "令和元年5月1日"},
+			{`日時.和暦(日時.作成(2019、4、30))`, "平成31年4月30日"},
+			{`日時.和暦(日時.作成(1926、12、24))`, "大正15年12月24日"},
+			{`日時.和暦(日時.作成(1900、1、1))`, "明治33年1月1日"},
+			{`日時.和暦(日時.作成(1873、1、1))`, "明治6年1月1日"},
+			{`日時.解析("明治33年1月1日")`, "1900-01-01 00:00:00"},
+			{`日時.曜日(日時.現在())`, "木曜日"},
+			{`日時.書式(日時.現在())`, "2024-10-17 09:30:15"},
+			{`日時.書式(日時.現在()、"{元号}{和暦年}年{月:2}月{日}日({曜日}) {時}時")`, "令和6年10月17日(木) 9時"},
+			{`日時.年(日時.現在()) + 日時.月(日時.現在()) + 日時.秒(日時.現在())`, "2049"},
+			{`日時.足す(日時.現在()、3、"日")`, "2024-10-20 09:30:15"},
+			{`日時.足す(日時.現在()、-2、"時間")`, "2024-10-17 07:30:15"},
+			{`日時.足す(日時.作成(2024、1、31)、1、"月")`, "2024-02-29 00:00:00"},
+			{`日時.足す(日時.作成(2024、2、29)、1、"年")`, "2025-02-28 00:00:00"},
+			{`日時.足す(日時.作成(2024、12、31)、1、"週")`, "2025-01-07 00:00:00"},
+			{`日時.差(日時.作成(2024、12、25)、日時.作成(2024、10、17、12))`, "68"},
+			{`日時.差(日時.作成(2024、10、17)、日時.作成(2024、10、17、1)、"分")`, "-60"},
+			{`日時.差(日時.作成(2024、3、30)、日時.作成(2024、1、31)、"月")`, "1"},
+			{`日時.差(日時.作成(2024、10、16)、日時.作成(2000、10、17)、"年")`, "23"},
+			{`日時.差(日時.作成(2000、10、17)、日時.作成(2024、10、17)、"年")`, "-24"},
+			{`日時.差(日時.作成(2500、1、1)、日時.作成(2000、1、1))`, "182622"},
+			{`日時.差(日時.作成(2000、1、1)、日時.作成(2500、1、1)、"秒")`, "-15778540800"},
+			{`日時.差(日時.作成(2024、10、17、0、0、1)、日時.作成(2024、10、17、0、1)、"分")`, "0"},
+			{`日時.足す(日時.作成(2000、1、1)、182622、"日")`, "2500-01-01 00:00:00"},
+			{`日時.作成(2024、1、1) < 日時.作成(2024、1、2)`, "true"},
+			{`日時.作成(2024、1、1) >= 日時.作成(2024、1、2)`, "false"},
+			{`日時.解析("2024-10-17 09:30:15") == 日時.現在()`, "true"},
+			{`a = {日時.作成(2024、1、1): "元日"} a[日時.解析("2024年1月1日")]`, "元日"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"日時\" " + v.input
+			if val := testEvalWith(t, newFixedClockEvaluator(), input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestDateTimeModuleErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`日時.作成(2023、2、29)`, "Error:2023年2月29日 0時0分0秒は存在しない日時です。"},
+			{`日時.作成(2023、2)`, "Error:関数作成の引数の個数が正しくありません。期待される形式: 作成(年、月、日、時=0、分=0、秒=0)"},
+			{`日時.作成(2023、2、1.5)`, "Error:関数作成の引数には整数が必要です。"},
+			{`日時.解析("明日")`, "Error:「明日」は日時として読めません。"},
+			{`日時.解析("元年1月1日")`, "Error:「元年1月1日」は日時として読めません。"},
+			{`日時.解析("平成32年1月1日")`, "Error:平成32年1月1日は存在しない日付です。"},
+			{`日時.解析("令和元年4月30日")`, "Error:令和元年4月30日は存在しない日付です。"},
+			{`日時.解析("明治5年12月31日")`, "Error:明治5年12月31日は存在しない日付です。"},
+			{`日時.解析("2024年10月17日(金)")`, "Error:2024年10月17日(金)の曜日は金ではありません。"},
+			{`日時.和暦(日時.作成(1872、12、31))`, "Error:1872-12-31は和暦に変換できません。"},
+			{`日時.書式(日時.現在()、"{年度}")`, "Error:書式の{年度}は日時の項目ではありません。"},
+			{`日時.足す(日時.現在()、9223372036854775807、"時間")`, "Error:日時の計算が扱える範囲を超えました。"},
+			{`日時.足す(日時.現在()、-9223372036854775807、"秒")`, "Error:日時の計算が扱える範囲を超えました。"},
+			{`日時.足す(日時.現在()、9223372036854775807、"年")`, "Error:日時の計算が扱える範囲を超えました。"},
+			{`日時.足す(日時.現在()、100000000000000000、"月")`, "Error:日時の計算が扱える範囲を超えました。"},
+			{`日時.足す(日時.現在()、1、"世紀")`, "Error:「世紀」は日時の単位ではありません。年、月、週、日、時間、分、秒のどれかを指定してください。"},
+			{`日時.曜日("2024-10-17")`, "Error:関数曜日の引数には日時が必要です。"},
+			{`日時.現在() + 日時.現在()`, "Error:日時に使えない演算子です。日時の計算には日時.足すと日時.差を使ってください。"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"日時\" " + v.input
+			if val := testEvalWith(t, newFixedClockEvaluator(), input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package evaluator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"jpl/object"
+	"jpl/utils"
+)
+
+// ファイル操作の権限。信頼できないスクリプトを動かすときに制限する
+type FilePolicy struct {
+	// trueならファイル操作をすべて禁止する
+	Deny bool
+	// trueなら書く・追記を禁止する
+	ReadOnly bool
+	// 空でなければこのディレクトリの中だけ操作できる。相対パスもここから探す。
+	// 読み込むでファイルのモジュールを読む時は、Deny と ReadOnly は使わず Root だけを使う
+	Root string
+}
+
+// 文字コードの変換。Decodeはファイルの内容をUTF-8に、EncodeはUTF-8をファイルの文字コードにする。
+// たとえばShift_JISは golang.org/x/text/encoding/japanese の Decoder と Encoder の Bytes を登録すればよい
+type Encoding struct {
+	Decode func([]byte) ([]byte, error)
+	Encode func([]byte) ([]byte, error)
+}
+
+var utf8Encoding = Encoding{
+	Decode: func(b []byte) ([]byte, error) {
+		if !utf8.Valid(b) {
+			return nil, errors.New("UTF-8として読めない文字が含まれています。")
+		}
+		return b, nil
+	},
+	Encode: func(b []byte) ([]byte, error) { return b, nil },
+}
+
+func (e *Evaluator) fileModule() map[string]object.Object {
+	return map[string]object.Object{
+		"読む":   newBuiltin("読む", e.fileRead),
+		"書く":   newBuiltin("書く", e.fileWriter("書く", os.O_TRUNC)),
+		"追記":   newBuiltin("追記", e.fileWriter("追記", os.O_APPEND)),
+		"存在する": newBuiltin("存在する", e.fileExists),
+		"一覧":   newBuiltin("一覧", e.fileList),
+	}
+}
+
+// 文字コードの名前は大文字小文字・「-」「_」・全角半角を区別しない
+func normalizeEncodingName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(utils.ToHalfWidth(name)))
+}
+
+func (e *Evaluator) lookUpEncoding(name string) (Encoding, bool) {
+	for k, v := range e.Encodings {
+		if normalizeEncodingName(k) == normalizeEncodingName(name) {
+			return v, true
+		}
+	}
+	return Encoding{}, false
+}
+
+// 省略できる文字コードの引数を取り出す
+func (e *Evaluator) encodingArg(name string, args []object.Object) (Encoding, *object.Error) {
+	if len(args) == 0 {
+		return utf8Encoding, nil
+	}
+	strs, err := stringArgs(name, args)
+	if err != nil {
+		return Encoding{}, err
+	}
+	encoding, ok := e.lookUpEncoding(strs[0])
+	if !ok {
+		return Encoding{}, newError("文字コード「%s」には対応していません。", strs[0])
+	}
+	return encoding, nil
+}
+
+// 権限を確かめて、操作するファイルの絶対パスを返す
+func (e *Evaluator) filePath(path string, write bool) (string, *object.Error) {
+	if e.Files.Deny {
+		return "", newError("ファイル操作は許可されていません。")
+	}
+	if write && e.Files.ReadOnly {
+		return "", newError("ファイルへの書き込みは許可されていません。")
+	}
+	return e.confine(path)
+}
+
+// 許可されたディレクトリの中の実際のパスを返す。ディレクトリの制限がなければ絶対パスにするだけ
+func (e *Evaluator) confine(path string) (string, *object.Error) {
+	if e.Files.Root == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", newError("ファイル「%s」を開けません。%s", path, err)
+		}
+		return abs, nil
+	}
+
+	root, err := filepath.Abs(e.Files.Root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", newError("許可されたディレクトリ「%s」を開けません。", e.Files.Root)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	// シンボリックリンクで外に出られないよう、実際のパスで確かめる
+	real, err := evalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", newError("ファイル「%s」を開けません。%s", path, err)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newError("「%s」は許可されたディレクトリの外にあります。", path)
+	}
+	return real, nil
+}
+
+// まだ無いファイルは、存在する親ディレクトリまでのリンクを解決する
+func evalSymlinks(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	dir, base := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if dir == path {
+		return path, nil
+	}
+	realDir, err := evalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realDir, base), nil
+}
+
+func newFileError(path string, err error) *object.Error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return newError("ファイル「%s」が見つかりません。", path)
+	case errors.Is(err, fs.ErrPermission):
+		return newError("ファイル「%s」を操作する権限がありません。", path)
+	default:
+		return newError("ファイル「%s」を操作できません。%s", path, err)
+	}
+}
+
+func (e *Evaluator) fileRead(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("読む", "読む(パス、文字コード=\"UTF-8\")")
+	}
+	strs, err := stringArgs("読む", args[:1])
+	if err != nil {
+		return err
+	}
+	encoding, err := e.encodingArg("読む", args[1:])
+	if err != nil {
+		return err
+	}
+	path, err := e.filePath(strs[0], false)
+	if err != nil {
+		return err
+	}
+
+	content, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return newFileError(strs[0], readErr)
+	}
+	decoded, decodeErr := encoding.Decode(content)
+	if decodeErr != nil {
+		return newError("ファイル「%s」を読めません。%s", strs[0], decodeErr)
+	}
+	return &object.String{Value: string(decoded)}
+}
+
+// 書くはファイルを上書きし、追記は末尾に足す。どちらもファイルが無ければ作る
+func (e *Evaluator) fileWriter(name string, flag int) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newArgCountError(name, name+"(パス、内容、文字コード=\"UTF-8\")")
+		}
+		strs, err := stringArgs(name, args[:2])
+		if err != nil {
+			return err
+		}
+		encoding, err := e.encodingArg(name, args[2:])
+		if err != nil {
+			return err
+		}
+		path, err := e.filePath(strs[0], true)
+		if err != nil {
+			return err
+		}
+
+		encoded, encodeErr := encoding.Encode([]byte(strs[1]))
+		if encodeErr != nil {
+			return newError("ファイル「%s」に書けません。%s", strs[0], encodeErr)
+		}
+		file, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+		if openErr != nil {
+			return newFileError(strs[0], openErr)
+		}
+		_, writeErr := file.Write(encoded)
+		if closeErr := file.Close(); writeErr == nil {
+			writeErr = closeErr
+		}
+		if writeErr != nil {
+			return newFileError(strs[0], writeErr)
+		}
+		return NULL
+	}
+}
+
+func (e *Evaluator) fileExists(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("存在する", "存在する(パス)")
+	}
+	strs, err := stringArgs("存在する", args)
+	if err != nil {
+		return err
+	}
+	path, err := e.filePath(strs[0], false)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(path)
+	return &object.Boolean{Value: statErr == nil}
+}
+
+// ディレクトリの中の名前を並べて返す。ディレクトリの名前には「/」を付ける
+func (e *Evaluator) fileList(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newArgCountError("一覧", "一覧(ディレクトリ=\".\")")
+	}
+	strs, err := stringArgs("一覧", args)
+	if err != nil {
+		return err
+	}
+	dir := "."
+	if len(strs) == 1 {
+		dir = strs[0]
+	}
+	path, err := e.filePath(dir, false)
+	if err != nil {
+		return err
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil {
+		return newFileError(dir, statErr)
+	}
+	if !info.IsDir() {
+		return newError("「%s」はディレクトリではありません。", dir)
+	}
+	entries, readErr := os.ReadDir(path)
+	if readErr != nil {
+		return newFileError(dir, readErr)
+	}
+	names := []string{}
+	for _, v := range entries {
+		if v.IsDir() {
+			names = append(names, v.Name()+"/")
+		} else {
+			names = append(names, v.Name())
+		}
+	}
+	sort.Strings(names)
+	return newStringArray(names)
+}
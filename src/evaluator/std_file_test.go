@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"挨拶.txt":      "こんにちは\n",
+			"sub/a.txt":   "a",
+			"sub/b.txt":   "b",
+			"sub/c/d.txt": "d",
+		})
+		join := func(name string) string { return "\"" + filepath.Join(dir, name) + "\"" }
+
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"ファイル.読む(" + join("挨拶.txt") + ")", "こんにちは\n"},
+			{"ファイル.読む(" + join("挨拶.txt") + "、\"utf8\")", "こんにちは\n"},
+			{"ファイル.書く(" + join("新規.txt") + "、\"一行目\\n\") ファイル.読む(" + join("新規.txt") + ")", "一行目\n"},
+			{"ファイル.書く(" + join("挨拶.txt") + "、\"上書き\") ファイル.読む(" + join("挨拶.txt") + ")", "上書き"},
+			{"ファイル.追記(" + join("挨拶.txt") + "、\"と追記\") ファイル.読む(" + join("挨拶.txt") + ")", "上書きと追記"},
+			{"ファイル.追記(" + join("追記のみ.txt") + "、\"a\") ファイル.読む(" + join("追記のみ.txt") + ")", "a"},
+			{"ファイル.存在する(" + join("sub/a.txt") + ")", "true"},
+			{"ファイル.存在する(" + join("sub") + ")", "true"},
+			{"ファイル.存在する(" + join("無い.txt") + ")", "false"},
+			{"ファイル.一覧(" + join("sub") + ")", "[a.txt, b.txt, c/]"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"ファイル\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestFileModuleErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"sjis.txt": "\x82\xb1\x82\xf1",
+		})
+		join := func(name string) string { return "\"" + filepath.Join(dir, name) + "\"" }
+
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"ファイル.読む(" + join("無い.txt") + ")", "Error:ファイル「" + filepath.Join(dir, "無い.txt") + "」が見つかりません。"},
+			{"ファイル.読む(" + join("sjis.txt") + ")", "Error:ファイル「" + filepath.Join(dir, "sjis.txt") + "」を読めません。UTF-8として読めない文字が含まれています。"},
+			{"ファイル.読む(" + join("sjis.txt") + "、\"EUC-JP\")", "Error:文字コード「EUC-JP」には対応していません。"},
+			{"ファイル.読む(1)", "Error:関数読むの引数には文字列が必要です。"},
+			{"ファイル.書く(" + join("a.txt") + ")", "Error:関数書くの引数の個数が正しくありません。期待される形式: 書く(パス、内容、文字コード=\"UTF-8\")"},
+			{"ファイル.一覧(" + join("sjis.txt") + ")", "Error:「" + filepath.Join(dir, "sjis.txt") + "」はディレクトリではありません。"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"ファイル\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestFileEncoding(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{})
+		path := filepath.Join(dir, "a.txt")
+
+		// テスト用に各バイトを反転する文字コードを登録する
+		flip := func(b []byte) ([]byte, error) {
+			res := make([]byte, len(b))
+			for i, v := range b {
+				res[i] = ^v
+			}
+			return res, nil
+		}
+		e := New()
+		e.Encodings["Flip_Code"] = Encoding{Decode: flip, Encode: flip}
+
+		res := testEvalWith(t, e, "読み込む \"ファイル\" ファイル.書く(\""+path+"\"、\"あ\"、\"flip-code\") ファイル.読む(\""+path+"\"、\"ＦＬＩＰ＿ＣＯＤＥ\")")
+		if res.Inspect() != "あ" {
+			t.Fatalf("got=%s expect=あ\n", res.Inspect())
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect, _ := flip([]byte("あ")); !bytes.Equal(raw, expect) {
+			t.Fatalf("got=%v expect=%v\n", raw, expect)
+		}
+	})
+}
+
+func TestFilePolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		dir := writeFiles(t, map[string]string{
+			"root/a.txt": "中",
+			"secret.txt": "外",
+		})
+		root := filepath.Join(dir, "root")
+		if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			policy FilePolicy
+			input  string
+			expect string
+		}{
+			{FilePolicy{Deny: true}, "ファイル.存在する(\"a.txt\")", "Error:ファイル操作は許可されていません。"},
+			{FilePolicy{ReadOnly: true, Root: root}, "ファイル.読む(\"a.txt\")", "中"},
+			{FilePolicy{ReadOnly: true, Root: root}, "ファイル.書く(\"a.txt\"、\"x\")", "Error:ファイルへの書き込みは許可されていません。"},
+			{FilePolicy{Root: root}, "ファイル.書く(\"b.txt\"、\"x\") ファイル.一覧()", "[a.txt, b.txt, link.txt]"},
+			{FilePolicy{Root: root}, "ファイル.読む(\"../secret.txt\")", "Error:「" + filepath.Join(root, "../secret.txt") + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Root: root}, "ファイル.読む(\"" + filepath.Join(dir, "secret.txt") + "\")", "Error:「" + filepath.Join(dir, "secret.txt") + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Root: root}, "ファイル.読む(\"link.txt\")", "Error:「" + filepath.Join(root, "link.txt") + "」は許可されたディレクトリの外にあります。"},
+			{FilePolicy{Root: root}, "ファイル.書く(\"無い/c.txt\"、\"x\")", "Error:ファイル「無い/c.txt」が見つかりません。"},
+		}
+
+		for i, v := range tests {
+			e := New()
+			e.Files = v.policy
+			input := "読み込む \"ファイル\" " + v.input
+			if val := testEvalWith(t, e, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,455 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"jpl/object"
+)
+
+// JSON解析(文字列) はJSONの値をオブジェクトにする。オブジェクトのキーの順番は保つ
+func builtinJSONParse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("JSON解析", "JSON解析(文字列)")
+	}
+	strs, err := stringArgs("JSON解析", args)
+	if err != nil {
+		return err
+	}
+
+	p := &jsonParser{input: strs[0]}
+	p.skipSpace()
+	res := p.value()
+	if isError(res) {
+		return res
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.error("値の後に余分な文字があります。")
+	}
+	return res
+}
+
+type jsonParser struct {
+	input string
+	pos   int
+}
+
+// エラーの位置は行と列(文字数)で示す
+func (p *jsonParser) error(format string, a ...interface{}) *object.Error {
+	consumed := p.input[:p.pos]
+	line := strings.Count(consumed, "\n") + 1
+	column := utf8.RuneCountInString(consumed[strings.LastIndex(consumed, "\n")+1:]) + 1
+	return newError("JSONを解析できません。%d行%d列: %s", line, column, fmt.Sprintf(format, a...))
+}
+
+func (p *jsonParser) unexpected() *object.Error {
+	if p.pos >= len(p.input) {
+		return p.error("JSONが途中で終わっています。")
+	}
+	ch, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return p.error("予期しない文字「%c」があります。", ch)
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonParser) peek() byte {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *jsonParser) value() object.Object {
+	switch ch := p.peek(); {
+	case ch == '{':
+		return p.object()
+	case ch == '[':
+		return p.array()
+	case ch == '"':
+		str, err := p.string()
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: str}
+	case ch == '-' || ('0' <= ch && ch <= '9'):
+		return p.number()
+	case strings.HasPrefix(p.input[p.pos:], "true"):
+		p.pos += len("true")
+		return &object.Boolean{Value: true}
+	case strings.HasPrefix(p.input[p.pos:], "false"):
+		p.pos += len("false")
+		return &object.Boolean{Value: false}
+	case strings.HasPrefix(p.input[p.pos:], "null"):
+		p.pos += len("null")
+		return NULL
+	default:
+		return p.unexpected()
+	}
+}
+
+func (p *jsonParser) object() object.Object {
+	hash := object.NewHash()
+	p.pos++
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return hash
+	}
+
+	for {
+		if p.peek() != '"' {
+			return p.unexpected()
+		}
+		key, err := p.string()
+		if err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.peek() != ':' {
+			return p.unexpected()
+		}
+		p.pos++
+		p.skipSpace()
+		value := p.value()
+		if isError(value) {
+			return value
+		}
+		hash.Set(&object.String{Value: key}, value)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case '}':
+			p.pos++
+			return hash
+		default:
+			return p.unexpected()
+		}
+	}
+}
+
+func (p *jsonParser) array() object.Object {
+	elements := []object.Object{}
+	p.pos++
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return &object.Array{Elements: elements}
+	}
+
+	for {
+		value := p.value()
+		if isError(value) {
+			return value
+		}
+		elements = append(elements, value)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case ']':
+			p.pos++
+			return &object.Array{Elements: elements}
+		default:
+			return p.unexpected()
+		}
+	}
+}
+
+var jsonEscapes = map[byte]rune{
+	'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t',
+}
+
+func (p *jsonParser) string() (string, *object.Error) {
+	var out strings.Builder
+	p.pos++
+
+	for {
+		if p.pos >= len(p.input) {
+			return "", p.error("文字列が閉じられていません。")
+		}
+		ch, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		switch {
+		case ch == '"':
+			p.pos++
+			return out.String(), nil
+		case ch < 0x20:
+			return "", p.error("文字列に制御文字を直接書くことはできません。")
+		case ch == '\\':
+			p.pos++
+			if ch, ok := jsonEscapes[p.peek()]; ok {
+				out.WriteRune(ch)
+				p.pos++
+				continue
+			}
+			if p.peek() != 'u' {
+				p.pos--
+				return "", p.error("不正なエスケープです。")
+			}
+			ch, err := p.unicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			out.WriteRune(ch)
+		default:
+			out.WriteRune(ch)
+			p.pos += size
+		}
+	}
+}
+
+// \uXXXX を読む。サロゲートペアは二つ続けて一文字にする
+func (p *jsonParser) unicodeEscape() (rune, *object.Error) {
+	read := func() (rune, bool) {
+		if p.pos+5 > len(p.input) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(p.input[p.pos+1:p.pos+5], 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		p.pos += 5
+		return rune(n), true
+	}
+
+	start := p.pos - 1
+	ch, ok := read()
+	if !ok {
+		p.pos = start
+		return 0, p.error("不正なエスケープです。")
+	}
+	if utf16.IsSurrogate(ch) && strings.HasPrefix(p.input[p.pos:], "\\u") {
+		p.pos++
+		if low, ok := read(); ok {
+			return utf16.DecodeRune(ch, low), nil
+		}
+		p.pos = start
+		return 0, p.error("不正なエスケープです。")
+	}
+	return ch, nil
+}
+
+// 小数点も指数も無ければ整数にする
+func (p *jsonParser) number() object.Object {
+	start := p.pos
+	digits := func() int {
+		n := 0
+		for '0' <= p.peek() && p.peek() <= '9' {
+			p.pos++
+			n++
+		}
+		return n
+	}
+
+	if p.peek() == '-' {
+		p.pos++
+	}
+	if p.peek() == '0' {
+		p.pos++
+	} else if digits() == 0 {
+		return p.unexpected()
+	}
+	isFloat := false
+	if p.peek() == '.' {
+		isFloat = true
+		p.pos++
+		if digits() == 0 {
+			return p.unexpected()
+		}
+	}
+	if p.peek() == 'e' || p.peek() == 'E' {
+		isFloat = true
+		p.pos++
+		if p.peek() == '+' || p.peek() == '-' {
+			p.pos++
+		}
+		if digits() == 0 {
+			return p.unexpected()
+		}
+	}
+
+	literal := p.input[start:p.pos]
+	if !isFloat {
+		if n, err := strconv.Atoi(literal); err == nil {
+			return &object.Integer{Value: n}
+		}
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		p.pos = start
+		return p.error("数値「%s」は大きすぎます。", literal)
+	}
+	return &object.Float{Value: f}
+}
+
+// 数で指定する字下げの上限。JavaScript の JSON.stringify にそろえる
+const maxJSONIndent = 10
+
+// JSON化(値、字下げ=0) は値をJSONの文字列にする。
+// 字下げに数を渡すとその数の空白で、文字列を渡すとその文字列で字下げして整形する
+func builtinJSONStringify(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("JSON化", "JSON化(値、字下げ=0)")
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 || arg.Value > maxJSONIndent {
+				return newArgTypeError("JSON化", fmt.Sprintf("0から%dまでの字下げ", maxJSONIndent))
+			}
+			indent = strings.Repeat(" ", arg.Value)
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newArgTypeError("JSON化", "字下げの数か文字列")
+		}
+	}
+
+	w := &jsonWriter{indent: indent, visiting: map[object.Object]bool{}}
+	if err := w.write(args[0], 0); err != nil {
+		return err
+	}
+	return &object.String{Value: w.out.String()}
+}
+
+type jsonWriter struct {
+	out      strings.Builder
+	indent   string
+	visiting map[object.Object]bool // 循環の検出に使う
+}
+
+func (w *jsonWriter) newline(depth int) {
+	if w.indent != "" {
+		w.out.WriteString("\n" + strings.Repeat(w.indent, depth))
+	}
+}
+
+func (w *jsonWriter) write(obj object.Object, depth int) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		w.out.WriteString("null")
+	case *object.Boolean, *object.Integer:
+		w.out.WriteString(obj.Inspect())
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("%sはJSONに変換できません。", obj.Inspect())
+		}
+		w.out.WriteString(obj.Inspect())
+	case *object.String:
+		w.writeString(obj.Value)
+	case *object.Array:
+		return w.writeList(obj, obj.Elements, depth)
+	case *object.Tuple:
+		return w.writeList(obj, obj.Elements, depth)
+	case *object.Hash:
+		return w.writeHash(obj, depth)
+	default:
+		return newError("%sはJSONに変換できません。", obj.Inspect())
+	}
+	return nil
+}
+
+func (w *jsonWriter) enter(obj object.Object) *object.Error {
+	if w.visiting[obj] {
+		return newError("自分自身を含む値はJSONに変換できません。")
+	}
+	w.visiting[obj] = true
+	return nil
+}
+
+func (w *jsonWriter) writeList(obj object.Object, elements []object.Object, depth int) *object.Error {
+	if err := w.enter(obj); err != nil {
+		return err
+	}
+	defer delete(w.visiting, obj)
+
+	w.out.WriteByte('[')
+	for i, v := range elements {
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		w.newline(depth + 1)
+		if err := w.write(v, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(elements) > 0 {
+		w.newline(depth)
+	}
+	w.out.WriteByte(']')
+	return nil
+}
+
+// キーは文字列にする。整数と真偽値のキーは文字列に直す
+func (w *jsonWriter) writeHash(hash *object.Hash, depth int) *object.Error {
+	if err := w.enter(hash); err != nil {
+		return err
+	}
+	defer delete(w.visiting, hash)
+
+	w.out.WriteByte('{')
+	for i, k := range hash.Keys {
+		pair := hash.Pairs[k]
+		switch pair.Key.(type) {
+		case *object.String, *object.Integer, *object.Boolean:
+		default:
+			return newError("JSONのキーには%sを使えません。", pair.Key.Inspect())
+		}
+
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		w.newline(depth + 1)
+		w.writeString(pair.Key.Inspect())
+		w.out.WriteByte(':')
+		if w.indent != "" {
+			w.out.WriteByte(' ')
+		}
+		if err := w.write(pair.Value, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(hash.Keys) > 0 {
+		w.newline(depth)
+	}
+	w.out.WriteByte('}')
+	return nil
+}
+
+// 日本語などはエスケープせずにそのまま書く
+func (w *jsonWriter) writeString(str string) {
+	w.out.WriteByte('"')
+	for _, ch := range str {
+		switch {
+		case ch == '"' || ch == '\\':
+			w.out.WriteRune('\\')
+			w.out.WriteRune(ch)
+		case ch == '\n':
+			w.out.WriteString("\\n")
+		case ch == '\r':
+			w.out.WriteString("\\r")
+		case ch == '\t':
+			w.out.WriteString("\\t")
+		case ch < 0x20:
+			fmt.Fprintf(&w.out, "\\u%04x", ch)
+		default:
+			w.out.WriteRune(ch)
+		}
+	}
+	w.out.WriteByte('"')
+}
@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestJSONParse(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`JSON解析("{\"名前\": \"太郎\", \"年齢\": 20, \"趣味\": [\"囲碁\", \"将棋\"]}")`, "{名前: 太郎, 年齢: 20, 趣味: [囲碁, 将棋]}"},
+			{`JSON解析("{\"b\": 1, \"a\": 2, \"b\": 3}")`, "{b: 3, a: 2}"},
+			{`JSON解析("[1, -2, 3.5, 1e3, -0.25E-1]")`, "[1, -2, 3.5, 1000.0, -0.025]"},
+			{`JSON解析("[true, false, null]")`, "[true, false, null]"},
+			{`JSON解析(" \n [ ] ")`, "[]"},
+			{`JSON解析("{}")`, "{}"},
+			{`JSON解析("\"a\\\"b\\\\c\\/\\n\"")`, "a\"b\\c/\n"},
+			{`JSON解析("\"\\u3042\\ud83d\\ude00\"")`, "あ😀"},
+			{`JSON解析("99999999999999999999")`, "100000000000000000000.0"},
+			{`a = JSON解析("{\"x\": {\"y\": [10, 20]}}") a["x"]["y"][1]`, "20"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestJSONParseErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`JSON解析("")`, "Error:JSONを解析できません。1行1列: JSONが途中で終わっています。"},
+			{`JSON解析("{\"a\": 1,}")`, "Error:JSONを解析できません。1行9列: 予期しない文字「}」があります。"},
+			{`JSON解析("[1, 2\n 3]")`, "Error:JSONを解析できません。2行2列: 予期しない文字「3」があります。"},
+			{`JSON解析("{\"あいう\" 1}")`, "Error:JSONを解析できません。1行8列: 予期しない文字「1」があります。"},
+			{`JSON解析("[1] [2]")`, "Error:JSONを解析できません。1行5列: 値の後に余分な文字があります。"},
+			{`JSON解析("\"abc")`, "Error:JSONを解析できません。1行5列: 文字列が閉じられていません。"},
+			{`JSON解析("\"\\x\"")`, "Error:JSONを解析できません。1行2列: 不正なエスケープです。"},
+			{`JSON解析("\"\\u12\"")`, "Error:JSONを解析できません。1行2列: 不正なエスケープです。"},
+			{`JSON解析("[01]")`, "Error:JSONを解析できません。1行3列: 予期しない文字「1」があります。"},
+			{`JSON解析("[1.]")`, "Error:JSONを解析できません。1行4列: 予期しない文字「]」があります。"},
+			{`JSON解析("tru")`, "Error:JSONを解析できません。1行1列: 予期しない文字「t」があります。"},
+			{`JSON解析(1)`, "Error:関数JSON解析の引数には文字列が必要です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestJSONStringify(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`JSON化({"名前": "太郎", "年齢": 20})`, `{"名前":"太郎","年齢":20}`},
+			{`JSON化([1, 2.5, 3.0, "a\"b\n"])`, `[1,2.5,3.0,"a\"b\n"]`},
+			{`JSON化({1: [], "空": {}})`, `{"1":[],"空":{}}`},
+			{`関数 f() { 戻す 1、2 } JSON化(f())`, `[1,2]`},
+			{`JSON化({"a": [1, {"b": 2}]}, 2)`, "{\n  \"a\": [\n    1,\n    {\n      \"b\": 2\n    }\n  ]\n}"},
+			{`JSON化([1], "\t")`, "[\n\t1\n]"},
+			{`JSON化(JSON解析("{\"z\": 1, \"a\": [true, null]}"))`, `{"z":1,"a":[true,null]}`},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestJSONStringifyErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`JSON化(表示)`, "Error:組み込み関数(表示)はJSONに変換できません。"},
+			{`JSON化([1, [2, 表示]])`, "Error:組み込み関数(表示)はJSONに変換できません。"},
+			{`a = {} a["自分"] = a JSON化(a)`, "Error:自分自身を含む値はJSONに変換できません。"},
+			{`JSON化(1, -1)`, "Error:関数JSON化の引数には0から10までの字下げが必要です。"},
+			{`JSON化([1], 9223372036854775807)`, "Error:関数JSON化の引数には0から10までの字下げが必要です。"},
+			{`JSON化([1], 10)`, "[\n          1\n]"},
+			{`JSON化(1, 1.5)`, "Error:関数JSON化の引数には字下げの数か文字列が必要です。"},
+		}
+
+		for i, v := range tests {
+			if val := testEval(t, v.input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package evaluator
+
+import (
+	"math"
+
+	"jpl/object"
+)
+
+func (e *Evaluator) mathModule() map[string]object.Object {
+	return map[string]object.Object{
+		"円周率":   &object.Float{Value: math.Pi},
+		"ネイピア数": &object.Float{Value: math.E},
+
+		"絶対値":   newBuiltin("絶対値", mathAbs),
+		"最大":    newBuiltin("最大", mathExtremum("最大", 1)),
+		"最小":    newBuiltin("最小", mathExtremum("最小", -1)),
+		"平方根":   newBuiltin("平方根", mathSqrt),
+		"累乗":    newBuiltin("累乗", mathPow),
+		"切り捨て":  newBuiltin("切り捨て", mathRound("切り捨て", math.Floor)),
+		"切り上げ":  newBuiltin("切り上げ", mathRound("切り上げ", math.Ceil)),
+		"四捨五入":  newBuiltin("四捨五入", mathRound("四捨五入", math.Round)),
+		"最大公約数": newBuiltin("最大公約数", mathGcd),
+		"素数判定":  newBuiltin("素数判定", mathIsPrime),
+
+		"正弦":  newBuiltin("正弦", mathFloatFunc("正弦", math.Sin)),
+		"余弦":  newBuiltin("余弦", mathFloatFunc("余弦", math.Cos)),
+		"正接":  newBuiltin("正接", mathFloatFunc("正接", math.Tan)),
+		"逆正弦": newBuiltin("逆正弦", mathFloatFunc("逆正弦", math.Asin)),
+		"逆余弦": newBuiltin("逆余弦", mathFloatFunc("逆余弦", math.Acos)),
+		"逆正接": newBuiltin("逆正接", mathFloatFunc("逆正接", math.Atan)),
+	}
+}
+
+func mathAbs(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("絶対値", "絶対値(数)")
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	default:
+		return newArgTypeError("絶対値", "数値")
+	}
+}
+
+// 最大と最小。引数に数を並べるか、数の配列を一つ渡す。sign が1なら最大、-1なら最小を返す
+func mathExtremum(name string, sign float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) == 1 {
+			if array, ok := args[0].(*object.Array); ok {
+				args = array.Elements
+			}
+		}
+		if len(args) == 0 {
+			return newError("関数%sには一つ以上の数値が必要です。", name)
+		}
+
+		var res object.Object
+		var resVal float64
+		for _, v := range args {
+			val, ok := toFloat(v)
+			if !ok {
+				return newArgTypeError(name, "数値")
+			}
+			if res == nil || val*sign > resVal*sign {
+				res = v
+				resVal = val
+			}
+		}
+		return res
+	}
+}
+
+func mathSqrt(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("平方根", "平方根(数)")
+	}
+
+	val, ok := toFloat(args[0])
+	if !ok {
+		return newArgTypeError("平方根", "数値")
+	}
+	if val < 0 {
+		return newError("負の数の平方根は求められません。")
+	}
+	return &object.Float{Value: math.Sqrt(val)}
+}
+
+// 整数の0以上の整数乗は整数で、それ以外は小数で返す
+func mathPow(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newArgCountError("累乗", "累乗(底, 指数)")
+	}
+
+	base, bok := args[0].(*object.Integer)
+	exp, eok := args[1].(*object.Integer)
+	if bok && eok && exp.Value >= 0 {
+		res := 1
+		b := base.Value
+		for n := exp.Value; n > 0; n >>= 1 {
+			if n&1 == 1 {
+				res *= b
+			}
+			b *= b
+		}
+		return &object.Integer{Value: res}
+	}
+
+	x, xok := toFloat(args[0])
+	y, yok := toFloat(args[1])
+	if !xok || !yok {
+		return newArgTypeError("累乗", "数値")
+	}
+	return &object.Float{Value: math.Pow(x, y)}
+}
+
+// 切り捨て、切り上げ、四捨五入。桁を指定すると小数点以下その桁までの小数を返す
+func mathRound(name string, round func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newArgCountError(name, name+"(数, 桁=0)")
+		}
+
+		val, ok := toFloat(args[0])
+		if !ok {
+			return newArgTypeError(name, "数値")
+		}
+
+		if len(args) == 2 {
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return newArgTypeError(name, "整数の桁")
+			}
+			scale := math.Pow(10, float64(digits.Value))
+			return &object.Float{Value: round(val*scale) / scale}
+		}
+
+		if integer, ok := args[0].(*object.Integer); ok {
+			return integer
+		}
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return newError("関数%sには有限の数値が必要です。", name)
+		}
+		res := round(val)
+		// float64(math.MaxInt) は math.MaxInt より一つ大きい
+		if res < math.MinInt || res >= math.MaxInt {
+			return newError("関数%sには整数の範囲に収まる数値が必要です。", name)
+		}
+		return &object.Integer{Value: int(res)}
+	}
+}
+
+func mathGcd(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newArgCountError("最大公約数", "最大公約数(a, b)")
+	}
+
+	a, aok := args[0].(*object.Integer)
+	b, bok := args[1].(*object.Integer)
+	if !aok || !bok {
+		return newArgTypeError("最大公約数", "整数")
+	}
+
+	x, y := a.Value, b.Value
+	if x < 0 {
+		x = -x
+	}
+	if y < 0 {
+		y = -y
+	}
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return &object.Integer{Value: x}
+}
+
+func mathIsPrime(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("素数判定", "素数判定(整数)")
+	}
+
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newArgTypeError("素数判定", "整数")
+	}
+
+	if n.Value < 2 {
+		return &object.Boolean{Value: false}
+	}
+	for i := 2; i <= n.Value/i; i++ {
+		if n.Value%i == 0 {
+			return &object.Boolean{Value: false}
+		}
+	}
+	return &object.Boolean{Value: true}
+}
+
+// 小数を受け取り小数を返す関数。結果が数にならない場合は定義域の外とする
+func mathFloatFunc(name string, fn func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newArgCountError(name, name+"(数)")
+		}
+
+		val, ok := toFloat(args[0])
+		if !ok {
+			return newArgTypeError(name, "数値")
+		}
+
+		res := fn(val)
+		if math.IsNaN(res) {
+			return newError("関数%sの定義域の外です。", name)
+		}
+		return &object.Float{Value: res}
+	}
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestMathModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"数学.絶対値(-5)", "5"},
+			{"数学.絶対値(5)", "5"},
+			{"数学.絶対値(-2.5)", "2.5"},
+			{"数学.最大(3、9、2)", "9"},
+			{"数学.最大([3、9.5、2])", "9.5"},
+			{"数学.最小(3、-9、2)", "-9"},
+			{"数学.最小(4)", "4"},
+			{"数学.平方根(16)", "4.0"},
+			{"数学.平方根(2)", "1.4142135623730951"},
+			{"数学.平方根(0)", "0.0"},
+			{"数学.累乗(2、10)", "1024"},
+			{"数学.累乗(-3、3)", "-27"},
+			{"数学.累乗(5、0)", "1"},
+			{"数学.累乗(2、-1)", "0.5"},
+			{"数学.累乗(4、0.5)", "2.0"},
+			{"数学.切り捨て(2.7)", "2"},
+			{"数学.切り捨て(-2.2)", "-3"},
+			{"数学.切り捨て(7)", "7"},
+			{"数学.切り上げ(2.1)", "3"},
+			{"数学.切り上げ(-2.7)", "-2"},
+			{"数学.四捨五入(2.5)", "3"},
+			{"数学.四捨五入(-2.5)", "-3"},
+			{"数学.四捨五入(2.4)", "2"},
+			{"数学.切り上げ(数学.累乗(2.0、62))", "4611686018427387904"},
+			{"数学.四捨五入(3.14159、2)", "3.14"},
+			{"数学.切り捨て(3.14159、3)", "3.141"},
+			{"数学.最大公約数(12、18)", "6"},
+			{"数学.最大公約数(-12、18)", "6"},
+			{"数学.最大公約数(0、0)", "0"},
+			{"数学.最大公約数(7、0)", "7"},
+			{"数学.素数判定(2)", "true"},
+			{"数学.素数判定(97)", "true"},
+			{"数学.素数判定(1)", "false"},
+			{"数学.素数判定(0)", "false"},
+			{"数学.素数判定(-7)", "false"},
+			{"数学.素数判定(91)", "false"},
+			{"数学.正弦(0)", "0.0"},
+			{"数学.余弦(0)", "1.0"},
+			{"数学.四捨五入(数学.正弦(数学.円周率 / 2)、6)", "1.0"},
+			{"数学.逆正接(1) * 4 == 数学.円周率", "true"},
+			{"数学.ネイピア数 > 2.718", "true"},
+			{"2 を 数学.平方根する", "1.4142135623730951"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"数学\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestMathModuleErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"数学.平方根(-1)", "Error:負の数の平方根は求められません。"},
+			{"数学.平方根(\"4\")", "Error:関数平方根の引数には数値が必要です。"},
+			{"数学.平方根(1、2)", "Error:関数平方根の引数の個数が正しくありません。期待される形式: 平方根(数)"},
+			{"数学.最大()", "Error:関数最大には一つ以上の数値が必要です。"},
+			{"数学.最大(1、\"2\")", "Error:関数最大の引数には数値が必要です。"},
+			{"数学.最大公約数(1.5、2)", "Error:関数最大公約数の引数には整数が必要です。"},
+			{"数学.素数判定(7.0)", "Error:関数素数判定の引数には整数が必要です。"},
+			{"数学.逆正弦(2)", "Error:関数逆正弦の定義域の外です。"},
+			{"数学.四捨五入(1.5、0.5)", "Error:関数四捨五入の引数には整数の桁が必要です。"},
+			{"数学.四捨五入(数学.累乗(10.0、300))", "Error:関数四捨五入には整数の範囲に収まる数値が必要です。"},
+			{"数学.切り捨て(9223372036854775807.0)", "Error:関数切り捨てには整数の範囲に収まる数値が必要です。"},
+			{"数学.切り上げ(-数学.累乗(10.0、19))", "Error:関数切り上げには整数の範囲に収まる数値が必要です。"},
+			{"数学.平方根(x: 1)", "Error:組み込み関数平方根には名前付き引数を使えません。"},
+			{"数学.存在しない(1)", "Error:関数が宣言されていません。"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"数学\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
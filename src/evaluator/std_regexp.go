@@ -0,0 +1,227 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"jpl/object"
+)
+
+// コンパイルしたパターンをいくつまで覚えておくか
+const regexpCacheSize = 256
+
+func (e *Evaluator) regexpModule() map[string]object.Object {
+	return map[string]object.Object{
+		"一致":   newBuiltin("一致", e.regexpMatch),
+		"検索":   newBuiltin("検索", e.regexpFind),
+		"全て検索": newBuiltin("全て検索", e.regexpFindAll),
+		"置換":   newBuiltin("置換", e.regexpReplace),
+	}
+}
+
+var regexpErrors = map[syntax.ErrorCode]string{
+	syntax.ErrMissingParen:          "括弧が閉じられていません。",
+	syntax.ErrUnexpectedParen:       "閉じ括弧に対応する開き括弧がありません。",
+	syntax.ErrMissingBracket:        "「[」が閉じられていません。",
+	syntax.ErrInvalidCharRange:      "文字の範囲が正しくありません。",
+	syntax.ErrMissingRepeatArgument: "繰り返す対象がありません。",
+	syntax.ErrInvalidRepeatOp:       "繰り返しの指定が正しくありません。",
+	syntax.ErrInvalidRepeatSize:     "繰り返しの回数が正しくありません。",
+	syntax.ErrInvalidEscape:         "エスケープが正しくありません。",
+	syntax.ErrTrailingBackslash:     "末尾に「\\」があります。",
+	syntax.ErrInvalidNamedCapture:   "名前付きグループが正しくありません。",
+	syntax.ErrInvalidPerlOp:         "「(?」の使い方が正しくありません。",
+}
+
+// コンパイル済みのパターン。Goの正規表現はグループ名に日本語を使えないので、
+// 名前を別名に置き換えてコンパイルし、元の名前を覚えておく
+type compiledRegexp struct {
+	re      *regexp.Regexp
+	names   []string          // グループの番号ごとの元の名前
+	aliases map[string]string // 元の名前から別名を引く
+}
+
+var groupNameRegexp = regexp.MustCompile(`^\(\?P?<([^>]*)>`)
+
+// (?P<名前> と (?<名前> の名前を別名に置き換える。文字クラスの中とエスケープされた括弧は置き換えない
+func replaceGroupNames(pattern string) (string, map[string]string) {
+	aliases := map[string]string{}
+	var out strings.Builder
+	inClass := false
+
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		if ch == '\\' && i+1 < len(pattern) {
+			out.WriteString(pattern[i : i+2])
+			i++
+			continue
+		}
+
+		if ch == '[' {
+			inClass = true
+		} else if ch == ']' {
+			inClass = false
+		} else if m := groupNameRegexp.FindStringSubmatch(pattern[i:]); ch == '(' && !inClass && m != nil {
+			alias, ok := aliases[m[1]]
+			if !ok {
+				alias = fmt.Sprintf("g%d", len(aliases))
+				aliases[m[1]] = alias
+			}
+			out.WriteString("(?P<" + alias + ">")
+			i += len(m[0]) - 1
+			continue
+		}
+		out.WriteByte(ch)
+	}
+	return out.String(), aliases
+}
+
+// パターンをコンパイルする。同じパターンは使い回す
+func (e *Evaluator) compileRegexp(pattern string) (*compiledRegexp, *object.Error) {
+	if compiled, ok := e.regexps[pattern]; ok {
+		return compiled, nil
+	}
+
+	replaced, aliases := replaceGroupNames(pattern)
+	re, err := regexp.Compile(replaced)
+	if err != nil {
+		message := err.Error()
+		if syntaxErr, ok := err.(*syntax.Error); ok {
+			if m, ok := regexpErrors[syntaxErr.Code]; ok {
+				message = m
+			}
+		}
+		return nil, newError("正規表現「%s」が正しくありません。%s", pattern, message)
+	}
+
+	compiled := &compiledRegexp{re: re, names: make([]string, re.NumSubexp()+1), aliases: aliases}
+	for name, alias := range aliases {
+		compiled.names[re.SubexpIndex(alias)] = name
+	}
+
+	if len(e.regexps) >= regexpCacheSize {
+		e.regexps = map[string]*compiledRegexp{}
+	}
+	e.regexps[pattern] = compiled
+	return compiled, nil
+}
+
+// 引数(文字列、パターン…)を取り出してパターンをコンパイルする
+func (e *Evaluator) regexpArgs(name string, signature string, count int, args []object.Object) ([]string, *compiledRegexp, *object.Error) {
+	if len(args) != count {
+		return nil, nil, newArgCountError(name, signature)
+	}
+	strs, err := stringArgs(name, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	compiled, err := e.compileRegexp(strs[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return strs, compiled, nil
+}
+
+// 一致した部分とグループを返す。
+// 名前付きグループがあれば、番号と名前の両方で引ける連想配列にする。一致しなかったグループは null になる
+func (c *compiledRegexp) match(str string, indexes []int) object.Object {
+	groups := []object.Object{}
+	for i := 0; i < len(indexes); i += 2 {
+		if indexes[i] < 0 {
+			groups = append(groups, NULL)
+		} else {
+			groups = append(groups, &object.String{Value: str[indexes[i]:indexes[i+1]]})
+		}
+	}
+
+	if len(c.aliases) == 0 {
+		return &object.Array{Elements: groups}
+	}
+
+	hash := object.NewHash()
+	for i, v := range groups {
+		hash.Set(&object.Integer{Value: i}, v)
+	}
+	for i, name := range c.names {
+		if name != "" {
+			hash.Set(&object.String{Value: name}, groups[i])
+		}
+	}
+	return hash
+}
+
+func (e *Evaluator) regexpMatch(args ...object.Object) object.Object {
+	strs, compiled, err := e.regexpArgs("一致", "一致(文字列、パターン)", 2, args)
+	if err != nil {
+		return err
+	}
+	return &object.Boolean{Value: compiled.re.MatchString(strs[0])}
+}
+
+// 最初に一致したものを返す。一致しなければ null を返す
+func (e *Evaluator) regexpFind(args ...object.Object) object.Object {
+	strs, compiled, err := e.regexpArgs("検索", "検索(文字列、パターン)", 2, args)
+	if err != nil {
+		return err
+	}
+
+	indexes := compiled.re.FindStringSubmatchIndex(strs[0])
+	if indexes == nil {
+		return NULL
+	}
+	return compiled.match(strs[0], indexes)
+}
+
+func (e *Evaluator) regexpFindAll(args ...object.Object) object.Object {
+	strs, compiled, err := e.regexpArgs("全て検索", "全て検索(文字列、パターン)", 2, args)
+	if err != nil {
+		return err
+	}
+
+	matches := []object.Object{}
+	for _, v := range compiled.re.FindAllStringSubmatchIndex(strs[0], -1) {
+		matches = append(matches, compiled.match(strs[0], v))
+	}
+	return &object.Array{Elements: matches}
+}
+
+var templateRefRegexp = regexp.MustCompile(`\$\$|\$\{[^}]*\}|\$\d+`)
+
+// 置換後の文字列では $1 や ${名前} でグループを参照できる。$$ は $ になる。
+// 「$1年」が「1年」という名前と読まれないよう、番号は数字までで区切る
+func (e *Evaluator) regexpReplace(args ...object.Object) object.Object {
+	strs, compiled, err := e.regexpArgs("置換", "置換(文字列、パターン、置換後)", 3, args)
+	if err != nil {
+		return err
+	}
+
+	template := templateRefRegexp.ReplaceAllStringFunc(strs[2], func(s string) string {
+		switch {
+		case s == "$$":
+			return s
+		case s[1] != '{':
+			return "${" + s[1:] + "}"
+		}
+		if alias, ok := compiled.aliases[s[2:len(s)-1]]; ok {
+			return "${" + alias + "}"
+		}
+		return s
+	})
+	if e.Limits.MaxStringSize == 0 {
+		return &object.String{Value: compiled.re.ReplaceAllString(strs[0], template)}
+	}
+
+	// 置き換えた後の長さは前もってわからないので、一致ごとに足しながら上限を確かめる
+	res, last := []byte{}, 0
+	for _, v := range compiled.re.FindAllStringSubmatchIndex(strs[0], -1) {
+		res = append(res, strs[0][last:v[0]]...)
+		res = compiled.re.ExpandString(res, template, strs[0], v)
+		last = v[1]
+		if err := e.reserveString(len(res) + len(strs[0]) - last); err != nil {
+			return err
+		}
+	}
+	return &object.String{Value: string(append(res, strs[0][last:]...))}
+}
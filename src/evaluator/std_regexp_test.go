@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestRegexpModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`正規表現.一致("東京都千代田区"、"^東京")`, "true"},
+			{`正規表現.一致("大阪府"、"^東京")`, "false"},
+			{`正規表現.一致("ひらがな"、"^\\p{Hiragana}+$")`, "true"},
+			{`正規表現.検索("電話: 03-1234-5678"、"(\\d+)-(\\d+)-(\\d+)")`, "[03-1234-5678, 03, 1234, 5678]"},
+			{`正規表現.検索("abc"、"\\d")`, "null"},
+			{`正規表現.検索("ab"、"a(x)?b")`, "[ab, null]"},
+			{`正規表現.検索("2024年10月"、"(?P<年>\\d+)年(?P<月>\\d+)月")`, "{0: 2024年10月, 1: 2024, 2: 10, 年: 2024, 月: 10}"},
+			{`m = 正規表現.検索("2024年10月"、"(?P<年>\\d+)年(\\d+)月") m["年"] + m[2]`, "202410"},
+			{`正規表現.全て検索("a1b22c333"、"\\d+")`, "[[1], [22], [333]]"},
+			{`正規表現.全て検索("abc"、"\\d+")`, "[]"},
+			{`正規表現.全て検索("x=1, y=2"、"(?P<名>\\w)=(?P<値>\\d)")`, "[{0: x=1, 1: x, 2: 1, 名: x, 値: 1}, {0: y=2, 1: y, 2: 2, 名: y, 値: 2}]"},
+			{`正規表現.置換("2024-10-17"、"(\\d+)-(\\d+)-(\\d+)"、"$1年$2月$3日")`, "2024年10月17日"},
+			{`正規表現.置換("田中 太郎"、"(?P<姓>\\S+) (?P<名>\\S+)"、"${名} ${姓}")`, "太郎 田中"},
+			{`正規表現.置換("a　b  c"、"[\\s　]+"、" ")`, "a b c"},
+			{`正規表現.置換("100"、"(\\d+)"、"$$$1円")`, "$100円"},
+			{`正規表現.置換("a(b)"、"\\((?P<中>\\w)\\)"、"[${中}]")`, "a[b]"},
+			{`正規表現.検索("x<y"、"[(?P<a>)]")`, "[<]"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"正規表現\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestRegexpModuleErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`正規表現.一致("a"、"(a")`, "Error:正規表現「(a」が正しくありません。括弧が閉じられていません。"},
+			{`正規表現.一致("a"、"[a")`, "Error:正規表現「[a」が正しくありません。「[」が閉じられていません。"},
+			{`正規表現.検索("a"、"*")`, "Error:正規表現「*」が正しくありません。繰り返す対象がありません。"},
+			{`正規表現.一致("a")`, "Error:関数一致の引数の個数が正しくありません。期待される形式: 一致(文字列、パターン)"},
+			{`正規表現.置換("a"、"a"、1)`, "Error:関数置換の引数には文字列が必要です。"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"正規表現\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestRegexpCache(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		e := New()
+		testEvalWith(t, e, `
+	読み込む "正規表現"
+	正規表現.一致("a"、"a+")
+	正規表現.検索("b"、"a+")
+	正規表現.一致("c"、"c")
+	`)
+		if len(e.regexps) != 2 {
+			t.Fatalf("got=%d expect=2\n", len(e.regexps))
+		}
+
+		re, _ := e.compileRegexp("a+")
+		if cached, _ := e.compileRegexp("a+"); cached != re {
+			t.Fatalf("同じパターンが使い回されていません。\n")
+		}
+
+		for i := 0; i < regexpCacheSize+10; i++ {
+			e.compileRegexp(string(rune('あ' + i)))
+		}
+		if len(e.regexps) > regexpCacheSize {
+			t.Fatalf("got=%d expect<=%d\n", len(e.regexps), regexpCacheSize)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package evaluator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"jpl/object"
+	"jpl/utils"
+)
+
+func (e *Evaluator) stringModule() map[string]object.Object {
+	return map[string]object.Object{
+		"長さ":   newBuiltin("長さ", stringLength),
+		"文字数":  newBuiltin("文字数", stringGraphemeCount),
+		"分割":   newBuiltin("分割", stringSplit),
+		"結合":   newBuiltin("結合", stringJoin),
+		"置換":   newBuiltin("置換", e.stringReplace),
+		"含む":   newBuiltin("含む", stringPredicate("含む", strings.Contains)),
+		"前方一致": newBuiltin("前方一致", stringPredicate("前方一致", strings.HasPrefix)),
+		"後方一致": newBuiltin("後方一致", stringPredicate("後方一致", strings.HasSuffix)),
+		"書式":   newBuiltin("書式", stringFormat),
+
+		"トリム":   newBuiltin("トリム", stringConv("トリム", strings.TrimSpace)),
+		"カタカナ化": newBuiltin("カタカナ化", stringConv("カタカナ化", utils.ToKatakana)),
+		"ひらがな化": newBuiltin("ひらがな化", stringConv("ひらがな化", utils.ToHiragana)),
+		"全角化":   newBuiltin("全角化", stringConv("全角化", utils.ToFullWidth)),
+		"半角化":   newBuiltin("半角化", stringConv("半角化", utils.ToHalfWidth)),
+	}
+}
+
+// 引数がすべて文字列であることを確かめて取り出す
+func stringArgs(name string, args []object.Object) ([]string, *object.Error) {
+	strs := []string{}
+	for _, v := range args {
+		str, ok := v.(*object.String)
+		if !ok {
+			return nil, newArgTypeError(name, "文字列")
+		}
+		strs = append(strs, str.Value)
+	}
+	return strs, nil
+}
+
+func newStringArray(strs []string) *object.Array {
+	elements := []object.Object{}
+	for _, v := range strs {
+		elements = append(elements, &object.String{Value: v})
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 文字(rune)の数を返す
+func stringLength(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("長さ", "長さ(文字列)")
+	}
+	strs, err := stringArgs("長さ", args)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: utf8.RuneCountInString(strs[0])}
+}
+
+// 見た目の文字の数を返す。「が」を「か」と濁点で書いた場合や絵文字の組み合わせも一文字と数える
+func stringGraphemeCount(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgCountError("文字数", "文字数(文字列)")
+	}
+	strs, err := stringArgs("文字数", args)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: len(utils.Graphemes(strs[0]))}
+}
+
+// 区切りを省略するか空文字列にすると一文字ずつに分ける
+func stringSplit(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("分割", "分割(文字列、区切り=\"\")")
+	}
+	strs, err := stringArgs("分割", args)
+	if err != nil {
+		return err
+	}
+
+	if len(strs) == 1 || strs[1] == "" {
+		return newStringArray(utils.Graphemes(strs[0]))
+	}
+	return newStringArray(strings.Split(strs[0], strs[1]))
+}
+
+// 配列の要素をつなげる。文字列以外の要素は表示と同じ形にする
+func stringJoin(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newArgCountError("結合", "結合(配列、区切り=\"\")")
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newArgTypeError("結合", "配列")
+	}
+	sep := ""
+	if len(args) == 2 {
+		strs, err := stringArgs("結合", args[1:])
+		if err != nil {
+			return err
+		}
+		sep = strs[0]
+	}
+
+	strs := []string{}
+	for _, v := range array.Elements {
+		strs = append(strs, v.Inspect())
+	}
+	return &object.String{Value: strings.Join(strs, sep)}
+}
+
+func (e *Evaluator) stringReplace(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newArgCountError("置換", "置換(文字列、前、後)")
+	}
+	strs, err := stringArgs("置換", args)
+	if err != nil {
+		return err
+	}
+	// 置き換えてから長さを確かめると大きな文字列を作ってしまうので、先に長さを見積もる
+	if grow := len(strs[2]) - len(strs[1]); grow > 0 && e.Limits.MaxStringSize > 0 {
+		size := len(strs[0])
+		if count := strings.Count(strs[0], strs[1]); count > (math.MaxInt-size)/grow {
+			size = math.MaxInt
+		} else {
+			size += count * grow
+		}
+		if err := e.reserveString(size); err != nil {
+			return err
+		}
+	}
+	return &object.String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+}
+
+func stringPredicate(name string, fn func(string, string) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newArgCountError(name, name+"(文字列、部分)")
+		}
+		strs, err := stringArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &object.Boolean{Value: fn(strs[0], strs[1])}
+	}
+}
+
+func stringConv(name string, fn func(string) string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newArgCountError(name, name+"(文字列)")
+		}
+		strs, err := stringArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: fn(strs[0])}
+	}
+}
+
+// 書式("{}は{}歳", 名前、年齢) のように{}を値で置き換える。
+// {0}は番号で、{名前}は値に渡した連想配列のキーで選ぶ。{{と}}は括弧そのものになる
+func stringFormat(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newArgCountError("書式", "書式(書式、…値)")
+	}
+	format, ok := args[0].(*object.String)
+	if !ok {
+		return newArgTypeError("書式", "文字列")
+	}
+	values := args[1:]
+
+	var out strings.Builder
+	runes := []rune(format.Value)
+	next := 0
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if (ch == '{' || ch == '}') && i+1 < len(runes) && runes[i+1] == ch {
+			out.WriteRune(ch)
+			i++
+			continue
+		}
+		if ch != '{' {
+			out.WriteRune(ch)
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && runes[end] != '}' {
+			end++
+		}
+		if end == len(runes) {
+			return newError("書式の「{」が閉じられていません。")
+		}
+		key := string(runes[i+1 : end])
+		i = end
+
+		value, ok := formatValue(key, values, &next)
+		if !ok {
+			return newError("書式の{%s}に対応する値がありません。", key)
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func formatValue(key string, values []object.Object, next *int) (object.Object, bool) {
+	if key == "" {
+		if *next >= len(values) {
+			return nil, false
+		}
+		*next++
+		return values[*next-1], true
+	}
+
+	if index, err := strconv.Atoi(utils.ToLower(key)); err == nil {
+		if index < 0 || index >= len(values) {
+			return nil, false
+		}
+		return values[index], true
+	}
+
+	if len(values) == 0 {
+		return nil, false
+	}
+	hash, ok := values[0].(*object.Hash)
+	if !ok {
+		return nil, false
+	}
+	return hash.Get(&object.String{Value: key})
+}
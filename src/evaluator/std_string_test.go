@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestStringModule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`文字列.長さ("日本語")`, "3"},
+			{`文字列.長さ("👨‍👩‍👧")`, "5"},
+			{`文字列.文字数("👨‍👩‍👧")`, "1"},
+			{`文字列.文字数("が")`, "1"},
+			{`文字列.分割("a,b,c"、",")`, "[a, b, c]"},
+			{`文字列.分割("りんご、みかん"、"、")`, "[りんご, みかん]"},
+			{`文字列.分割("日本🇯🇵")`, "[日, 本, 🇯🇵]"},
+			{`文字列.結合(["a"、1、2.5])`, "a12.5"},
+			{`文字列.結合(["り"、"ん"、"ご"]、"・")`, "り・ん・ご"},
+			{`文字列.置換("すもももももも"、"もも"、"桃")`, "す桃桃桃"},
+			{`文字列.含む("東京都"、"京")`, "true"},
+			{`文字列.含む("東京都"、"大阪")`, "false"},
+			{`文字列.前方一致("東京都"、"東京")`, "true"},
+			{`文字列.後方一致("東京都"、"東京")`, "false"},
+			{`文字列.トリム("　 こんにちは\n　")`, "こんにちは"},
+			{`文字列.カタカナ化("ひらがな")`, "ヒラガナ"},
+			{`文字列.ひらがな化("カタカナ")`, "かたかな"},
+			{`文字列.全角化("abc 123")`, "ａｂｃ　１２３"},
+			{`文字列.半角化("ＡＢＣ　１２３ガ")`, "ABC 123ｶﾞ"},
+			{`文字列.半角化("「ア、イ。」")`, "「ｱ、ｲ。」"},
+			{`文字列.書式("{}は{}歳です"、"太郎"、20)`, "太郎は20歳です"},
+			{`文字列.書式("{1}と{0}と{１}"、"a"、"b")`, "bとaとb"},
+			{`文字列.書式("{名前}さん"、{"名前": "花子"})`, "花子さん"},
+			{`文字列.書式("{{}}{}"、[1、2])`, "{}[1, 2]"},
+			{`"日本語" を 文字列.長さする`, "3"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"文字列\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
+
+func TestStringModuleErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, _ Backend) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{`文字列.長さ(1)`, "Error:関数長さの引数には文字列が必要です。"},
+			{`文字列.長さ()`, "Error:関数長さの引数の個数が正しくありません。期待される形式: 長さ(文字列)"},
+			{`文字列.分割("a"、1)`, "Error:関数分割の引数には文字列が必要です。"},
+			{`文字列.結合("abc")`, "Error:関数結合の引数には配列が必要です。"},
+			{`文字列.置換("a"、"b")`, "Error:関数置換の引数の個数が正しくありません。期待される形式: 置換(文字列、前、後)"},
+			{`文字列.書式("{}{}"、1)`, "Error:書式の{}に対応する値がありません。"},
+			{`文字列.書式("{3}"、1)`, "Error:書式の{3}に対応する値がありません。"},
+			{`文字列.書式("{名前}"、{"年齢": 1})`, "Error:書式の{名前}に対応する値がありません。"},
+			{`文字列.書式("{"、1)`, "Error:書式の「{」が閉じられていません。"},
+		}
+
+		for i, v := range tests {
+			input := "読み込む \"文字列\" " + v.input
+			if val := testEval(t, input).Inspect(); val != v.expect {
+				t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+			}
+		}
+	})
+}
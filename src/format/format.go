@@ -0,0 +1,334 @@
+// ソースコードを決まった書き方に整える。
+// 構文木から文とブロックの区切りを知り、字句と注釈をそのままの順に書き直す。
+// 演算子や括弧は全角か半角のどちらかにそろえ、一行に一つの文を書いてブロックの中を字下げする
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"jpl/ast"
+	"jpl/parser"
+	"jpl/token"
+	"jpl/utils"
+)
+
+// 整え方
+type Style struct {
+	FullWidth bool   // 記号と数字を全角で書く。偽なら半角で書く
+	Indent    string // ブロックの中を一段下げるのに使う文字列
+}
+
+// 半角の記号とタブで字下げする書き方
+var DefaultStyle = Style{Indent: "\t"}
+
+// 字句ごとの半角と全角の書き方
+var symbols = map[token.TokenKind][2]string{
+	token.PLUS:            {"+", "＋"},
+	token.MINUS:           {"-", "ー"},
+	token.ASTERISK:        {"*", "×"},
+	token.SLASH:           {"/", "÷"},
+	token.ASSIGN:          {"=", "＝"},
+	token.PLUS_ASSIGN:     {"+=", "＋＝"},
+	token.MINUS_ASSIGN:    {"-=", "ー＝"},
+	token.ASTERISK_ASSIGN: {"*=", "×＝"},
+	token.SLASH_ASSIGN:    {"/=", "÷＝"},
+	token.GT:              {"<", "＜"},
+	token.LT:              {">", "＞"},
+	token.GE:              {"<=", "＜＝"},
+	token.LE:              {">=", "＞＝"},
+	token.EQ:              {"==", "＝＝"},
+	token.NOT_EQ:          {"!=", "！＝"},
+	token.LPAREN:          {"(", "（"},
+	token.RPAREN:          {")", "）"},
+	token.LBRACE:          {"{", "｛"},
+	token.RBRACE:          {"}", "｝"},
+	token.LBRACKET:        {"[", "［"},
+	token.RBRACKET:        {"]", "］"},
+	token.COMMA:           {",", "、"},
+	token.COLON:           {":", "："},
+	token.ELLIPSIS:        {"...", "…"},
+	token.DOT:             {".", "．"},
+}
+
+type pos struct {
+	line   int
+	column int
+}
+
+func tokenPos(tok *token.Token) pos {
+	return pos{tok.Line, tok.Column}
+}
+
+// src を整える。構文に誤りがあれば整えずに誤りを返す
+func Source(src string, style Style) (string, error) {
+	head, comments := token.TokenizeWithComments(src)
+	program, errors := parser.ParseWithPositions(head)
+	if len(errors) > 0 {
+		err := errors[0]
+		return "", fmt.Errorf("%d行%d列: 構文が正しくありません。%s", err.Line, err.Column, err.Message)
+	}
+
+	p := &printer{
+		style:  style,
+		lines:  strings.Split(src, "\n"),
+		starts: map[pos]bool{},
+		blocks: map[*token.Token]bool{},
+		opens:  map[*token.Token]*token.Token{},
+		unary:  map[*token.Token]bool{},
+	}
+	blockPos := map[pos]bool{}
+	for _, node := range program.Nodes {
+		p.walk(node, blockPos)
+	}
+
+	tokens := []*token.Token{}
+	for tok := head; tok != nil && tok.Kind != token.EOF; tok = tok.Next {
+		tokens = append(tokens, tok)
+	}
+	p.matchBlocks(tokens, blockPos)
+
+	items := merge(tokens, comments)
+	for i, item := range items {
+		if item.Kind == token.COMMENT {
+			var next *token.Token
+			if i+1 < len(items) {
+				next = items[i+1]
+			}
+			p.comment(item, next)
+		} else {
+			p.token(item)
+		}
+	}
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+	return p.out.String(), nil
+}
+
+type printer struct {
+	style  Style
+	lines  []string                      // 元のソースコードの行
+	starts map[pos]bool                  // 文の並びにある文の始めの位置
+	blocks map[*token.Token]bool         // ブロックを囲む波括弧
+	opens  map[*token.Token]*token.Token // ブロックの閉じ波括弧から開き波括弧へ
+	unary  map[*token.Token]bool         // 単項演算子として書いた符号
+
+	out    strings.Builder
+	depth  int
+	prev   *token.Token // 直前に書いた字句か注釈
+	last   *token.Token // 直前に書いた字句。注釈は含まない
+	broken bool         // 注釈の後なので、次の字句は行を改めて書く
+}
+
+// 文の始めとブロックの位置を集める
+func (p *printer) walk(node *ast.Node, blockPos map[pos]bool) {
+	if node == nil {
+		return
+	}
+	if node.StartLine > 0 {
+		p.starts[pos{node.StartLine, node.StartColumn}] = true
+	}
+	if node.NodeKind == ast.BLOCK {
+		blockPos[pos{node.Line, node.Column}] = true
+	}
+	for _, child := range []*ast.Node{node.Lhs, node.Rhs, node.Condition, node.Then, node.Else, node.Body} {
+		p.walk(child, blockPos)
+	}
+	for _, child := range node.Stmts {
+		p.walk(child, blockPos)
+	}
+	for _, child := range node.Params {
+		p.walk(child, blockPos)
+	}
+}
+
+// ブロックの開き波括弧に対応する閉じ波括弧を探す。ハッシュの波括弧はブロックではない
+func (p *printer) matchBlocks(tokens []*token.Token, blockPos map[pos]bool) {
+	stack := []*token.Token{}
+	for _, tok := range tokens {
+		switch tok.Kind {
+		case token.LBRACE:
+			stack = append(stack, tok)
+		case token.RBRACE:
+			if len(stack) == 0 {
+				continue
+			}
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if blockPos[tokenPos(open)] {
+				p.blocks[open] = true
+				p.blocks[tok] = true
+				p.opens[tok] = open
+			}
+		}
+	}
+}
+
+// 字句と注釈を位置の順に並べる
+func merge(tokens []*token.Token, comments []*token.Token) []*token.Token {
+	res := make([]*token.Token, 0, len(tokens)+len(comments))
+	i, j := 0, 0
+	for i < len(tokens) || j < len(comments) {
+		if j == len(comments) || i < len(tokens) && before(tokens[i], comments[j]) {
+			res = append(res, tokens[i])
+			i++
+		} else {
+			res = append(res, comments[j])
+			j++
+		}
+	}
+	return res
+}
+
+func before(a *token.Token, b *token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// 字句か注釈の終わる行
+func endLine(tok *token.Token) int {
+	if tok.Kind == token.COMMENT {
+		return tok.Line + strings.Count(tok.Literal, "\n")
+	}
+	return tok.Line
+}
+
+// 元のソースコードで、tok の前に空行があるか。空行は続いていても一つにする
+func (p *printer) blankBefore(tok *token.Token) bool {
+	if p.prev == nil || p.blocks[p.prev] && p.prev.Kind == token.LBRACE {
+		return false
+	}
+	above := tok.Line - 1
+	return above > endLine(p.prev) && strings.TrimSpace(p.lines[above-1]) == ""
+}
+
+// 行を改めて depth 段下げる
+func (p *printer) newline(depth int, blank bool) {
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+		if blank {
+			p.out.WriteString("\n")
+		}
+	}
+	p.out.WriteString(strings.Repeat(p.style.Indent, depth))
+}
+
+func (p *printer) token(tok *token.Token) {
+	switch {
+	case p.starts[tokenPos(tok)]:
+		p.newline(p.depth, p.blankBefore(tok))
+	case tok.Kind == token.RBRACE && p.blocks[tok]:
+		p.depth--
+		// 空のブロックは {} と書く
+		if p.prev != p.opens[tok] {
+			p.newline(p.depth, false)
+		}
+	case p.broken:
+		p.newline(p.depth+1, false)
+	case p.prev != nil && p.space(tok):
+		p.out.WriteString(" ")
+	}
+
+	if (tok.Kind == token.MINUS || tok.Kind == token.PLUS) && (p.last == nil || p.starts[tokenPos(tok)] || !p.isValue(p.last)) {
+		p.unary[tok] = true
+	}
+	p.out.WriteString(p.text(tok))
+	if tok.Kind == token.LBRACE && p.blocks[tok] {
+		p.depth++
+	}
+	p.prev, p.last, p.broken = tok, tok, false
+}
+
+// 注釈は元の行に続けて書くか、一行に書く。一行の注釈の後は必ず行を改める
+func (p *printer) comment(tok *token.Token, next *token.Token) {
+	if p.prev == nil || tok.Line > endLine(p.prev) {
+		p.newline(p.depth, p.blankBefore(tok))
+	} else {
+		p.out.WriteString(" ")
+	}
+	p.out.WriteString(strings.TrimRight(tok.Literal, " \t\r"))
+	p.prev = tok
+	p.broken = isLineComment(tok) || next != nil && next.Line > endLine(tok)
+}
+
+// "//" か "／／" で始まる一行の注釈か
+func isLineComment(tok *token.Token) bool {
+	runes := []rune(tok.Literal)
+	return len(runes) > 1 && (runes[1] == '/' || runes[1] == '／')
+}
+
+// 値の後に続く "(" や "[" は呼び出しや添字になり、"-" は引き算になる
+func (p *printer) isValue(tok *token.Token) bool {
+	switch tok.Kind {
+	case token.INTEGER, token.FLOAT, token.STRING, token.IDENT, token.RPAREN, token.RBRACKET:
+		return true
+	case token.RBRACE:
+		return !p.blocks[tok]
+	}
+	return false
+}
+
+// 直前の字句との間に空白を置くか
+func (p *printer) space(tok *token.Token) bool {
+	prev := p.last
+	if prev != p.prev {
+		// 注釈の後には空白を置く
+		return true
+	}
+	switch tok.Kind {
+	case token.RPAREN, token.RBRACKET, token.COMMA, token.COLON, token.DOT:
+		return false
+	case token.RBRACE:
+		return p.blocks[tok]
+	case token.LPAREN:
+		if prev.Kind == token.IDENT {
+			return false
+		}
+	case token.LBRACKET:
+		if p.isValue(prev) {
+			return false
+		}
+	}
+	switch prev.Kind {
+	case token.LPAREN, token.LBRACKET, token.DOT, token.ELLIPSIS:
+		return false
+	case token.LBRACE:
+		return p.blocks[prev]
+	case token.COMMA, token.COLON:
+		// 全角の「、」と「：」はそれだけで間が空いて見える
+		return !p.style.FullWidth
+	case token.MINUS, token.PLUS:
+		return !p.unary[prev]
+	}
+	return true
+}
+
+func (p *printer) text(tok *token.Token) string {
+	switch tok.Kind {
+	case token.INTEGER, token.FLOAT:
+		if p.style.FullWidth {
+			return utils.ToFullWidth(tok.Literal)
+		}
+		return utils.ToHalfWidth(tok.Literal)
+	case token.STRING:
+		return p.quote(tok.Literal)
+	}
+	if s, ok := symbols[tok.Kind]; ok {
+		if p.style.FullWidth {
+			return s[1]
+		}
+		return s[0]
+	}
+	return tok.Literal
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, `"`, `\"`, "＂", `\＂`)
+
+// 文字列を引用符で囲み、改行やタブや引用符を \ で書き表す
+func (p *printer) quote(str string) string {
+	q := `"`
+	if p.style.FullWidth {
+		q = "＂"
+	}
+	return q + escaper.Replace(str) + q
+}
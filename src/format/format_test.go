@@ -0,0 +1,157 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"jpl/parser"
+	"jpl/token"
+)
+
+var fullWidth = Style{FullWidth: true, Indent: "  "}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		style    Style
+		expected string
+	}{
+		{"x=1+2*3", DefaultStyle, "x = 1 + 2 * 3\n"},
+		{"ｘ ＝ １＋２×３", DefaultStyle, "ｘ = 1 + 2 * 3\n"},
+		{"x = 1 + 2 * 3", fullWidth, "x ＝ １ ＋ ２ × ３\n"},
+		{"a = -1 - ー2", DefaultStyle, "a = -1 - -2\n"},
+		{"a = [1,2、 3][0] b = {\"k\" : 1.5}", DefaultStyle, "a = [1, 2, 3][0]\nb = {\"k\": 1.5}\n"},
+		{"a = [1, 2] b = {\"k\": 1.5}", fullWidth, "a ＝ ［１、２］\nb ＝ ｛＂k＂：１．５｝\n"},
+		{"s = \"a\\\"b\\＂\\n\"", fullWidth, "s ＝ ＂a\\\"b\\＂\\n＂\n"},
+		{"関数 f（a と、…残り）｛ a 戻す ｝", DefaultStyle, "関数 f(a と, ...残り) {\n\ta 戻す\n}\n"},
+		{"関数 f(){}", DefaultStyle, "関数 f() {}\n"},
+		{"もし a ならば { b } それ以外 c = 1", fullWidth, "もし a ならば ｛\n  b\n｝ それ以外 c ＝ １\n"},
+		{"i < 3 ならば 繰り返す i 増やす", DefaultStyle, "i < 3 ならば 繰り返す i 増やす\n"},
+		{"x を 5 増やす m．f(1) を 表示する", DefaultStyle, "x を 5 増やす\nm.f(1) を 表示する\n"},
+		{"x = 1\n\n\n\ny = 2", DefaultStyle, "x = 1\n\ny = 2\n"},
+		{"{\n\n x = 1\n\n}", DefaultStyle, "{\n\tx = 1\n}\n"},
+		{"", DefaultStyle, ""},
+	}
+
+	for i, v := range tests {
+		got, err := Source(v.input, v.style)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		if got != v.expected {
+			t.Fatalf("test%d : got=%q expected=%q\n", i, got, v.expected)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x=1 // 一\ny=2", "x = 1 // 一\ny = 2\n"},
+		{"// 先頭\n\n\n関数 f() { // 開く\n  // 中\n}", "// 先頭\n\n関数 f() { // 開く\n\t// 中\n}\n"},
+		{"x = [1, // 一つ目\n2]", "x = [1, // 一つ目\n\t2]\n"},
+		{"x = 1 /* 途中 */ + 2", "x = 1 /* 途中 */ + 2\n"},
+		{"／＊ 全角の\n  注釈 ＊／\nx = 1   ", "／＊ 全角の\n  注釈 ＊／\nx = 1\n"},
+	}
+
+	for i, v := range tests {
+		got, err := Source(v.input, DefaultStyle)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		if got != v.expected {
+			t.Fatalf("test%d : got=%q expected=%q\n", i, got, v.expected)
+		}
+	}
+}
+
+const program = `// 階乗を求める
+関数 階乗（n）｛
+  もし n＜＝1 ならば ｛ 1 戻す ｝  // 止める
+	r=n×階乗(n ー 1)
+
+
+    r 戻す
+}
+x  =  -3 + ー４
+一覧 = [x、4,  "a\"b\＂\n"]
+h = {"キー": 1, "値":[1,2]} h
+/* 複数行の
+   注釈 */
+x を 5 増やす
+もし x > 1 ならば {
+} それ以外 {
+   x = f(1、名前: 2)[0]
+}
+モジュール．名前(3.5) を 表示する
+x < 3 ならば 繰り返す x 増やす
+`
+
+// 整えた結果をもう一度整えても変わらない。どちらの書き方から整えても同じになる
+func TestIdempotent(t *testing.T) {
+	styles := []Style{DefaultStyle, fullWidth, {Indent: "    "}}
+	for i, style := range styles {
+		once, err := Source(program, style)
+		if err != nil {
+			t.Fatalf("style%d : %s\n", i, err)
+		}
+		twice, err := Source(once, style)
+		if err != nil {
+			t.Fatalf("style%d : %s\n", i, err)
+		}
+		if once != twice {
+			t.Fatalf("style%d : got=%q expected=%q\n", i, twice, once)
+		}
+		for j, other := range styles {
+			converted, err := Source(once, other)
+			if err != nil {
+				t.Fatalf("style%d : %s\n", i, err)
+			}
+			direct, _ := Source(program, other)
+			if converted != direct {
+				t.Fatalf("style%d to style%d : got=%q expected=%q\n", i, j, converted, direct)
+			}
+		}
+	}
+}
+
+// 整えても構文木は変わらず、注釈も残る
+func TestPreserve(t *testing.T) {
+	parse := func(src string) string {
+		program, errors := parser.Parse(token.Tokenize(src))
+		if len(errors) > 0 {
+			t.Fatal(errors)
+		}
+		res := []string{}
+		for _, node := range program.Nodes {
+			res = append(res, node.String())
+		}
+		return strings.Join(res, "\n")
+	}
+
+	expected := parse(program)
+	_, comments := token.TokenizeWithComments(program)
+	for i, style := range []Style{DefaultStyle, fullWidth} {
+		out, err := Source(program, style)
+		if err != nil {
+			t.Fatalf("style%d : %s\n", i, err)
+		}
+		if got := parse(out); got != expected {
+			t.Fatalf("style%d : got=%s expected=%s\n", i, got, expected)
+		}
+		for _, c := range comments {
+			if !strings.Contains(out, c.Literal) {
+				t.Fatalf("style%d : %sがありません。\n", i, c.Literal)
+			}
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	_, err := Source("x = 1\ny = (", DefaultStyle)
+	if err == nil || err.Error() != "2行6列: 構文が正しくありません。式が必要です。" {
+		t.Fatalf("got=%v\n", err)
+	}
+}
@@ -0,0 +1,206 @@
+// Go のプログラムにJPLを組み込むためのパッケージ。
+//
+// 実行器はそれぞれ独立した大域変数と組み込み関数を持つ。
+// 別々の実行器は別々のゴルーチンから同時に使ってよい。
+// 同じ実行器への呼び出しは順番に実行される。
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"jpl/evaluator"
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+type Options struct {
+	// 表示の出力先。nil なら実行器の中に溜めて Output で取り出す
+	Out io.Writer
+	// 実行する前に見つかった問題の出力先。nil なら出力しない
+	Warnings io.Writer
+	// 読み込むモジュールを探すディレクトリ
+	SearchPath []string
+	// ファイルモジュールの権限
+	Files evaluator.FilePolicy
+	// プログラムを実行する方法
+	Backend evaluator.Backend
+	// 実行の上限。0の項目は制限しない
+	Limits evaluator.Limits
+	// 乱数の種。0なら実行器を作った時刻から決める
+	Seed int64
+}
+
+type Interpreter struct {
+	mu  sync.Mutex
+	e   *evaluator.Evaluator
+	env *object.Environment
+	out *bytes.Buffer // Options.Out が nil の時の出力先
+}
+
+func New(options Options) *Interpreter {
+	it := &Interpreter{e: evaluator.New(), env: object.NewEnvironment()}
+	if options.Out != nil {
+		it.e.Out = options.Out
+	} else {
+		it.out = &bytes.Buffer{}
+		it.e.Out = it.out
+	}
+	it.e.Warnings = options.Warnings
+	it.e.SearchPath = options.SearchPath
+	it.e.Files = options.Files
+	it.e.Backend = options.Backend
+	it.e.Limits = options.Limits
+	if options.Seed != 0 {
+		it.e.Seed(options.Seed)
+	}
+	return it
+}
+
+// スクリプトの構文の誤り
+type SyntaxError struct {
+	Messages []string
+}
+
+func (err *SyntaxError) Error() string {
+	return strings.Join(err.Messages, "\n")
+}
+
+// スクリプトの実行中に起きたエラー
+type Error struct {
+	Message string
+	// 上限や期限に達して止まった
+	Limit bool
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// 型の決まっていない Go の値にする。整数は int、連想配列は map[string]interface{} になる
+func toGo(obj object.Object) (interface{}, error) {
+	var value interface{}
+	if err := object.ToGo(obj, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func runtimeError(obj object.Object) error {
+	err, ok := obj.(*object.Error)
+	if !ok {
+		return nil
+	}
+	return &Error{Message: err.Message, Limit: err.Kind == object.LimitError}
+}
+
+// ソースコードを実行して最後の式の値を返す。大域変数は次の実行にも残る
+func (it *Interpreter) Run(ctx context.Context, src string) (interface{}, error) {
+	program, errors := parser.Parse(token.Tokenize(src))
+	if len(errors) > 0 {
+		return nil, &SyntaxError{Messages: errors}
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	res := it.e.EvalProgramContext(ctx, program, it.env)
+	if err := runtimeError(res); err != nil {
+		return nil, err
+	}
+	return toGo(res)
+}
+
+// 大域変数に Go の値を入れる。値は object.FromGo で変換する
+func (it *Interpreter) Set(name string, value interface{}) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return err
+	}
+	// 関数はエラーメッセージに変数の名前を使う
+	if builtin, ok := obj.(*object.Builtin); ok && builtin.Name == "" {
+		builtin.Name = name
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.env.Define(name, obj)
+	return nil
+}
+
+// 大域変数の値を Go の値にして返す
+func (it *Interpreter) Get(name string) (interface{}, bool) {
+	var value interface{}
+	ok, _ := it.GetAs(name, &value)
+	return value, ok
+}
+
+// 大域変数の値を target が指す変数に入れる。値は object.ToGo で変換する
+func (it *Interpreter) GetAs(name string, target interface{}) (bool, error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	obj, ok := it.env.Get(name)
+	if !ok {
+		return false, nil
+	}
+	return true, object.ToGo(obj, target)
+}
+
+// Go の関数を組み込み関数として登録する。
+// 引数と戻り値は object.FromGo と同じ規則で変換し、関数が返したエラーはスクリプトのエラーになる
+func (it *Interpreter) Register(name string, fn interface{}) error {
+	obj, err := object.FromGo(fn)
+	if err != nil {
+		return err
+	}
+	builtin, ok := obj.(*object.Builtin)
+	if !ok {
+		return fmt.Errorf("%sには関数が必要です。", name)
+	}
+	builtin.Name = name
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.e.Register(name, builtin.Fn)
+	return nil
+}
+
+// スクリプトで定義した関数を呼び出す
+func (it *Interpreter) Call(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	objs := make([]object.Object, len(args))
+	for i, v := range args {
+		obj, err := object.FromGo(v)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	fn, ok := it.env.Get(name)
+	if !ok {
+		return nil, &Error{Message: fmt.Sprintf("関数%sが宣言されていません。", name)}
+	}
+	res := it.e.ApplyContext(ctx, fn, objs...)
+	if err := runtimeError(res); err != nil {
+		return nil, err
+	}
+	return toGo(res)
+}
+
+// Options.Out を指定しなかった時に、これまでの表示をまとめて返す。返した分は消す
+func (it *Interpreter) Output() string {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.out == nil {
+		return ""
+	}
+	str := it.out.String()
+	it.out.Reset()
+	return str
+}
@@ -0,0 +1,215 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"jpl/evaluator"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect interface{}
+	}{
+		{"1 + 2", 3},
+		{"1.5 * 2", 3.0},
+		{`"日本" + "語"`, "日本語"},
+		{"1 < 2", true},
+		{"[1、\"a\"、[2.5]]", []interface{}{1, "a", []interface{}{2.5}}},
+		{`x = {"a": 1、"b": [1 == 1]} x`, map[string]interface{}{"a": 1, "b": []interface{}{true}}},
+		{"関数 f() { 1、2 戻す } f()", []interface{}{1, 2}},
+		{"x = 1", nil},
+	}
+
+	for _, backend := range []evaluator.Backend{evaluator.TreeWalker, evaluator.VM} {
+		for i, v := range tests {
+			it := New(Options{Backend: backend})
+			res, err := it.Run(context.Background(), v.input)
+			if err != nil {
+				t.Fatalf("test%d : %s\n", i, err)
+			}
+			if !reflect.DeepEqual(res, v.expect) {
+				t.Fatalf("test%d : got=%#v expect=%#v\n", i, res, v.expect)
+			}
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	it := New(Options{})
+	if err := it.Set("設定", map[string]interface{}{"倍率": 3, "名前": "山田"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := it.Set("値", []interface{}{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := it.Set("x", make(chan int)); err == nil {
+		t.Fatalf("変換できない値でエラーになりません。\n")
+	}
+
+	if _, err := it.Run(context.Background(), `合計 = 値[2] * 設定["倍率"]`); err != nil {
+		t.Fatal(err)
+	}
+	// 大域変数は次の実行にも残る
+	res, err := it.Run(context.Background(), `合計 + 1`)
+	if err != nil || res != 10 {
+		t.Fatalf("got=%v %v expect=10\n", res, err)
+	}
+	if res, ok := it.Get("合計"); !ok || res != 9 {
+		t.Fatalf("got=%v expect=9\n", res)
+	}
+	if _, ok := it.Get("ない"); ok {
+		t.Fatalf("ない変数が見つかりました。\n")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	it := New(Options{})
+	it.Register("足す", func(args ...interface{}) (interface{}, error) {
+		sum := 0
+		for _, v := range args {
+			n, ok := v.(int)
+			if !ok {
+				return nil, errors.New("整数が必要です。")
+			}
+			sum += n
+		}
+		return sum, nil
+	})
+	it.Register("大文字", strings.ToUpper)
+	it.Set("割る", func(a, b int) (int, int, error) {
+		if b == 0 {
+			return 0, 0, errors.New("0では割れません。")
+		}
+		return a / b, a % b, nil
+	})
+	if err := it.Register("値", 1); err == nil {
+		t.Fatalf("関数でない値を登録できました。\n")
+	}
+
+	tests := []struct {
+		input  string
+		expect interface{}
+		err    string
+	}{
+		{"足す(1、2、3)", 6, ""},
+		{"1 と 2 を 足す", 3, ""},
+		{`大文字("abc")`, "ABC", ""},
+		{`足す(1、"a")`, nil, "整数が必要です。"},
+		{"割る(7、2)", []interface{}{3, 1}, ""},
+		{"割る(7、0)", nil, "0では割れません。"},
+		{`割る(7、"a")`, nil, "関数割るの2番目の引数: STRINGをintに変換できません。"},
+		{"割る(7)", nil, "関数割るの引数の個数が正しくありません。"},
+		{`大文字(1)`, nil, "関数大文字の1番目の引数: INTEGERをstringに変換できません。"},
+	}
+
+	for i, v := range tests {
+		res, err := it.Run(context.Background(), v.input)
+		if v.err != "" {
+			if err == nil || err.Error() != v.err {
+				t.Fatalf("test%d : got=%v expect=%s\n", i, err, v.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(res, v.expect) {
+			t.Fatalf("test%d : got=%v %v expect=%v\n", i, res, err, v.expect)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	it := New(Options{})
+	if _, err := it.Run(context.Background(), "関数 挨拶(名前) { \"こんにちは、\" + 名前 戻す }"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := it.Call(context.Background(), "挨拶", "世界")
+	if err != nil || res != "こんにちは、世界" {
+		t.Fatalf("got=%v %v\n", res, err)
+	}
+	if _, err := it.Call(context.Background(), "ない"); err == nil {
+		t.Fatalf("ない関数を呼び出せました。\n")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	it := New(Options{})
+	it.Run(context.Background(), `表示("a"、1) 表示("b")`)
+	if out := it.Output(); out != "a 1\nb\n" {
+		t.Fatalf("got=%q\n", out)
+	}
+	if out := it.Output(); out != "" {
+		t.Fatalf("取り出した出力が残っています。 got=%q\n", out)
+	}
+
+	var buf strings.Builder
+	it = New(Options{Out: &buf})
+	it.Run(context.Background(), `表示("c")`)
+	if buf.String() != "c\n" || it.Output() != "" {
+		t.Fatalf("got=%q\n", buf.String())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	it := New(Options{Limits: evaluator.Limits{MaxSteps: 1000}})
+
+	_, err := it.Run(context.Background(), "関数 (")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("構文のエラーになっていません。 got=%v\n", err)
+	}
+
+	_, err = it.Run(context.Background(), "1 / 0")
+	var runErr *Error
+	if !errors.As(err, &runErr) || runErr.Limit || runErr.Message != "0で割ることはできません。" {
+		t.Fatalf("got=%v\n", err)
+	}
+
+	_, err = it.Run(context.Background(), "1 ならば 繰り返す { 1 }")
+	if !errors.As(err, &runErr) || !runErr.Limit {
+		t.Fatalf("上限のエラーになっていません。 got=%v\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = New(Options{}).Run(ctx, "1 ならば 繰り返す { 1 }")
+	if !errors.As(err, &runErr) || !runErr.Limit {
+		t.Fatalf("期限のエラーになっていません。 got=%v\n", err)
+	}
+}
+
+// 別々の実行器は同時に使える。同じ実行器への呼び出しは順番に実行される
+func TestConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	shared := New(Options{Backend: evaluator.VM})
+	shared.Set("数", 0)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			it := New(Options{Backend: evaluator.Backend(i % 2)})
+			it.Set("n", i)
+			src := "関数 f(x) { もし x < 2 ならば x 戻す f(x - 1) + f(x - 2) } 表示(n) f(15) + n"
+			res, err := it.Run(context.Background(), src)
+			if err != nil || res != 610+i {
+				t.Errorf("got=%v %v expect=%d\n", res, err, 610+i)
+			}
+			if out := it.Output(); out != fmt.Sprintf("%d\n", i) {
+				t.Errorf("got=%q\n", out)
+			}
+			for j := 0; j < 10; j++ {
+				shared.Run(context.Background(), "数 += 1")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if res, _ := shared.Get("数"); res != 80 {
+		t.Fatalf("got=%v expect=80\n", res)
+	}
+}
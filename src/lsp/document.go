@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"strings"
+
+	"jpl/ast"
+	"jpl/parser"
+	"jpl/resolver"
+	"jpl/token"
+)
+
+// 字句や節点の位置。行と列は1から数え、列は文字(rune)単位
+type pos struct {
+	line   int
+	column int
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.column < q.column
+}
+
+func nodePos(node *ast.Node) pos {
+	return pos{node.Line, node.Column}
+}
+
+// 開いている文書と、それを解析した結果
+type document struct {
+	uri      string
+	lines    []string
+	tokens   []*token.Token
+	program  *ast.Program
+	errors   []parser.Error
+	analysis *resolver.Analysis
+	braces   map[pos]pos // 開き波括弧の位置から閉じ波括弧の位置へ
+}
+
+func newDocument(uri string, text string, known func(string) bool) *document {
+	d := &document{uri: uri, lines: strings.Split(text, "\n"), braces: map[pos]pos{}}
+
+	head := token.Tokenize(text)
+	for tok := head; tok != nil; tok = tok.Next {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.matchBraces()
+
+	d.program, d.errors = parser.ParseWithPositions(head)
+	// 構文に誤りがあっても、読めたところまでで名前を解決する
+	d.analysis = resolver.Analyze(d.program, known)
+	return d
+}
+
+func (d *document) matchBraces() {
+	stack := []pos{}
+	for _, tok := range d.tokens {
+		switch tok.Kind {
+		case token.LBRACE:
+			stack = append(stack, pos{tok.Line, tok.Column})
+		case token.RBRACE:
+			if len(stack) > 0 {
+				d.braces[stack[len(stack)-1]] = pos{tok.Line, tok.Column}
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+func (d *document) end() pos {
+	last := len(d.lines)
+	return pos{last, len([]rune(d.lines[last-1])) + 1}
+}
+
+// ブロックの閉じ波括弧の位置。関数は本体のブロックを見る。閉じていなければ文書の終わり
+func (d *document) closingBrace(node *ast.Node) pos {
+	// 書きかけの関数は本体がないことがある
+	if node.NodeKind == ast.FUNC && node.Body != nil {
+		node = node.Body
+	}
+	if end, ok := d.braces[nodePos(node)]; ok {
+		return end
+	}
+	return d.end()
+}
+
+// 範囲の始めと終わり
+func (d *document) scopeRange(scope *resolver.Scope) (pos, pos) {
+	if scope.Node == nil {
+		return pos{1, 1}, d.end()
+	}
+	return nodePos(scope.Node), d.closingBrace(scope.Node)
+}
+
+// 文字(rune)単位の列を UTF-16 の符号単位で0から数えた列にする
+func utf16Column(line string, column int) int {
+	units := 0
+	i := 1
+	for _, r := range line {
+		if i >= column {
+			break
+		}
+		units += utf16Len(r)
+		i++
+	}
+	// 行の終わりより後ろは1文字を1単位と数える
+	return units + column - i
+}
+
+// UTF-16 の符号単位で数えた列を、1から数える文字(rune)単位の列にする
+func runeColumn(line string, character int) int {
+	units := 0
+	column := 1
+	for _, r := range line {
+		if units >= character {
+			break
+		}
+		units += utf16Len(r)
+		column++
+	}
+	return column
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) line(n int) string {
+	if n < 1 || len(d.lines) < n {
+		return ""
+	}
+	return d.lines[n-1]
+}
+
+func (d *document) toPosition(p pos) Position {
+	return Position{Line: p.line - 1, Character: utf16Column(d.line(p.line), p.column)}
+}
+
+func (d *document) fromPosition(p Position) pos {
+	return pos{p.Line + 1, runeColumn(d.line(p.Line+1), p.Character)}
+}
+
+// 位置から length 文字の範囲
+func (d *document) rangeOf(start pos, length int) Range {
+	return Range{Start: d.toPosition(start), End: d.toPosition(pos{start.line, start.column + length})}
+}
+
+// 節点に書いた名前の範囲。名前のない節点は1文字の範囲にする
+func (d *document) nameRange(node *ast.Node) Range {
+	length := len([]rune(node.Ident))
+	if length == 0 {
+		length = 1
+	}
+	return d.rangeOf(nodePos(node), length)
+}
+
+// 位置にある名前を探す。名前の直後にある時も見つける
+func (d *document) symbolAt(p pos) (*resolver.Symbol, *ast.Node) {
+	for _, scope := range d.analysis.Scopes {
+		for _, sym := range scope.Symbols {
+			for _, ref := range sym.Refs {
+				start := nodePos(ref)
+				end := pos{start.line, start.column + len([]rune(ref.Ident))}
+				if !p.before(start) && !end.before(p) {
+					return sym, ref
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// 位置にある識別子の字句を探す
+func (d *document) identAt(p pos) *token.Token {
+	for i, tok := range d.tokens {
+		if tok.Kind != token.IDENT || tok.Line != p.line {
+			continue
+		}
+		if tok.Column <= p.column && p.column <= tok.Column+len([]rune(tok.Literal)) {
+			// メンバーの名前は変数ではない
+			if i > 0 && d.tokens[i-1].Kind == token.DOT {
+				return nil
+			}
+			return tok
+		}
+	}
+	return nil
+}
+
+// 位置から見える名前を内側の範囲から順に返す。同じ名前は内側のものだけ返す
+func (d *document) visibleSymbols(p pos) []*resolver.Symbol {
+	var inner *resolver.Scope
+	for _, scope := range d.analysis.Scopes {
+		start, end := d.scopeRange(scope)
+		if !p.before(start) && !end.before(p) {
+			// 範囲は外側から順に並んでいるので、後に見つかった範囲ほど内側にある
+			inner = scope
+		}
+	}
+
+	symbols := []*resolver.Symbol{}
+	seen := map[string]bool{}
+	for scope := inner; scope != nil; scope = scope.Outer {
+		for _, sym := range scope.Symbols {
+			if !seen[sym.Name] {
+				seen[sym.Name] = true
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	return symbols
+}
+
+// 関数の宣言の形。例: 関数 足す(a と, b = 1)
+func signature(fn *ast.Node) string {
+	params := []string{}
+	for _, param := range fn.Params {
+		params = append(params, param.String())
+	}
+	return "関数 " + fn.Ident + "(" + strings.Join(params, ", ") + ")"
+}
+
+func describe(sym *resolver.Symbol) string {
+	switch sym.Kind {
+	case resolver.FunctionSymbol:
+		if sym.Decl.NodeKind == ast.FUNC {
+			return signature(sym.Decl)
+		}
+		return "関数 " + sym.Name
+	case resolver.ParameterSymbol:
+		return "引数 " + sym.Name
+	case resolver.ModuleSymbol:
+		return "モジュール " + sym.Name
+	default:
+		return "変数 " + sym.Name
+	}
+}
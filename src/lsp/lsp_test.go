@@ -0,0 +1,342 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"jpl/transport"
+)
+
+const uri = "file:///main.jpl"
+
+const source = `関数 倍(数、係数 = 2) {
+	結果 = 数 * 係数
+	結果 戻す
+}
+s = "😀" x = 倍(3)
+x を 表示する
+`
+
+type reply struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// 台本どおりにメッセージを送り、応答と通知を受け取るクライアント
+type client struct {
+	t    *testing.T
+	in   bytes.Buffer
+	seq  int
+	msgs []reply
+}
+
+func (c *client) request(method string, params interface{}) int {
+	c.seq++
+	transport.WriteMessage(&c.in, map[string]interface{}{"jsonrpc": "2.0", "id": c.seq, "method": method, "params": params})
+	return c.seq
+}
+
+func (c *client) notify(method string, params interface{}) {
+	transport.WriteMessage(&c.in, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) run() {
+	var out bytes.Buffer
+	if err := Serve(&c.in, &out); err != nil {
+		c.t.Fatal(err)
+	}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := transport.ReadMessage(r)
+		if err != nil {
+			return
+		}
+		var msg reply
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		c.msgs = append(c.msgs, msg)
+	}
+}
+
+func (c *client) result(id int, v interface{}) {
+	c.t.Helper()
+	for _, msg := range c.msgs {
+		if msg.Method == "" && msg.ID == id {
+			if msg.Error != nil {
+				c.t.Fatalf("要求%d: %s", id, msg.Error.Message)
+			}
+			if err := json.Unmarshal(msg.Result, v); err != nil {
+				c.t.Fatal(err)
+			}
+			return
+		}
+	}
+	c.t.Fatalf("要求%dの応答がありません。", id)
+}
+
+func (c *client) error(id int) *responseError {
+	c.t.Helper()
+	for _, msg := range c.msgs {
+		if msg.Method == "" && msg.ID == id {
+			return msg.Error
+		}
+	}
+	c.t.Fatalf("要求%dの応答がありません。", id)
+	return nil
+}
+
+func (c *client) diagnostics() [][]Diagnostic {
+	res := [][]Diagnostic{}
+	for _, msg := range c.msgs {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params publishDiagnosticsParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				c.t.Fatal(err)
+			}
+			res = append(res, params.Diagnostics)
+		}
+	}
+	return res
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "position": Position{line, character}}
+}
+
+func span(line, start, end int) Range {
+	return Range{Position{line, start}, Position{line, end}}
+}
+
+func TestServer(t *testing.T) {
+	c := &client{t: t}
+	initialize := c.request("initialize", map[string]interface{}{})
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": uri, "text": source}})
+
+	definition := c.request("textDocument/definition", at(4, 13))
+	hoverFunc := c.request("textDocument/hover", at(4, 14))
+	hoverBuiltin := c.request("textDocument/hover", at(5, 6))
+	hoverParam := c.request("textDocument/hover", at(1, 7))
+	inner := c.request("textDocument/completion", at(2, 1))
+	outer := c.request("textDocument/completion", at(5, 0))
+	symbols := c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	prepare := c.request("textDocument/prepareRename", at(4, 9))
+	renameVar := c.request("textDocument/rename", map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "position": Position{4, 9}, "newName": "値"})
+	renameFunc := c.request("textDocument/rename", map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "position": Position{0, 3}, "newName": "二倍"})
+	renameKeyword := c.request("textDocument/rename", map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "position": Position{4, 9}, "newName": "もし"})
+	unknown := c.request("textDocument/unknown", map[string]interface{}{})
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "x = (1 +"}},
+	})
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "s = \"😀\" 未定義(s)\n{ y = 1 }"}},
+	})
+	// 本体のない関数でも止まらない
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "関数 f()"}},
+	})
+	partial := c.request("textDocument/completion", at(0, 0))
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	c.run()
+
+	var capabilities struct {
+		Capabilities struct {
+			RenameProvider struct{ PrepareProvider bool }
+		}
+	}
+	c.result(initialize, &capabilities)
+	if !capabilities.Capabilities.RenameProvider.PrepareProvider {
+		t.Fatalf("capabilities: %+v", capabilities)
+	}
+
+	var location Location
+	c.result(definition, &location)
+	if location.URI != uri || location.Range != span(0, 3, 4) {
+		t.Fatalf("definition: %+v", location)
+	}
+
+	hovers := []struct {
+		id     int
+		expect string
+		rng    Range
+	}{
+		{hoverFunc, "関数 倍(数, 係数=2)", span(4, 13, 14)},
+		{hoverBuiltin, "組み込み関数 表示", span(5, 4, 8)},
+		{hoverParam, "引数 数", span(1, 6, 7)},
+	}
+	for i, v := range hovers {
+		var hover Hover
+		c.result(v.id, &hover)
+		if hover.Contents.Value != "```jpl\n"+v.expect+"\n```" || hover.Range != v.rng {
+			t.Fatalf("hover%d: %+v", i, hover)
+		}
+	}
+
+	labels := func(id int) map[string]int {
+		var items []CompletionItem
+		c.result(id, &items)
+		res := map[string]int{}
+		for _, item := range items {
+			res[item.Label] = item.Kind
+		}
+		return res
+	}
+	innerItems := labels(inner)
+	for name, kind := range map[string]int{"結果": variableCompletion, "数": variableCompletion, "倍": functionCompletion, "表示": functionCompletion, "もし": keywordCompletion} {
+		if innerItems[name] != kind {
+			t.Fatalf("completion: %sがありません。%v", name, innerItems)
+		}
+	}
+	if outerItems := labels(outer); outerItems["結果"] != 0 || outerItems["x"] != variableCompletion {
+		t.Fatalf("completion: %v", outerItems)
+	}
+
+	var docSymbols []DocumentSymbol
+	c.result(symbols, &docSymbols)
+	names := []string{}
+	for _, sym := range docSymbols {
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, " ") != "倍 s x" || len(docSymbols[0].Children) != 3 || docSymbols[0].Range != (Range{Position{0, 3}, Position{3, 1}}) {
+		t.Fatalf("documentSymbol: %+v", docSymbols)
+	}
+
+	var prepared Range
+	c.result(prepare, &prepared)
+	if prepared != span(4, 9, 10) {
+		t.Fatalf("prepareRename: %+v", prepared)
+	}
+
+	renames := []struct {
+		id     int
+		expect []Range
+	}{
+		{renameVar, []Range{span(4, 9, 10), span(5, 0, 1)}},
+		{renameFunc, []Range{span(0, 3, 4), span(4, 13, 14)}},
+	}
+	for i, v := range renames {
+		var edit WorkspaceEdit
+		c.result(v.id, &edit)
+		edits := edit.Changes[uri]
+		if len(edits) != len(v.expect) {
+			t.Fatalf("rename%d: %+v", i, edit)
+		}
+		for j, e := range edits {
+			if e.Range != v.expect[j] {
+				t.Fatalf("rename%d: %+v", i, edit)
+			}
+		}
+	}
+	if err := c.error(renameKeyword); err == nil || err.Message != "「もし」は名前に使えません。" {
+		t.Fatalf("rename: %+v", err)
+	}
+	if err := c.error(unknown); err == nil || err.Code != methodNotFound {
+		t.Fatalf("unknown: %+v", err)
+	}
+
+	var items []CompletionItem
+	c.result(partial, &items)
+
+	diagnostics := c.diagnostics()
+	if len(diagnostics) != 4 || len(diagnostics[0]) != 0 {
+		t.Fatalf("diagnostics: %+v", diagnostics)
+	}
+	if d := diagnostics[1]; len(d) == 0 || d[0].Range != span(0, 8, 9) || d[0].Severity != SeverityError || d[0].Message != "式が必要です。" {
+		t.Fatalf("diagnostics: %+v", d)
+	}
+	d := diagnostics[2]
+	if len(d) != 2 ||
+		d[0].Message != "関数「未定義」が宣言されていません。" || d[0].Range != span(0, 9, 12) || d[0].Severity != SeverityWarning ||
+		d[1].Message != "変数「y」は使われていません。" || d[1].Range != span(1, 2, 3) || d[1].Severity != SeverityHint {
+		t.Fatalf("diagnostics: %+v", d)
+	}
+}
+
+// 書きかけの文書でも止まらずに、構文の誤りと読めたところまでの名前の問題を報告する
+func TestHalfTypedDocuments(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect []string
+		rng    Range // 最初の診断の範囲
+	}{
+		{"関数(", []string{"\"関数\"キーワードの後には識別子が必要です。", "式が必要です。"}, span(0, 2, 3)},
+		{"[a、", []string{"括弧を閉じてください。"}, span(0, 3, 4)},
+		{"関数 f() {", []string{"括弧を閉じてください。"}, span(0, 8, 9)},
+		{"[a、...] = 1", []string{"整数ではありません。 取得した文字=]"}, span(0, 6, 7)},
+		{"f(を)", []string{"整数ではありません。 取得した文字=を"}, span(0, 2, 3)},
+		{"関数 倍(数) {\n\t", []string{"括弧を閉じてください。"}, span(1, 1, 2)},
+	}
+
+	c := &client{t: t}
+	c.request("initialize", map[string]interface{}{})
+	for _, v := range tests {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]string{"uri": uri},
+			"contentChanges": []map[string]string{{"text": v.text}},
+		})
+	}
+	// 本体の閉じていない関数の中でも仮引数を補完できる
+	completion := c.request("textDocument/completion", at(1, 1))
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	c.run()
+
+	diagnostics := c.diagnostics()
+	if len(diagnostics) != len(tests) {
+		t.Fatalf("diagnostics: %+v", diagnostics)
+	}
+	for i, v := range tests {
+		d := diagnostics[i]
+		messages := []string{}
+		for _, diag := range d {
+			messages = append(messages, diag.Message)
+		}
+		if strings.Join(messages, " ") != strings.Join(v.expect, " ") || d[0].Range != v.rng || d[0].Severity != SeverityError {
+			t.Fatalf("test%d : %+v", i, d)
+		}
+	}
+
+	var items []CompletionItem
+	c.result(completion, &items)
+	found := false
+	for _, item := range items {
+		found = found || item.Label == "数" && item.Kind == variableCompletion
+	}
+	if !found {
+		t.Fatalf("completion: %+v", items)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	line := "a😀漢b"
+	tests := []struct {
+		column    int // 文字単位。1から数える
+		character int // UTF-16 の符号単位。0から数える
+	}{
+		{1, 0},
+		{2, 1},
+		{3, 3},
+		{4, 4},
+		{5, 5},
+	}
+	for i, v := range tests {
+		if got := utf16Column(line, v.column); got != v.character {
+			t.Fatalf("test%d : utf16Column got=%d expect=%d", i, got, v.character)
+		}
+		if got := runeColumn(line, v.character); got != v.column {
+			t.Fatalf("test%d : runeColumn got=%d expect=%d", i, got, v.column)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Language Server Protocol のサーバ。エディタに構文の誤りや名前の情報を知らせる。
+//
+// 文書は変更のたびに全体を受け取り、字句解析から名前の解決までやり直す。
+// 位置の列は UTF-16 の符号単位で受け渡し、中では文字(rune)単位で扱う。
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"jpl/ast"
+	"jpl/evaluator"
+	"jpl/resolver"
+	"jpl/token"
+	"jpl/transport"
+)
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	builtins []string
+	known    map[string]bool // 組み込み関数の名前
+	docs     map[string]*document
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		builtins: evaluator.New().BuiltinNames(),
+		known:    map[string]bool{},
+		docs:     map[string]*document{},
+	}
+	for _, name := range s.builtins {
+		s.known[name] = true
+	}
+	return s
+}
+
+// in からメッセージを読んで応答する。exit を受け取るか in が終わると戻る
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Serve()
+}
+
+func (s *Server) Serve() error {
+	for {
+		body, err := transport.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return fmt.Errorf("メッセージが正しくありません。%s", err)
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		s.handle(&msg)
+	}
+}
+
+func (s *Server) isKnown(name string) bool {
+	return s.known[name]
+}
+
+// 要求なら応答を返し、通知なら処理だけする
+func (s *Server) handle(msg *message) {
+	result, err := s.dispatch(msg)
+	if msg.ID == nil {
+		return
+	}
+	res := &response{JSONRPC: "2.0", ID: msg.ID, Result: result}
+	if err != nil {
+		res.Result = nil
+		res.Error = err
+	}
+	transport.WriteMessage(s.out, res)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	transport.WriteMessage(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) dispatch(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"positionEncoding":       "utf-16",
+				"textDocumentSync":       1, // 変更のたびに全体を受け取る
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+				"renameProvider":         map[string]interface{}{"prepareProvider": true},
+			},
+			"serverInfo": map[string]interface{}{"name": "jpl"},
+		}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalid(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalid(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalid(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/definition":
+		return s.withPosition(msg, s.definition)
+	case "textDocument/hover":
+		return s.withPosition(msg, s.hover)
+	case "textDocument/completion":
+		return s.withPosition(msg, s.completion)
+	case "textDocument/prepareRename":
+		return s.withPosition(msg, s.prepareRename)
+	case "textDocument/rename":
+		return s.rename(msg)
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalid(err)
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(d), nil
+	default:
+		return nil, &responseError{Code: methodNotFound, Message: fmt.Sprintf("「%s」には対応していません。", msg.Method)}
+	}
+}
+
+func invalid(err error) *responseError {
+	return &responseError{Code: invalidParams, Message: fmt.Sprintf("引数が正しくありません。%s", err)}
+}
+
+func (s *Server) document(uri string) (*document, *responseError) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: requestFailed, Message: fmt.Sprintf("%sは開いていません。", uri)}
+	}
+	return d, nil
+}
+
+func (s *Server) withPosition(msg *message, fn func(*document, pos) (interface{}, *responseError)) (interface{}, *responseError) {
+	var params positionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, invalid(err)
+	}
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return fn(d, d.fromPosition(params.Position))
+}
+
+// 文書を解析し直して診断を送る
+func (s *Server) update(uri string, text string) {
+	d := newDocument(uri, text, s.isKnown)
+	s.docs[uri] = d
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(d)})
+}
+
+func (s *Server) diagnostics(d *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range d.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.rangeOf(pos{err.Line, err.Column}, 1),
+			Severity: SeverityError,
+			Source:   "jpl",
+			Message:  err.Message,
+		})
+	}
+	// 構文に誤りがあると読めなかった宣言があるので、名前の問題は知らせない
+	if len(d.errors) > 0 {
+		return diagnostics
+	}
+
+	for _, v := range d.analysis.Diagnostics {
+		diagnostic := Diagnostic{Range: d.nameRange(v.Node), Severity: SeverityWarning, Source: "jpl", Message: v.Message}
+		if v.Kind == resolver.Unused {
+			diagnostic.Severity = SeverityHint
+			diagnostic.Tags = []int{unnecessaryTag}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+func (s *Server) definition(d *document, p pos) (interface{}, *responseError) {
+	sym, _ := d.symbolAt(p)
+	if sym == nil || sym.Decl == nil {
+		return nil, nil
+	}
+	return Location{URI: d.uri, Range: d.declRange(sym)}, nil
+}
+
+// 宣言した名前の範囲。モジュールは読み込む文の始めにする
+func (d *document) declRange(sym *resolver.Symbol) Range {
+	if sym.Kind == resolver.ModuleSymbol {
+		return d.rangeOf(nodePos(sym.Decl), len([]rune("読み込む")))
+	}
+	return d.rangeOf(nodePos(sym.Decl), len([]rune(sym.Name)))
+}
+
+// 組み込み関数の名前。「表示する」のような動詞の形なら「する」を除いた名前
+func (s *Server) builtinName(name string) (string, bool) {
+	if s.known[name] {
+		return name, true
+	}
+	if stem := strings.TrimSuffix(name, "する"); stem != name && s.known[stem] {
+		return stem, true
+	}
+	return "", false
+}
+
+func (s *Server) hover(d *document, p pos) (interface{}, *responseError) {
+	if sym, ref := d.symbolAt(p); sym != nil {
+		return Hover{Contents: code(describe(sym)), Range: d.nameRange(ref)}, nil
+	}
+	if tok := d.identAt(p); tok != nil {
+		if name, ok := s.builtinName(tok.Literal); ok {
+			return Hover{
+				Contents: code("組み込み関数 " + name),
+				Range:    d.rangeOf(pos{tok.Line, tok.Column}, len([]rune(tok.Literal))),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func code(str string) MarkupContent {
+	return MarkupContent{Kind: "markdown", Value: "```jpl\n" + str + "\n```"}
+}
+
+func (s *Server) completion(d *document, p pos) (interface{}, *responseError) {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, sym := range d.visibleSymbols(p) {
+		kind := variableCompletion
+		switch sym.Kind {
+		case resolver.FunctionSymbol:
+			kind = functionCompletion
+		case resolver.ModuleSymbol:
+			kind = moduleCompletion
+		}
+		add(CompletionItem{Label: sym.Name, Kind: kind, Detail: describe(sym)})
+	}
+	for _, name := range s.builtins {
+		add(CompletionItem{Label: name, Kind: functionCompletion, Detail: "組み込み関数"})
+	}
+	for _, word := range token.Keywords() {
+		add(CompletionItem{Label: word, Kind: keywordCompletion})
+	}
+	return items, nil
+}
+
+// 名前を変えられる位置なら、その名前の範囲を返す
+func (s *Server) prepareRename(d *document, p pos) (interface{}, *responseError) {
+	sym, ref := d.symbolAt(p)
+	if sym == nil || sym.Kind == resolver.ModuleSymbol {
+		return nil, nil
+	}
+	return d.rangeOf(nodePos(ref), len([]rune(sym.Name))), nil
+}
+
+func (s *Server) rename(msg *message) (interface{}, *responseError) {
+	var params renameParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, invalid(err)
+	}
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, _ := d.symbolAt(d.fromPosition(params.Position))
+	if sym == nil {
+		return nil, &responseError{Code: requestFailed, Message: "名前を変えられる変数や関数がありません。"}
+	}
+	if sym.Kind == resolver.ModuleSymbol {
+		return nil, &responseError{Code: requestFailed, Message: "モジュールの名前は変えられません。"}
+	}
+	if !isIdent(params.NewName) {
+		return nil, &responseError{Code: requestFailed, Message: fmt.Sprintf("「%s」は名前に使えません。", params.NewName)}
+	}
+
+	// 呼び出しの「する」は残す
+	edits := []TextEdit{}
+	for _, ref := range sym.Refs {
+		edits = append(edits, TextEdit{Range: d.rangeOf(nodePos(ref), len([]rune(sym.Name))), NewText: params.NewName})
+	}
+	return WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}}, nil
+}
+
+// 一つの識別子として読める名前かどうか。予約語は使えない
+func isIdent(name string) bool {
+	tok := token.Tokenize(name)
+	return tok.Kind == token.IDENT && tok.Literal == name && tok.Next != nil && tok.Next.Kind == token.EOF
+}
+
+// 一番外側の名前を並べ、関数の中の名前はその関数の子にする
+func (s *Server) documentSymbols(d *document) []DocumentSymbol {
+	symbols := map[*ast.Node][]*resolver.Symbol{} // 囲む関数の宣言ごと。関数の外は nil
+	for _, scope := range d.analysis.Scopes {
+		fn := enclosingFunction(scope)
+		for _, sym := range scope.Symbols {
+			if sym.Decl != nil {
+				symbols[fn] = append(symbols[fn], sym)
+			}
+		}
+	}
+
+	var build func(fn *ast.Node) []DocumentSymbol
+	build = func(fn *ast.Node) []DocumentSymbol {
+		list := []DocumentSymbol{}
+		for _, sym := range symbols[fn] {
+			symbol := DocumentSymbol{
+				Name:           sym.Name,
+				Detail:         describe(sym),
+				Kind:           variableSymbolKind,
+				Range:          d.declRange(sym),
+				SelectionRange: d.declRange(sym),
+			}
+			switch sym.Kind {
+			case resolver.FunctionSymbol:
+				symbol.Kind = functionSymbolKind
+				if sym.Decl.NodeKind == ast.FUNC {
+					// 関数は名前から閉じ波括弧まで
+					end := d.closingBrace(sym.Decl)
+					symbol.Range.End = d.toPosition(pos{end.line, end.column + 1})
+					symbol.Children = build(sym.Decl)
+				}
+			case resolver.ModuleSymbol:
+				symbol.Kind = moduleSymbolKind
+			}
+			list = append(list, symbol)
+		}
+		return list
+	}
+	return build(nil)
+}
+
+// 範囲を囲む一番内側の関数の宣言。関数の外なら nil
+func enclosingFunction(scope *resolver.Scope) *ast.Node {
+	for ; scope != nil; scope = scope.Outer {
+		if scope.Node != nil && scope.Node.NodeKind == ast.FUNC {
+			return scope.Node
+		}
+	}
+	return nil
+}
@@ -1,13 +1,71 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"jpl/dap"
+	"jpl/debugger"
+	"jpl/evaluator"
+	"jpl/format"
+	"jpl/lsp"
+	"jpl/object"
 	"jpl/repl"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
+	files := flag.String("files", "all", "スクリプトに許すファイル操作 (all, read, none)。読み込むでのモジュールの読み込みは制限しない")
+	root := flag.String("root", "", "ファイル操作とモジュールの読み込みをこのディレクトリの中に限る")
+	useVM := flag.Bool("vm", false, "バイトコードにコンパイルして仮想機械で実行する")
+	optimize := flag.Bool("optimize", true, "実行する前に定数の計算などを済ませる")
+	maxDepth := flag.Int("max-depth", evaluator.DefaultMaxDepth, "関数呼び出しの深さの上限")
+	maxSteps := flag.Int("max-steps", 0, "繰り返しと関数呼び出しの回数の上限 (0は無制限)")
+	maxAllocations := flag.Int("max-allocations", 0, "作る値の数の上限 (0は無制限)")
+	maxStringSize := flag.Int("max-string-size", 0, "作る文字列のバイト数の上限 (0は無制限)")
+	timeout := flag.Duration("timeout", 0, "実行時間の上限 (0は無制限)")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		policy, ok := filePolicy(*files, *root)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "-filesにはall、read、noneのどれかを指定してください。\n")
+			os.Exit(2)
+		}
+		backend := evaluator.TreeWalker
+		if *useVM {
+			backend = evaluator.VM
+		}
+		newEvaluator := func() *evaluator.Evaluator {
+			e := evaluator.New()
+			e.Files = policy
+			e.Backend = backend
+			e.NoOptimize = !*optimize
+			e.Limits.MaxDepth = *maxDepth
+			e.Limits.MaxSteps = *maxSteps
+			e.Limits.MaxAllocations = *maxAllocations
+			e.Limits.MaxStringSize = *maxStringSize
+			e.SearchPath = filepath.SplitList(os.Getenv("JPL_PATH"))
+			return e
+		}
+		switch flag.Arg(0) {
+		case "debug":
+			os.Exit(debug(newEvaluator(), flag.Arg(1)))
+		case "dap":
+			os.Exit(serveDAP(newEvaluator, flag.Args()[1:]))
+		case "lsp":
+			os.Exit(serveLSP())
+		case "fmt":
+			os.Exit(formatFiles(flag.Args()[1:]))
+		}
+		os.Exit(run(newEvaluator(), flag.Arg(0), *timeout))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,3 +74,152 @@ func main() {
 	fmt.Printf("Hello %s!\n", user.Username)
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func filePolicy(files string, root string) (evaluator.FilePolicy, bool) {
+	policy := evaluator.FilePolicy{Root: root}
+	switch files {
+	case "all":
+	case "read":
+		policy.ReadOnly = true
+	case "none":
+		policy.Deny = true
+	default:
+		return policy, false
+	}
+	return policy, true
+}
+
+func run(e *evaluator.Evaluator, path string, timeout time.Duration) int {
+	e.Warnings = os.Stderr
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return report(e.EvalFileContext(ctx, path, object.NewEnvironment()))
+}
+
+// jpl debug ファイル
+func debug(e *evaluator.Evaluator, path string) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "使い方: jpl debug ファイル")
+		return 2
+	}
+	e.Warnings = os.Stderr
+
+	return report(debugger.New(e, os.Stdin, os.Stdout).Run(path, object.NewEnvironment()))
+}
+
+// jpl dap [-listen アドレス]
+// 標準入出力か、アドレスで待ち受けた接続で Debug Adapter Protocol を話す
+func serveDAP(newEvaluator func() *evaluator.Evaluator, args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	listen := flags.String("listen", "", "標準入出力の代わりにこのアドレスで接続を待つ (例: 127.0.0.1:4711)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var err error
+	if *listen != "" {
+		err = dap.ListenAndServe(*listen, newEvaluator)
+	} else {
+		err = dap.Serve(os.Stdin, os.Stdout, newEvaluator())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// jpl lsp
+// 標準入出力で Language Server Protocol を話す
+func serveLSP() int {
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// jpl fmt [-check] [-full-width] [-indent 数] [ファイル...]
+// ファイルを整えて書き換える。ファイルがなければ標準入力を整えて標準出力に書く。
+// -check では書き換えずに、整っていないファイルの名前を書いて1で終わる
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "書き換えずに、整っていないファイルの名前を書く")
+	fullWidth := flags.Bool("full-width", false, "記号と数字を全角で書く")
+	indent := flags.Int("indent", 0, "ブロックを字下げする空白の数 (0はタブ)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	style := format.Style{FullWidth: *fullWidth, Indent: "\t"}
+	if *indent > 0 {
+		style.Indent = strings.Repeat(" ", *indent)
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, err := format.Source(string(src), style)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if *check {
+			if out != string(src) {
+				fmt.Println("<標準入力>")
+				return 1
+			}
+			return 0
+		}
+		fmt.Print(out)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		out, err := format.Source(string(src), style)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+			status = 1
+			continue
+		}
+		if out == string(src) {
+			continue
+		}
+		if *check {
+			fmt.Println(path)
+			status = 1
+			continue
+		}
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// 実行の結果を表示して終了コードを返す
+func report(res object.Object) int {
+	if res.Type() == object.ERROR {
+		fmt.Fprintln(os.Stderr, res.Inspect())
+		return 1
+	}
+	if res.Type() != object.NULL {
+		fmt.Println(res.Inspect())
+	}
+	return 0
+}
@@ -0,0 +1,471 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// 変換する入れ子の深さの上限。循環した値で止まらなくなるのを防ぐ
+const maxConvertDepth = 100
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// Go の値をオブジェクトにする。
+// 構造体はフィールド名か jpl タグの名前をキーにした連想配列に、関数は引数と戻り値を変換する組み込み関数になる。
+// 関数が最後に error を返すと、nil でない時はスクリプトのエラーになる
+func FromGo(value interface{}) (Object, error) {
+	if value == nil {
+		return &Null{}, nil
+	}
+	return fromValue(reflect.ValueOf(value), 0)
+}
+
+func fromValue(v reflect.Value, depth int) (Object, error) {
+	if depth > maxConvertDepth {
+		return nil, fmt.Errorf("値の入れ子が深すぎて変換できません。")
+	}
+	if !v.IsValid() {
+		return &Null{}, nil
+	}
+	if v.Kind() != reflect.Interface && v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return &Null{}, nil
+		}
+		return v.Interface().(Object), nil
+	}
+	if v.Type() == timeType {
+		return &DateTime{Value: v.Interface().(time.Time)}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return &Boolean{Value: v.Bool()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: int(v.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return nil, fmt.Errorf("%dは整数として大きすぎます。", v.Uint())
+		}
+		return &Integer{Value: int(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		return fromValue(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &Null{}, nil
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			obj, err := fromValue(v.Index(i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		return mapFromValue(v, depth)
+	case reflect.Struct:
+		return structFromValue(v, depth)
+	case reflect.Func:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		return funcFromValue(v), nil
+	default:
+		return nil, fmt.Errorf("%sはオブジェクトに変換できません。", v.Type())
+	}
+}
+
+func mapFromValue(v reflect.Value, depth int) (Object, error) {
+	hash := NewHash()
+	// Go の map は順番が決まらないので、キーの表示で並べて順番を決める
+	type entry struct {
+		key   Hashable
+		value reflect.Value
+	}
+	entries := []entry{}
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := fromValue(iter.Key(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return nil, fmt.Errorf("%sは連想配列のキーに使えません。", key.Type())
+		}
+		entries = append(entries, entry{hashable, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key.Inspect() < entries[j].key.Inspect()
+	})
+	for _, e := range entries {
+		value, err := fromValue(e.value, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(e.key, value)
+	}
+	return hash, nil
+}
+
+// 構造体のフィールドと連想配列のキーの対応。jpl:"-" のフィールドと公開されていないフィールドは使わない
+func structFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := fieldName(t.Field(i)); ok {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+func structFromValue(v reflect.Value, depth int) (Object, error) {
+	hash := NewHash()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, err := fromValue(v.Field(i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(&String{Value: name}, value)
+	}
+	return hash, nil
+}
+
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag, ok := f.Tag.Lookup("jpl")
+	if !ok || tag == "" {
+		return f.Name, true
+	}
+	return tag, tag != "-"
+}
+
+// Go の関数を組み込み関数にする。名前は後から変えてよく、エラーメッセージには呼び出した時の名前を使う
+func funcFromValue(fn reflect.Value) *Builtin {
+	t := fn.Type()
+	builtin := &Builtin{}
+	builtin.Fn = func(args ...Object) Object {
+		in, err := funcArgs(t, args)
+		if err != nil {
+			return &Error{Message: fmt.Sprintf("関数%sの%s", builtin.Name, err)}
+		}
+
+		out := fn.Call(in)
+		if n := len(out); n > 0 && t.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return &Error{Message: err.Error()}
+			}
+			out = out[:n-1]
+		}
+
+		results := make([]Object, len(out))
+		for i, v := range out {
+			obj, err := fromValue(v, 0)
+			if err != nil {
+				return &Error{Message: err.Error()}
+			}
+			results[i] = obj
+		}
+		switch len(results) {
+		case 0:
+			return &Null{}
+		case 1:
+			return results[0]
+		default:
+			return &Tuple{Elements: results}
+		}
+	}
+	return builtin
+}
+
+func funcArgs(t reflect.Type, args []Object) ([]reflect.Value, error) {
+	n := t.NumIn()
+	if (t.IsVariadic() && len(args) < n-1) || (!t.IsVariadic() && len(args) != n) {
+		return nil, fmt.Errorf("引数の個数が正しくありません。")
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			argType = t.In(n - 1).Elem()
+		} else {
+			argType = t.In(i)
+		}
+		v := reflect.New(argType).Elem()
+		if err := toValue(arg, v, 0); err != nil {
+			return nil, fmt.Errorf("%d番目の引数: %s", i+1, err)
+		}
+		in[i] = v
+	}
+	return in, nil
+}
+
+// オブジェクトを target が指す Go の変数に入れる。target はポインタでなければならない。
+// target が interface{} なら、整数は int、連想配列は map[string]interface{} のように決まった型にする
+func ToGo(obj Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("変換先にはポインタが必要です。")
+	}
+	return toValue(obj, v.Elem(), 0)
+}
+
+func conversionError(obj Object, t reflect.Type) error {
+	return fmt.Errorf("%sを%sに変換できません。", obj.Type(), t)
+}
+
+func toValue(obj Object, v reflect.Value, depth int) error {
+	if depth > maxConvertDepth {
+		return fmt.Errorf("値の入れ子が深すぎて変換できません。")
+	}
+	t := v.Type()
+
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		value, err := toInterface(obj, depth)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if _, ok := obj.(*Null); ok {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return conversionError(obj, t)
+	}
+	if t == timeType {
+		d, ok := obj.(*DateTime)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		v.Set(reflect.ValueOf(d.Value))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		v.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		if v.OverflowInt(int64(i.Value)) {
+			return fmt.Errorf("%dは%sに収まりません。", i.Value, t)
+		}
+		v.SetInt(int64(i.Value))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("%dは%sに収まりません。", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			v.SetFloat(n.Value)
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return conversionError(obj, t)
+		}
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		v.SetString(s.Value)
+	case reflect.Pointer:
+		p := reflect.New(t.Elem())
+		if err := toValue(obj, p.Elem(), depth+1); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Slice, reflect.Array:
+		elements, ok := listElements(obj)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(elements), len(elements)))
+		} else if len(elements) != t.Len() {
+			return fmt.Errorf("要素の数が%d個の配列は%sに変換できません。", len(elements), t)
+		}
+		for i, e := range elements {
+			if err := toValue(e, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Keys))
+		for _, k := range hash.Keys {
+			pair := hash.Pairs[k]
+			key := reflect.New(t.Key()).Elem()
+			if err := toValue(pair.Key, key, depth+1); err != nil {
+				return err
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := toValue(pair.Value, value, depth+1); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return conversionError(obj, t)
+		}
+		// 構造体にないキーは無視する
+		for name, i := range structFields(t) {
+			value, ok := hash.Get(&String{Value: name})
+			if !ok {
+				continue
+			}
+			if err := toValue(value, v.Field(i), depth+1); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+	default:
+		return conversionError(obj, t)
+	}
+	return nil
+}
+
+func listElements(obj Object) ([]Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Elements, true
+	case *Tuple:
+		return obj.Elements, true
+	default:
+		return nil, false
+	}
+}
+
+// 型の決まっていない変数に入れる値。対応する Go の値がないものはオブジェクトのまま返す
+func toInterface(obj Object, depth int) (interface{}, error) {
+	if depth > maxConvertDepth {
+		return nil, fmt.Errorf("値の入れ子が深すぎて変換できません。")
+	}
+
+	switch obj := obj.(type) {
+	case *Null:
+		return nil, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *DateTime:
+		return obj.Value, nil
+	case *Array, *Tuple:
+		elements, _ := listElements(obj)
+		res := make([]interface{}, len(elements))
+		for i, e := range elements {
+			value, err := toInterface(e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = value
+		}
+		return res, nil
+	case *Hash:
+		return hashToInterface(obj, depth)
+	default:
+		return obj, nil
+	}
+}
+
+// キーが全て文字列なら map[string]interface{} に、そうでなければ map[interface{}]interface{} にする
+func hashToInterface(hash *Hash, depth int) (interface{}, error) {
+	stringKeys := true
+	for _, k := range hash.Keys {
+		if _, ok := hash.Pairs[k].Key.(*String); !ok {
+			stringKeys = false
+			break
+		}
+	}
+
+	if stringKeys {
+		res := make(map[string]interface{}, len(hash.Keys))
+		for _, k := range hash.Keys {
+			pair := hash.Pairs[k]
+			value, err := toInterface(pair.Value, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res[pair.Key.(*String).Value] = value
+		}
+		return res, nil
+	}
+
+	res := make(map[interface{}]interface{}, len(hash.Keys))
+	for _, k := range hash.Keys {
+		pair := hash.Pairs[k]
+		key, err := toInterface(pair.Key, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.TypeOf(key).Comparable() {
+			return nil, fmt.Errorf("%sは Go の map のキーに使えません。", pair.Key.Type())
+		}
+		value, err := toInterface(pair.Value, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = value
+	}
+	return res, nil
+}
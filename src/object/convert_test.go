@@ -0,0 +1,227 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type person struct {
+	Name    string `jpl:"名前"`
+	Age     int    `jpl:"年齢"`
+	Tags    []string
+	Secret  string `jpl:"-"`
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	var nilPointer *person
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		input  interface{}
+		expect string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{"日本語", "日本語"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{person{Name: "山田", Age: 30, Tags: []string{"x"}, Secret: "秘密"}, "{名前: 山田, 年齢: 30, Tags: [x]}"},
+		{&person{Name: "鈴木"}, "{名前: 鈴木, 年齢: 0, Tags: null}"},
+		{nilPointer, "null"},
+		{&Integer{Value: 3}, "3"},
+		{date, (&DateTime{Value: date}).Inspect()},
+	}
+
+	for i, v := range tests {
+		obj, err := FromGo(v.input)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		if val := obj.Inspect(); val != v.expect {
+			t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+		}
+	}
+
+	for i, v := range []interface{}{make(chan int), map[[1]int]int{{1}: 1}, uint64(1 << 63)} {
+		if _, err := FromGo(v); err == nil {
+			t.Fatalf("test%d : 変換できない値でエラーになりません。\n", i)
+		}
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	divide := func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("0では割れません。")
+		}
+		return a / b, nil
+	}
+	join := func(sep string, strs ...string) string {
+		res := ""
+		for i, s := range strs {
+			if i > 0 {
+				res += sep
+			}
+			res += s
+		}
+		return res
+	}
+	pair := func(p person) (string, int) {
+		return p.Name, p.Age
+	}
+
+	tests := []struct {
+		fn     interface{}
+		args   []Object
+		expect string
+	}{
+		{divide, []Object{&Integer{Value: 7}, &Integer{Value: 2}}, "3"},
+		{divide, []Object{&Integer{Value: 7}, &Integer{Value: 0}}, "Error:0では割れません。"},
+		{divide, []Object{&Integer{Value: 7}}, "Error:関数fの引数の個数が正しくありません。"},
+		{divide, []Object{&Integer{Value: 7}, &String{Value: "a"}}, "Error:関数fの2番目の引数: STRINGをintに変換できません。"},
+		{join, []Object{&String{Value: "、"}, &String{Value: "a"}, &String{Value: "b"}}, "a、b"},
+		{join, []Object{&String{Value: "、"}}, ""},
+		{pair, []Object{newStringHash(map[string]Object{"名前": &String{Value: "山田"}, "年齢": &Integer{Value: 30}})}, "(山田, 30)"},
+		{func() {}, nil, "null"},
+	}
+
+	for i, v := range tests {
+		obj, err := FromGo(v.fn)
+		if err != nil {
+			t.Fatalf("test%d : %s\n", i, err)
+		}
+		builtin := obj.(*Builtin)
+		builtin.Name = "f"
+		if val := builtin.Fn(v.args...).Inspect(); val != v.expect {
+			t.Fatalf("test%d : got=%s expect=%s\n", i, val, v.expect)
+		}
+	}
+}
+
+func newStringHash(pairs map[string]Object) *Hash {
+	hash := NewHash()
+	for k, v := range pairs {
+		hash.Set(&String{Value: k}, v)
+	}
+	return hash
+}
+
+func TestToGo(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+
+	var i int
+	var i8 int8
+	var u uint
+	var f float64
+	var s string
+	var b bool
+	var ints []int
+	var fixed [2]int
+	var m map[string]int
+	var p person
+	var pp *person
+	var value interface{}
+	var obj Object
+	var tm time.Time
+
+	hash := newStringHash(map[string]Object{
+		"名前":     &String{Value: "山田"},
+		"年齢":     &Integer{Value: 30},
+		"Tags":   &Array{Elements: []Object{&String{Value: "x"}}},
+		"Secret": &String{Value: "秘密"},
+		"その他":    &Integer{Value: 1},
+	})
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		obj    Object
+		target interface{}
+		expect interface{}
+	}{
+		{&Integer{Value: 5}, &i, 5},
+		{&Integer{Value: 100}, &i8, int8(100)},
+		{&Integer{Value: 5}, &u, uint(5)},
+		{&Integer{Value: 5}, &f, 5.0},
+		{&Float{Value: 1.5}, &f, 1.5},
+		{&String{Value: "あ"}, &s, "あ"},
+		{&Boolean{Value: true}, &b, true},
+		{array, &ints, []int{1, 2}},
+		{&Tuple{Elements: array.Elements}, &fixed, [2]int{1, 2}},
+		{newStringHash(map[string]Object{"a": &Integer{Value: 1}}), &m, map[string]int{"a": 1}},
+		{hash, &p, person{Name: "山田", Age: 30, Tags: []string{"x"}}},
+		{hash, &pp, &person{Name: "山田", Age: 30, Tags: []string{"x"}}},
+		{&Null{}, &pp, (*person)(nil)},
+		{array, &value, []interface{}{1, 2}},
+		{newStringHash(map[string]Object{"a": array}), &value, map[string]interface{}{"a": []interface{}{1, 2}}},
+		{&Null{}, &value, nil},
+		{array, &obj, array},
+		{&DateTime{Value: date}, &tm, date},
+	}
+
+	for n, v := range tests {
+		if err := ToGo(v.obj, v.target); err != nil {
+			t.Fatalf("test%d : %s\n", n, err)
+		}
+		if got := reflect.ValueOf(v.target).Elem().Interface(); !reflect.DeepEqual(got, v.expect) {
+			t.Fatalf("test%d : got=%#v expect=%#v\n", n, got, v.expect)
+		}
+	}
+
+	// キーが文字列でない連想配列
+	intKeys := NewHash()
+	intKeys.Set(&Integer{Value: 1}, &String{Value: "a"})
+	if err := ToGo(intKeys, &value); err != nil || !reflect.DeepEqual(value, map[interface{}]interface{}{1: "a"}) {
+		t.Fatalf("got=%#v %v\n", value, err)
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i int
+	var i8 int8
+	var u uint
+	var fixed [3]int
+	var p person
+
+	tests := []struct {
+		obj    Object
+		target interface{}
+		expect string
+	}{
+		{&String{Value: "a"}, &i, "STRINGをintに変換できません。"},
+		{&Integer{Value: 1000}, &i8, "1000はint8に収まりません。"},
+		{&Integer{Value: -1}, &u, "-1はuintに収まりません。"},
+		{&Null{}, &i, "NULLをintに変換できません。"},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &fixed, "要素の数が1個の配列は[3]intに変換できません。"},
+		{newStringHash(map[string]Object{"年齢": &String{Value: "a"}}), &p, "年齢: STRINGをintに変換できません。"},
+		{&Integer{Value: 1}, i, "変換先にはポインタが必要です。"},
+	}
+
+	for n, v := range tests {
+		err := ToGo(v.obj, v.target)
+		if err == nil || err.Error() != v.expect {
+			t.Fatalf("test%d : got=%v expect=%s\n", n, err, v.expect)
+		}
+	}
+}
+
+// 変換して戻すと元の値になる
+func TestRoundTrip(t *testing.T) {
+	in := person{Name: "山田", Age: 30, Tags: []string{"a", "b"}}
+	obj, err := FromGo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out person
+	if err := ToGo(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("got=%#v expect=%#v\n", out, in)
+	}
+}
@@ -0,0 +1,22 @@
+package object
+
+import (
+	"time"
+)
+
+// 日付と時刻
+type DateTime struct {
+	Value time.Time
+}
+
+func (d *DateTime) Type() ObjectType {
+	return DATETIME
+}
+
+func (d *DateTime) Inspect() string {
+	return d.Value.Format("2006-01-02 15:04:05")
+}
+
+func (d *DateTime) HashKey() HashKey {
+	return HashKey{Type: d.Type(), Value: uint64(d.Value.UnixNano())}
+}
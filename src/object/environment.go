@@ -1,27 +1,49 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	store map[string]Object
+	store map[string]*Object // 値の入れ物は変数がある限り変わらない。Ref で覚えておける
+	slots []Object           // 解決済みの変数。位置で参照する
+	names []string           // slots の変数の名前。デバッガで実行する時だけ設定する
 	outer *Environment
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
+	s := make(map[string]*Object)
 	return &Environment{store: s}
 }
 
+// 変数を定義するまで表は作らない。ブロックの多くは変数を定義しないので
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	return &Environment{outer: outer}
+}
+
+// 解決済みの変数を位置で参照する環境を作る
+func NewScopeEnvironment(outer *Environment, size int) *Environment {
+	env := &Environment{outer: outer}
+	if size > 0 {
+		env.slots = make([]Object, size)
+	}
 	return env
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	if cell, ok := e.store[name]; ok {
+		return *cell, true
+	}
+	if e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return nil, false
+}
+
+// 外側の環境は探さず、この環境に定義された変数だけを探す
+func (e *Environment) GetLocal(name string) (Object, bool) {
+	if cell, ok := e.store[name]; ok {
+		return *cell, true
 	}
-	return obj, ok
+	return nil, false
 }
 
 func (e *Environment) Set(name string, val Object) Object {
@@ -37,6 +59,108 @@ func (e *Environment) Set(name string, val Object) Object {
 		}
 		curEnv = curEnv.outer
 	}
-	curEnv.store[name] = val
+	curEnv.define(name, val)
+	return val
+}
+
+// 外側の環境に同じ名前があっても、この環境に変数を定義する
+func (e *Environment) Define(name string, val Object) Object {
+	e.define(name, val)
+	return val
+}
+
+func (e *Environment) define(name string, val Object) {
+	if cell, ok := e.store[name]; ok {
+		*cell = val
+		return
+	}
+	if e.store == nil {
+		e.store = make(map[string]*Object)
+	}
+	e.store[name] = &val
+}
+
+// 名前で探した変数の入れ物を覚えておく。同じ環境で見つかる間は表を引かずに済む
+type Ref struct {
+	env  *Environment
+	cell *Object
+}
+
+// Get と同じように変数を探す。見つかった入れ物を ref に覚える
+func (e *Environment) GetRef(name string, ref *Ref) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if len(env.store) == 0 {
+			continue
+		}
+		if env == ref.env {
+			return *ref.cell, true
+		}
+		if cell, ok := env.store[name]; ok {
+			ref.env, ref.cell = env, cell
+			return *cell, true
+		}
+	}
+	return nil, false
+}
+
+// Set と同じように変数に代入する。代入した入れ物を ref に覚える
+func (e *Environment) SetRef(name string, val Object, ref *Ref) {
+	if _, ok := e.GetRef(name, ref); ok {
+		*ref.cell = val
+		return
+	}
+	e.define(name, val)
+	ref.env, ref.cell = e, e.store[name]
+}
+
+// 外側の環境を返す。一番外側なら nil を返す
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+func (e *Environment) at(depth int) *Environment {
+	env := e
+	for i := 0; i < depth; i++ {
+		env = env.outer
+	}
+	return env
+}
+
+// depth 段外側の環境の slot 番目の変数を返す。まだ代入されていなければ false を返す
+func (e *Environment) GetAt(depth int, slot int) (Object, bool) {
+	obj := e.at(depth).slots[slot]
+	return obj, obj != nil
+}
+
+func (e *Environment) SetAt(depth int, slot int, val Object) Object {
+	e.at(depth).slots[slot] = val
 	return val
 }
+
+// 位置で参照する変数に名前を付ける。Variables で名前と値を一覧できるようになる
+func (e *Environment) SetNames(names []string) {
+	e.names = names
+}
+
+type Variable struct {
+	Name  string
+	Value Object
+}
+
+// この環境に定義された変数を名前の順に返す。外側の環境は含めない。
+// 位置で参照する変数は SetNames で名前を付けたものだけ返す
+func (e *Environment) Variables() []Variable {
+	vars := []Variable{}
+	for name, cell := range e.store {
+		vars = append(vars, Variable{Name: name, Value: *cell})
+	}
+	for i, name := range e.names {
+		if i < len(e.slots) && e.slots[i] != nil {
+			vars = append(vars, Variable{Name: name, Value: e.slots[i]})
+		}
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+	return vars
+}
@@ -0,0 +1,33 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 読み込んだファイルの最上位の変数と関数をまとめたもの
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE
+}
+
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("モジュール(%s)", m.Name)
+}
+
+// 「_」で始まる名前はモジュールの外からは使えない
+func IsExported(name string) bool {
+	return !strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "＿")
+}
+
+func (m *Module) Get(name string) (Object, bool) {
+	if !IsExported(name) {
+		return nil, false
+	}
+	return m.Env.GetLocal(name)
+}
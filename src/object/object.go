@@ -2,6 +2,9 @@ package object
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
 
 	"jpl/ast"
@@ -16,6 +19,14 @@ const (
 	NULL = "NULL"
 	RETURN_VALUE = "RETURN_VALUE"
 	FUNCTION = "FUNCTION"
+	ARRAY = "ARRAY"
+	HASH = "HASH"
+	TUPLE = "TUPLE"
+	STRING = "STRING"
+	MODULE = "MODULE"
+	FLOAT = "FLOAT"
+	BUILTIN = "BUILTIN"
+	DATETIME = "DATETIME"
 )
 
 type Object interface {
@@ -23,8 +34,16 @@ type Object interface {
 	Inspect() string
 }
 
+type ErrorKind int
+
+const (
+	RuntimeError ErrorKind = iota // スクリプトの誤りによるエラー
+	LimitError // 実行の上限や期限に達して止めた
+)
+
 type Error struct {
 	Message string
+	Kind ErrorKind
 }
 func (e *Error) Type() ObjectType {
 	return ERROR
@@ -42,6 +61,39 @@ func (i *Integer) Type() ObjectType {
 func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+func (f *Float) Type() ObjectType {
+	return FLOAT
+}
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	// 整数と区別できるように、整数値の小数には「.0」を付ける
+	if !strings.ContainsAny(str, ".") && !math.IsInf(f.Value, 0) && !math.IsNaN(f.Value) {
+		str += ".0"
+	}
+	return str
+}
+
+type String struct {
+	Value string
+}
+func (s *String) Type() ObjectType {
+	return STRING
+}
+func (s *String) Inspect() string {
+	return s.Value
+}
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
 
 type Boolean struct {
 	Value bool
@@ -52,6 +104,13 @@ func (b *Boolean) Type() ObjectType {
 func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 type Null struct {}
 func (n *Null) Type() ObjectType {
@@ -72,6 +131,7 @@ func (r *ReturnValue) Inspect() string {
 }
 
 type Function struct {
+	Name string
 	Params []*ast.Node
 	Body *ast.Node
 	Env *Environment
@@ -82,8 +142,62 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	params := []string{}
 	for _, v := range f.Params {
-		params = append(params, v.Ident)
+		params = append(params, v.String())
 	}
 
 	return fmt.Sprintf("関数(%s)\n", strings.Join(params, ","))
-}
\ No newline at end of file
+}
+// 関数名と仮引数の並び。例: f(a, b=10, …残り)
+func (f *Function) Signature() string {
+	params := []string{}
+	for _, v := range f.Params {
+		params = append(params, v.String())
+	}
+
+	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(params, ", "))
+}
+
+type Array struct {
+	Elements []Object
+}
+func (a *Array) Type() ObjectType {
+	return ARRAY
+}
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, v := range a.Elements {
+		elements = append(elements, v.Inspect())
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+// 関数から戻される複数の値
+type Tuple struct {
+	Elements []Object
+}
+func (t *Tuple) Type() ObjectType {
+	return TUPLE
+}
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, v := range t.Elements {
+		elements = append(elements, v.Inspect())
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(elements, ", "))
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// Goで書かれた関数
+type Builtin struct {
+	Name string
+	Fn BuiltinFunction
+}
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN
+}
+func (b *Builtin) Inspect() string {
+	return fmt.Sprintf("組み込み関数(%s)", b.Name)
+}
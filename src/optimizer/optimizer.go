@@ -0,0 +1,165 @@
+package optimizer
+
+import (
+	"jpl/ast"
+	"jpl/object"
+)
+
+// 定数の計算に使う評価器の処理。計算の結果が評価器と変わらないように、評価器と同じものを使う
+type Evaluator interface {
+	Infix(op ast.NodeKind, left object.Object, right object.Object) object.Object
+	IsTruthy(obj object.Object) bool
+}
+
+type optimizer struct {
+	e Evaluator
+}
+
+// 構文木をその場で書き換えて、実行する前にできる計算を済ませる。
+// 定数同士の計算を畳み込み、条件が定数のもしの分岐を取り除き、単項のマイナスを符号反転にする
+func Optimize(program *ast.Program, e Evaluator) *ast.Program {
+	o := &optimizer{e: e}
+	program.Nodes = o.stmts(program.Nodes)
+	return program
+}
+
+// 最後の文は値として使われるので、途中の文だけ取り除く
+func (o *optimizer) stmts(nodes []*ast.Node) []*ast.Node {
+	res := nodes[:0]
+	for i, v := range nodes {
+		v = o.node(v)
+		if i < len(nodes)-1 && isEmptyBlock(v) {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+func (o *optimizer) nodes(nodes []*ast.Node) {
+	for i, v := range nodes {
+		nodes[i] = o.node(v)
+	}
+}
+
+func (o *optimizer) node(node *ast.Node) *ast.Node {
+	if node == nil {
+		return nil
+	}
+
+	switch node.NodeKind {
+	case ast.FUNC:
+		// 既定値は関数の形式として表示されるので書き換えない
+		node.Body = o.node(node.Body)
+		return node
+	case ast.BLOCK:
+		node.Stmts = o.stmts(node.Stmts)
+		return node
+	case ast.IF, ast.TERNARY:
+		return o.branch(node)
+	case ast.FOR:
+		node.Condition = o.node(node.Condition)
+		node.Then = o.node(node.Then)
+		if cond, ok := o.constant(node.Condition); ok && !o.e.IsTruthy(cond) {
+			return ast.NewNode(ast.BLOCK)
+		}
+		return node
+	case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.EQ, ast.NOT_EQ, ast.GT, ast.GE:
+		return o.binary(node)
+	}
+
+	node.Lhs = o.node(node.Lhs)
+	node.Rhs = o.node(node.Rhs)
+	node.Condition = o.node(node.Condition)
+	node.Then = o.node(node.Then)
+	node.Else = o.node(node.Else)
+	node.Body = o.node(node.Body)
+	o.nodes(node.Params)
+	o.nodes(node.Stmts)
+	return node
+}
+
+func (o *optimizer) binary(node *ast.Node) *ast.Node {
+	node.Lhs = o.node(node.Lhs)
+	node.Rhs = o.node(node.Rhs)
+
+	if val, ok := o.constant(node); ok {
+		if literal := newLiteral(val); literal != nil {
+			return literal
+		}
+		return node
+	}
+
+	// 構文解析で「-x」は「0 - x」になっている
+	if node.NodeKind == ast.SUB && node.Lhs.NodeKind == ast.INTEGER && node.Lhs.Num == 0 {
+		neg := ast.NewNode(ast.NEG)
+		neg.Lhs = node.Rhs
+		return neg
+	}
+	return node
+}
+
+// 条件が定数なら、選ばれる方の節に置き換える。選ばれる節がなければ空のブロックにする
+func (o *optimizer) branch(node *ast.Node) *ast.Node {
+	node.Condition = o.node(node.Condition)
+	node.Then = o.node(node.Then)
+	node.Else = o.node(node.Else)
+
+	cond, ok := o.constant(node.Condition)
+	if !ok {
+		return node
+	}
+	if o.e.IsTruthy(cond) {
+		return node.Then
+	}
+	if node.Else != nil {
+		return node.Else
+	}
+	return ast.NewNode(ast.BLOCK)
+}
+
+// 定数の式なら値を返す。エラーになる計算は実行時に同じエラーを出すために畳み込まない
+func (o *optimizer) constant(node *ast.Node) (object.Object, bool) {
+	switch node.NodeKind {
+	case ast.INTEGER:
+		return &object.Integer{Value: node.Num}, true
+	case ast.FLOAT:
+		return &object.Float{Value: node.Float}, true
+	case ast.STRING:
+		return &object.String{Value: node.Str}, true
+	case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.EQ, ast.NOT_EQ, ast.GT, ast.GE:
+		left, ok := o.constant(node.Lhs)
+		if !ok {
+			return nil, false
+		}
+		right, ok := o.constant(node.Rhs)
+		if !ok {
+			return nil, false
+		}
+		res := o.e.Infix(node.NodeKind, left, right)
+		if res.Type() == object.ERROR {
+			return nil, false
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// 値を表す節を作る。真偽値のように書き表せない値なら nil を返す
+func newLiteral(val object.Object) *ast.Node {
+	switch val := val.(type) {
+	case *object.Integer:
+		return ast.NewIntegerNode(val.Value)
+	case *object.Float:
+		return ast.NewFloatNode(val.Value)
+	case *object.String:
+		return ast.NewStringNode(val.Value)
+	default:
+		return nil
+	}
+}
+
+func isEmptyBlock(node *ast.Node) bool {
+	return node.NodeKind == ast.BLOCK && len(node.Stmts) == 0
+}
@@ -0,0 +1,119 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"jpl/ast"
+	"jpl/object"
+	"jpl/parser"
+	"jpl/token"
+)
+
+// 整数と文字列だけを計算する評価器
+type testEvaluator struct{}
+
+func (testEvaluator) Infix(op ast.NodeKind, left object.Object, right object.Object) object.Object {
+	if l, ok := left.(*object.String); ok {
+		if r, ok := right.(*object.String); ok && op == ast.ADD {
+			return &object.String{Value: l.Value + r.Value}
+		}
+		return &object.Error{Message: "文字列に使えない演算子です。"}
+	}
+
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return &object.Error{Message: "数値が必要です。"}
+	}
+	switch op {
+	case ast.ADD:
+		return &object.Integer{Value: l.Value + r.Value}
+	case ast.SUB:
+		return &object.Integer{Value: l.Value - r.Value}
+	case ast.MUL:
+		return &object.Integer{Value: l.Value * r.Value}
+	case ast.DIV:
+		if r.Value == 0 {
+			return &object.Error{Message: "0で割ることはできません。"}
+		}
+		return &object.Integer{Value: l.Value / r.Value}
+	case ast.EQ:
+		return &object.Boolean{Value: l.Value == r.Value}
+	case ast.GT:
+		return &object.Boolean{Value: l.Value < r.Value}
+	default:
+		return &object.Error{Message: "対応していない演算子です"}
+	}
+}
+
+func (testEvaluator) IsTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value != 0
+	default:
+		return true
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{"x * (60 * 60)", "x * 3600"},
+		{"x * 60 * 60", "(x * 60) * 60"},
+		{`"あ" + "い"`, `"あい"`},
+		{"1 ÷ 0", "1 / 0"},
+		{"1 + \"あ\"", `1 + "あ"`},
+		{"-5", "-5"},
+		{"-x", "-x"},
+		{"-(x + 1)", "-(x + 1)"},
+		{"a = -(2 * 3)", "a = -6"},
+		{"もし 1 == 1 ならば x それ以外 y", "x"},
+		{"もし 1 == 2 ならば x それ以外 y", "y"},
+		{"もし 1 < 2 ならば { x = 1 }", "{ x = 1 }"},
+		{"もし 0 ならば x", "{  }"},
+		{"もし x ならば 1 + 1", "もし x ならば 2"},
+		{"1 == 1 なら x でなければ y", "x"},
+		{"0 ならば 繰り返す x", "{  }"},
+		{"x < 10 ならば 繰り返す x += 2 * 2", "x < 10 ならば 繰り返す x += 4"},
+		{"関数 f(a=1+1) { a * (2 + 3) }", "関数 f(a=1 + 1) { a * 5 }"},
+		{"f(1 + 2、[3 * 4])", "f(3, [12])"},
+	}
+
+	for i, v := range tests {
+		program, errors := parser.Parse(token.Tokenize(v.input))
+		if len(errors) > 0 {
+			t.Fatalf("test%d : %v\n", i, errors)
+		}
+
+		Optimize(program, testEvaluator{})
+		if len(program.Nodes) != 1 {
+			t.Fatalf("test%d : nodes got=%d expect=%d\n", i, len(program.Nodes), 1)
+		}
+		if got := program.Nodes[0].String(); got != v.expect {
+			t.Fatalf("test%d : got=%s expect=%s\n", i, got, v.expect)
+		}
+	}
+}
+
+// 途中の文が空になれば取り除き、最後の文は値として残す
+func TestOptimizeRemovesDeadStatements(t *testing.T) {
+	program, errors := parser.Parse(token.Tokenize("もし 0 ならば x a もし 0 ならば y"))
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	Optimize(program, testEvaluator{})
+	strs := []string{}
+	for _, v := range program.Nodes {
+		strs = append(strs, v.String())
+	}
+	if got := strings.Join(strs, " / "); got != "a / {  }" {
+		t.Fatalf("got=%s expect=%s\n", got, "a / {  }")
+	}
+}
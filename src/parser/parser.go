@@ -11,8 +11,18 @@ import (
 
 type Parser struct {
 	curToken  *token.Token
+	prevToken *token.Token // 直前に読んだ字句
+	inFunction int // 読んでいる関数の本体の入れ子の深さ
 
 	Errors []string
+	positions []Error // Errors と同じ順に、誤りに気付いた位置を持つ
+}
+
+// 構文の誤り。位置は誤りに気付いた時に読んでいた字句の位置
+type Error struct {
+	Message string
+	Line    int
+	Column  int
 }
 
 func newParser(head *token.Token) *Parser {
@@ -21,13 +31,23 @@ func newParser(head *token.Token) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.curToken.Next
 }
 
+// 今の字句が直前の字句より後の行にあるか
+func (p *Parser) onNewLine() bool {
+	return p.prevToken != nil && p.curToken != nil && p.curToken.Line > p.prevToken.Line
+}
+
 func (p *Parser) curTokenIs(tokenKind token.TokenKind) bool {
 	return p.curToken != nil && p.curToken.Kind == tokenKind
 }
 
+func (p *Parser) peekTokenIs(tokenKind token.TokenKind) bool {
+	return p.curToken != nil && p.curToken.Next != nil && p.curToken.Next.Kind == tokenKind
+}
+
 func (p *Parser) consume(tokenKind token.TokenKind) bool {
 	if p.curTokenIs(token.INTEGER) ||
 		p.curTokenIs(token.EOF) ||
@@ -48,8 +68,29 @@ func (p *Parser) expect(tokenKind token.TokenKind) bool {
 	return false
 }
 
+// 節点の位置がまだ決まっていなければ、字句の位置にする
+func mark(node *ast.Node, tok *token.Token) *ast.Node {
+	if node != nil && node.Line == 0 && tok != nil {
+		node.Line, node.Column = tok.Line, tok.Column
+	}
+	return node
+}
+
+// 文の並びに置いた文に、始めの字句の位置を付ける
+func markStart(node *ast.Node, tok *token.Token) *ast.Node {
+	if node != nil && tok != nil {
+		node.StartLine, node.StartColumn = tok.Line, tok.Column
+	}
+	return node
+}
+
 func (p *Parser) appendError(format string, arg ...interface{}) {
-	p.Errors = append(p.Errors, fmt.Sprintf(format, arg...))
+	err := Error{Message: fmt.Sprintf(format, arg...)}
+	if p.curToken != nil {
+		err.Line, err.Column = p.curToken.Line, p.curToken.Column
+	}
+	p.Errors = append(p.Errors, err.Message)
+	p.positions = append(p.positions, err)
 }
 
 func (p *Parser) program() *ast.Node {
@@ -60,16 +101,34 @@ func (p *Parser) program() *ast.Node {
 		}
 		funcNode := ast.NewNode(ast.FUNC)
 		funcNode.Ident = p.curToken.Literal
+		// 関数の位置は名前の位置にする
+		mark(funcNode, p.curToken)
 		p.nextToken()
 
 		if !p.expect(token.LPAREN) {
 			p.appendError("括弧が必要です。")
 			return nil
 		}
-		for p.curTokenIs(token.IDENT) {
-			ident := ast.NewIdentNode(p.curToken.Literal)
-			funcNode.Params = append(funcNode.Params, ident)
-			p.nextToken()
+		hasDefault := false
+		for p.curTokenIs(token.IDENT) || p.curTokenIs(token.ELLIPSIS) {
+			if len(funcNode.Params) > 0 && funcNode.Params[len(funcNode.Params)-1].NodeKind == ast.REST {
+				p.appendError("残りの引数は最後に置いてください。")
+				return nil
+			}
+
+			param := p.param()
+			if param == nil {
+				return nil
+			}
+			if param.NodeKind == ast.IDENT {
+				if param.Rhs != nil {
+					hasDefault = true
+				} else if hasDefault {
+					p.appendError("既定値のない引数は既定値のある引数より前に置いてください。")
+					return nil
+				}
+			}
+			funcNode.Params = append(funcNode.Params, param)
 			p.consume(token.COMMA)
 		}
 		if !p.expect(token.RPAREN) {
@@ -77,14 +136,46 @@ func (p *Parser) program() *ast.Node {
 			return nil
 		}
 
+		p.inFunction++
 		funcNode.Body = p.stmt()
+		p.inFunction--
 		return funcNode
 	}
 
 	return p.stmt()
 }
 
-func (p *Parser) stmt() *ast.Node {
+// 仮引数を読む。助詞はParticleに、既定値はRhsに格納し、残りの引数はREST節点にする
+func (p *Parser) param() *ast.Node {
+	if p.consume(token.ELLIPSIS) {
+		if !p.curTokenIs(token.IDENT) {
+			p.appendError("\"…\"の後には識別子が必要です。")
+			return nil
+		}
+		node := ast.NewNodeBinop(ast.REST, mark(ast.NewIdentNode(p.curToken.Literal), p.curToken), nil)
+		p.nextToken()
+		return node
+	}
+
+	node := mark(ast.NewIdentNode(p.curToken.Literal), p.curToken)
+	p.nextToken()
+	if p.curTokenIs(token.PARTICLE) {
+		node.Particle = p.curToken.Literal
+		p.nextToken()
+	}
+	if p.consume(token.ASSIGN) {
+		node.Rhs = p.ternary()
+		if node.Rhs == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func (p *Parser) stmt() (res *ast.Node) {
+	start := p.curToken
+	defer func() { mark(res, start) }()
+
 	if p.consume(token.IF) {
 		node := ast.NewNode(ast.IF)
 		node.Condition = p.expr()
@@ -109,12 +200,38 @@ func (p *Parser) stmt() *ast.Node {
 				p.appendError("括弧を閉じてください。")
 				return nil
 			}
-			node.Stmts = append(node.Stmts, p.stmt())
+			start := p.curToken
+			node.Stmts = append(node.Stmts, markStart(p.stmt(), start))
 		}
 		return node
 	}
 
-	node := p.expr()
+	if p.consume(token.IMPORT) {
+		if !p.curTokenIs(token.STRING) {
+			p.appendError("\"読み込む\"の後にはモジュール名の文字列が必要です。")
+			return nil
+		}
+		node := ast.NewNode(ast.IMPORT)
+		node.Str = p.curToken.Literal
+		p.nextToken()
+		return node
+	}
+
+	if p.consume(token.RETURN) {
+		node := p.tuple()
+		if node == nil {
+			return nil
+		}
+		return p.returnNode(node)
+	}
+
+	node := p.multiAssign()
+
+	if p.consume(token.INCREMENT) {
+		return ast.NewNodeBinop(ast.ADD_ASSIGN, node, ast.NewIntegerNode(1))
+	} else if p.consume(token.DECREMENT) {
+		return ast.NewNodeBinop(ast.SUB_ASSIGN, node, ast.NewIntegerNode(1))
+	}
 
 	if p.consume(token.THEN) && p.consume(token.FOR) {
 		fNode := ast.NewNode(ast.FOR)
@@ -124,26 +241,144 @@ func (p *Parser) stmt() *ast.Node {
 	}
 
 	if p.consume(token.RETURN) {
-		node = ast.NewNodeBinop(ast.RETURN, node, nil)
+		node = p.returnNode(node)
 	}
 
 	return node
 }
 
+func (p *Parser) returnNode(value *ast.Node) *ast.Node {
+	node := ast.NewNodeBinop(ast.RETURN, value, nil)
+	node.InFunction = p.inFunction > 0
+	return node
+}
+
 func (p *Parser) expr() *ast.Node {
 	return p.assign()
 }
 
 func (p *Parser) assign() *ast.Node {
-	node := p.equality()
+	return p.assignTo(p.sov(), p.assign)
+}
+
+// 文として書かれた代入。左辺と右辺に「、」で区切った複数の式を書ける
+func (p *Parser) multiAssign() *ast.Node {
+	return p.assignTo(p.tuple(), p.multiAssign)
+}
+
+// lhsの後に代入演算子が続けば代入の節点を作る。右辺はrhsで読む
+func (p *Parser) assignTo(lhs *ast.Node, rhs func() *ast.Node) *ast.Node {
+	node := lhs
 
 	if p.consume(token.ASSIGN) {
-		node = ast.NewNodeBinop(ast.ASSIGN, node, p.assign())
+		node = ast.NewNodeBinop(ast.ASSIGN, node, rhs())
+	} else if p.consume(token.PLUS_ASSIGN) {
+		node = ast.NewNodeBinop(ast.ADD_ASSIGN, node, rhs())
+	} else if p.consume(token.MINUS_ASSIGN) {
+		node = ast.NewNodeBinop(ast.SUB_ASSIGN, node, rhs())
+	} else if p.consume(token.ASTERISK_ASSIGN) {
+		node = ast.NewNodeBinop(ast.MUL_ASSIGN, node, rhs())
+	} else if p.consume(token.SLASH_ASSIGN) {
+		node = ast.NewNodeBinop(ast.DIV_ASSIGN, node, rhs())
 	}
 
 	return node
 }
 
+func (p *Parser) tuple() *ast.Node {
+	node := p.element()
+	if node == nil || !p.curTokenIs(token.COMMA) {
+		return node
+	}
+
+	tNode := ast.NewNode(ast.TUPLE)
+	tNode.Params = append(tNode.Params, node)
+	for p.consume(token.COMMA) {
+		elem := p.element()
+		if elem == nil {
+			return nil
+		}
+		tNode.Params = append(tNode.Params, elem)
+	}
+	return tNode
+}
+
+// 配列や複数の値の要素。分割代入の左辺では…残りを書ける
+func (p *Parser) element() *ast.Node {
+	if p.consume(token.ELLIPSIS) {
+		target := p.postfix()
+		if target == nil {
+			return nil
+		}
+		return ast.NewNodeBinop(ast.REST, target, nil)
+	}
+	return p.sov()
+}
+
+// 引数に助詞を付け、最後に動詞(関数名)を置く呼び出し。例: x に 1 を 足す
+// 「x を 5 増やす」もここで読む
+func (p *Parser) sov() *ast.Node {
+	node := p.ternary()
+	if node == nil || !p.curTokenIs(token.PARTICLE) {
+		return node
+	}
+
+	call := ast.NewNode(ast.CALL)
+	for p.curTokenIs(token.PARTICLE) {
+		arg := ast.NewNodeBinop(ast.PARTICLE, node, nil)
+		arg.Ident = p.curToken.Literal
+		call.Params = append(call.Params, arg)
+		p.nextToken()
+
+		node = p.ternary()
+		if node == nil {
+			return nil
+		}
+	}
+
+	if len(call.Params) == 1 && call.Params[0].Ident == "を" {
+		if p.consume(token.INCREMENT) {
+			return ast.NewNodeBinop(ast.ADD_ASSIGN, call.Params[0].Lhs, node)
+		} else if p.consume(token.DECREMENT) {
+			return ast.NewNodeBinop(ast.SUB_ASSIGN, call.Params[0].Lhs, node)
+		}
+	}
+
+	if node.NodeKind != ast.IDENT && node.NodeKind != ast.MEMBER {
+		p.appendError("助詞の後には動詞(関数名)が必要です。")
+		return nil
+	}
+	call.Lhs = node.Lhs
+	call.Ident = node.Ident
+	// 呼び出しの位置は動詞の位置にする
+	call.Line, call.Column = node.Line, node.Column
+	return call
+}
+
+func (p *Parser) ternary() *ast.Node {
+	node := p.equality()
+	if node == nil || !p.consume(token.TERNARY_THEN) {
+		return node
+	}
+
+	tNode := ast.NewNode(ast.TERNARY)
+	tNode.Condition = node
+	tNode.Then = p.ternary()
+	if tNode.Then == nil {
+		return nil
+	}
+
+	if !p.expect(token.TERNARY_ELSE) {
+		p.appendError("\"でなければ\"が必要です。")
+		return nil
+	}
+	tNode.Else = p.ternary()
+	if tNode.Else == nil {
+		return nil
+	}
+	return tNode
+}
+
 func (p *Parser) equality() *ast.Node {
 	node := p.relational()
 
@@ -206,14 +441,147 @@ func (p *Parser) mul() *ast.Node {
 
 func (p *Parser) unary() *ast.Node {
 	if p.consume(token.PLUS) {
-		return ast.NewNodeBinop(ast.ADD, ast.NewIntegerNode(0), p.primary())
+		return ast.NewNodeBinop(ast.ADD, ast.NewIntegerNode(0), p.postfix())
 	} else if p.consume(token.MINUS) {
-		return ast.NewNodeBinop(ast.SUB, ast.NewIntegerNode(0), p.primary())
+		return ast.NewNodeBinop(ast.SUB, ast.NewIntegerNode(0), p.postfix())
+	}
+	return p.postfix()
+}
+
+func (p *Parser) postfix() *ast.Node {
+	node := p.primary()
+
+	for node != nil {
+		// 行を改めた "[" は添字ではなく、次の文の始まり
+		if !p.onNewLine() && p.consume(token.LBRACKET) {
+			index := p.expr()
+			if index == nil {
+				return nil
+			}
+
+			if !p.expect(token.RBRACKET) {
+				p.appendError("括弧を閉じてください。")
+				return nil
+			}
+			node = ast.NewNodeBinop(ast.INDEX, node, index)
+		} else if p.consume(token.DOT) {
+			if !p.curTokenIs(token.IDENT) {
+				p.appendError("\".\"の後には識別子が必要です。")
+				return nil
+			}
+			nameToken := p.curToken
+			p.nextToken()
+
+			if p.consume(token.LPAREN) {
+				call := ast.NewNodeBinop(ast.CALL, node, nil)
+				call.Ident = nameToken.Literal
+				call.Line, call.Column = nameToken.Line, nameToken.Column
+				node = p.callArgs(call)
+			} else {
+				node = ast.NewNodeBinop(ast.MEMBER, node, nil)
+				node.Ident = nameToken.Literal
+				node.Line, node.Column = nameToken.Line, nameToken.Column
+			}
+		} else {
+			return node
+		}
+	}
+
+	return node
+}
+
+// 関数呼び出しの引数を読む。開き括弧は読み終えているものとする
+func (p *Parser) callArgs(node *ast.Node) *ast.Node {
+	named := false
+	for !p.curTokenIs(token.RPAREN) && !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			name := mark(ast.NewIdentNode(p.curToken.Literal), p.curToken)
+			p.nextToken()
+			p.nextToken()
+			value := p.expr()
+			if value == nil {
+				return nil
+			}
+			node.Params = append(node.Params, ast.NewNodeBinop(ast.PAIR, name, value))
+			named = true
+		} else if named {
+			p.appendError("名前付き引数の後に位置で指定する引数は置けません。")
+			return nil
+		} else {
+			node.Params = append(node.Params, p.expr()) 
+		}
+		p.consume(token.COMMA)
+	}
+
+	if !p.expect(token.RPAREN) {
+		p.appendError("括弧を閉じてください。")
+		return nil
+	}
+
+	return node
+}
+
+func (p *Parser) array() *ast.Node {
+	node := ast.NewNode(ast.ARRAY)
+
+	for !p.curTokenIs(token.RBRACKET) && !p.curTokenIs(token.EOF) {
+		var elem *ast.Node
+		if p.curTokenIs(token.ELLIPSIS) {
+			elem = p.element()
+		} else {
+			elem = p.expr()
+		}
+		if elem == nil {
+			return nil
+		}
+		node.Params = append(node.Params, elem)
+		if !p.consume(token.COMMA) && !p.curTokenIs(token.RBRACKET) {
+			p.appendError("要素の間には\"、\"が必要です。")
+			return nil
+		}
 	}
-	return p.primary()
+
+	if !p.expect(token.RBRACKET) {
+		p.appendError("括弧を閉じてください。")
+		return nil
+	}
+	return node
+}
+
+func (p *Parser) hash() *ast.Node {
+	node := ast.NewNode(ast.HASH)
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		key := p.expr()
+		if key == nil {
+			return nil
+		}
+		if !p.expect(token.COLON) {
+			p.appendError("\":\"が必要です。")
+			return nil
+		}
+		value := p.expr()
+		if value == nil {
+			return nil
+		}
+		node.Params = append(node.Params, ast.NewNodeBinop(ast.PAIR, key, value))
+		if !p.consume(token.COMMA) && !p.curTokenIs(token.RBRACE) {
+			p.appendError("要素の間には\"、\"が必要です。")
+			return nil
+		}
+	}
+
+	if !p.expect(token.RBRACE) {
+		p.appendError("括弧を閉じてください。")
+		return nil
+	}
+	return node
 }
 
-func (p *Parser) primary() *ast.Node {
+func (p *Parser) primary() (res *ast.Node) {
+	start := p.curToken
+	defer func() { mark(res, start) }()
+
 	if p.consume(token.LPAREN) {
 		if p.consume(token.RPAREN) {
 			p.appendError("式が必要です。")
@@ -232,6 +600,32 @@ func (p *Parser) primary() *ast.Node {
 		return node
 	}
 
+	if p.curTokenIs(token.FLOAT) {
+		str := utils.ToHalfWidth(p.curToken.Literal)
+		num, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			p.appendError("小数ではありません。 取得した文字=%s", str)
+			p.nextToken()
+			return nil
+		}
+		p.nextToken()
+		return ast.NewFloatNode(num)
+	}
+
+	if p.curTokenIs(token.STRING) {
+		node := ast.NewStringNode(p.curToken.Literal)
+		p.nextToken()
+		return node
+	}
+
+	if p.consume(token.LBRACKET) {
+		return p.array()
+	}
+
+	if p.consume(token.LBRACE) {
+		return p.hash()
+	}
+
 	if p.curTokenIs(token.IDENT) {
 		identifier := p.curToken.Literal
 		p.nextToken()
@@ -239,18 +633,7 @@ func (p *Parser) primary() *ast.Node {
 		if p.consume(token.LPAREN) {
 			node := ast.NewNode(ast.CALL)
 			node.Ident = identifier
-
-			for !p.curTokenIs(token.RPAREN) && !p.curTokenIs(token.EOF) {
-				node.Params = append(node.Params, p.expr()) 
-				p.consume(token.COMMA)
-			}
-
-			if !p.expect(token.RPAREN) {
-				p.appendError("括弧を閉じてください。")
-				return nil
-			}
-
-			return node
+			return p.callArgs(node)
 		}
 
 		node := ast.NewIdentNode(identifier)
@@ -278,15 +661,27 @@ func (p *Parser) primary() *ast.Node {
 }
 	
 func Parse(head *token.Token) (*ast.Program, []string) {
-		p := newParser(head)
+	program, p := parse(head)
+	return program, p.Errors
+}
+
+// Parse と同じように読み、誤りの位置も返す
+func ParseWithPositions(head *token.Token) (*ast.Program, []Error) {
+	program, p := parse(head)
+	return program, p.positions
+}
+
+func parse(head *token.Token) (*ast.Program, *Parser) {
+	p := newParser(head)
 	program := ast.NewProgram()
 
 	for !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		node := p.program()
 		if node != nil {
-			program.Nodes = append(program.Nodes, node)
+			program.Nodes = append(program.Nodes, markStart(node, start))
 		}
 	}
 
-	return program, p.Errors
+	return program, p
 }
@@ -293,4 +293,421 @@ func TestFuncCall(t *testing.T) {
 	if node.Params[1].Ident != "日本" {
 		t.Fatalf("second arg : got=%s expect=%s\n", node.Params[1].Ident, "日本")
 	}
-}
\ No newline at end of file
+}
+
+func TestCompoundAssign(t *testing.T) {
+	tests := []struct {
+		input string
+		nodeKind ast.NodeKind
+		lhs ast.NodeKind
+		rhs int
+	} {
+		{"a += 5", ast.ADD_ASSIGN, ast.IDENT, 5},
+		{"a ＋＝ 5", ast.ADD_ASSIGN, ast.IDENT, 5},
+		{"a -= 5", ast.SUB_ASSIGN, ast.IDENT, 5},
+		{"a *= 5", ast.MUL_ASSIGN, ast.IDENT, 5},
+		{"a ÷＝ 5", ast.DIV_ASSIGN, ast.IDENT, 5},
+		{"a[0] += 5", ast.ADD_ASSIGN, ast.INDEX, 5},
+		{"a 増やす", ast.ADD_ASSIGN, ast.IDENT, 1},
+		{"a 減らす", ast.SUB_ASSIGN, ast.IDENT, 1},
+		{"a を 5 増やす", ast.ADD_ASSIGN, ast.IDENT, 5},
+		{"a[1] を 5 減らす", ast.SUB_ASSIGN, ast.INDEX, 5},
+	}
+
+	for i, v := range tests {
+		head := token.Tokenize(v.input)
+		program, errors := Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("test%d : %v\n", i, errors)
+		}
+
+		for _, node := range program.Nodes {
+			if node.NodeKind != v.nodeKind {
+				t.Fatalf("test%d(kind) : got=%d expect=%d\n", i, node.NodeKind, v.nodeKind)
+			}
+			if node.Lhs == nil || node.Lhs.NodeKind != v.lhs {
+				t.Fatalf("test%d(lhs) : got=%d expect=%d\n", i, node.Lhs.NodeKind, v.lhs)
+			}
+			if node.Rhs == nil || node.Rhs.Num != v.rhs {
+				t.Fatalf("test%d(rhs) : got=%d expect=%d\n", i, node.Rhs.Num, v.rhs)
+			}
+		}
+	}
+}
+
+func TestArrayAndHash(t *testing.T) {
+	input := `
+	[1, 2、3]
+	a = ｛1: 2、3: 4}
+	a[1]
+	`
+	head := token.Tokenize(input)
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	array := program.Nodes[0]
+	if array.NodeKind != ast.ARRAY {
+		t.Fatalf("kind : got=%d expect=%d\n", array.NodeKind, ast.ARRAY)
+	}
+	if len(array.Params) != 3 {
+		t.Fatalf("elements length : got=%d expect=%d\n", len(array.Params), 3)
+	}
+
+	hash := program.Nodes[1].Rhs
+	if hash.NodeKind != ast.HASH {
+		t.Fatalf("kind : got=%d expect=%d\n", hash.NodeKind, ast.HASH)
+	}
+	if len(hash.Params) != 2 || hash.Params[1].Lhs.Num != 3 || hash.Params[1].Rhs.Num != 4 {
+		t.Fatalf("pairs : got=%v\n", hash.Params)
+	}
+
+	index := program.Nodes[2]
+	if index.NodeKind != ast.INDEX {
+		t.Fatalf("kind : got=%d expect=%d\n", index.NodeKind, ast.INDEX)
+	}
+	if index.Lhs.Ident != "a" || index.Rhs.Num != 1 {
+		t.Fatalf("index : got=%s[%d]\n", index.Lhs.Ident, index.Rhs.Num)
+	}
+}
+
+func TestTernaryExpression(t *testing.T) {
+	input := "a = x > 0 なら 1 でなければ y == 0 なら 0 でなければ -1 + 2"
+	head := token.Tokenize(input)
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	node := program.Nodes[0]
+	if node.NodeKind != ast.ASSIGN {
+		t.Fatalf("kind : got=%d expect=%d\n", node.NodeKind, ast.ASSIGN)
+	}
+
+	ternary := node.Rhs
+	if ternary.NodeKind != ast.TERNARY {
+		t.Fatalf("kind : got=%d expect=%d\n", ternary.NodeKind, ast.TERNARY)
+	}
+	if ternary.Condition.NodeKind != ast.GT {
+		t.Fatalf("condition : got=%d expect=%d\n", ternary.Condition.NodeKind, ast.GT)
+	}
+	if ternary.Then.NodeKind != ast.INTEGER {
+		t.Fatalf("then : got=%d expect=%d\n", ternary.Then.NodeKind, ast.INTEGER)
+	}
+	if ternary.Else.NodeKind != ast.TERNARY {
+		t.Fatalf("else : got=%d expect=%d\n", ternary.Else.NodeKind, ast.TERNARY)
+	}
+	if ternary.Else.Else.NodeKind != ast.ADD {
+		t.Fatalf("nested else : got=%d expect=%d\n", ternary.Else.Else.NodeKind, ast.ADD)
+	}
+}
+
+func TestTernaryWithoutElse(t *testing.T) {
+	head := token.Tokenize("x > 0 なら 1")
+	_, errors := Parse(head)
+	if len(errors) == 0 {
+		t.Fatalf("expected an error\n")
+	}
+}
+
+func TestFuncDeclationWithDefaultAndRest(t *testing.T) {
+	input := `
+	関数 f(a、b=10、…残り) {
+		a 戻す
+	}`
+	head := token.Tokenize(input)
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	node := program.Nodes[0]
+	if len(node.Params) != 3 {
+		t.Fatalf("params length : got=%d expect=%d\n", len(node.Params), 3)
+	}
+	if node.Params[0].Ident != "a" || node.Params[0].Rhs != nil {
+		t.Fatalf("first param : got=%s\n", node.Params[0])
+	}
+	if node.Params[1].Ident != "b" || node.Params[1].Rhs == nil || node.Params[1].Rhs.Num != 10 {
+		t.Fatalf("second param : got=%s\n", node.Params[1])
+	}
+	if node.Params[2].NodeKind != ast.REST || node.Params[2].Lhs.Ident != "残り" {
+		t.Fatalf("third param : got=%s\n", node.Params[2])
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	tests := []string{
+		"関数 f(a=1、b) { a 戻す }",
+		"関数 f(…a、b) { a 戻す }",
+		"関数 f(…) { 1 戻す }",
+	}
+
+	for i, v := range tests {
+		head := token.Tokenize(v)
+		_, errors := Parse(head)
+		if len(errors) == 0 {
+			t.Fatalf("test%d : expected an error\n", i)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	head := token.Tokenize("f(1、b: 3、c：4)")
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	node := program.Nodes[0]
+	if len(node.Params) != 3 {
+		t.Fatalf("args length : got=%d expect=%d\n", len(node.Params), 3)
+	}
+	if node.Params[0].NodeKind != ast.INTEGER {
+		t.Fatalf("first arg : got=%d expect=%d\n", node.Params[0].NodeKind, ast.INTEGER)
+	}
+	if node.Params[1].NodeKind != ast.PAIR || node.Params[1].Lhs.Ident != "b" || node.Params[1].Rhs.Num != 3 {
+		t.Fatalf("second arg : got=%s\n", node.Params[1])
+	}
+
+	head = token.Tokenize("f(b: 3、1)")
+	if _, errors := Parse(head); len(errors) == 0 {
+		t.Fatalf("expected an error for positional argument after named argument\n")
+	}
+}
+
+func TestMultipleAssign(t *testing.T) {
+	tests := []struct {
+		input string
+		lhs ast.NodeKind
+		rhs ast.NodeKind
+	} {
+		{"x、y = f()", ast.TUPLE, ast.CALL},
+		{"x, y = y, x", ast.TUPLE, ast.TUPLE},
+		{"x = 1、2", ast.IDENT, ast.TUPLE},
+		{"[先頭、…残り] = 配列", ast.ARRAY, ast.IDENT},
+		{"先頭、...残り = 配列", ast.TUPLE, ast.IDENT},
+	}
+
+	for i, v := range tests {
+		head := token.Tokenize(v.input)
+		program, errors := Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("test%d : %v\n", i, errors)
+		}
+		if len(program.Nodes) != 1 {
+			t.Fatalf("test%d(length) : got=%d expect=%d\n", i, len(program.Nodes), 1)
+		}
+
+		node := program.Nodes[0]
+		if node.NodeKind != ast.ASSIGN {
+			t.Fatalf("test%d(kind) : got=%d expect=%d\n", i, node.NodeKind, ast.ASSIGN)
+		}
+		if node.Lhs.NodeKind != v.lhs {
+			t.Fatalf("test%d(lhs) : got=%d expect=%d\n", i, node.Lhs.NodeKind, v.lhs)
+		}
+		if node.Rhs.NodeKind != v.rhs {
+			t.Fatalf("test%d(rhs) : got=%d expect=%d\n", i, node.Rhs.NodeKind, v.rhs)
+		}
+	}
+}
+
+func TestReturnMultipleValues(t *testing.T) {
+	tests := []string{
+		"a、b 戻す",
+		"戻す a、b",
+	}
+
+	for i, v := range tests {
+		head := token.Tokenize(v)
+		program, errors := Parse(head)
+		if len(errors) > 0 {
+			t.Fatalf("test%d : %v\n", i, errors)
+		}
+
+		node := program.Nodes[0]
+		if node.NodeKind != ast.RETURN {
+			t.Fatalf("test%d(kind) : got=%d expect=%d\n", i, node.NodeKind, ast.RETURN)
+		}
+		if node.Lhs.NodeKind != ast.TUPLE || len(node.Lhs.Params) != 2 {
+			t.Fatalf("test%d(lhs) : got=%s\n", i, node.Lhs)
+		}
+	}
+}
+
+func TestParticleCall(t *testing.T) {
+	input := `
+	関数 足す(数 に、値 を=1) {
+		数 + 値 戻す
+	}
+	x に 1 を 足す
+	メッセージ を 表示する
+	`
+	head := token.Tokenize(input)
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+	if len(program.Nodes) != 3 {
+		t.Fatalf("length : got=%d expect=%d\n", len(program.Nodes), 3)
+	}
+
+	fn := program.Nodes[0]
+	if fn.Params[0].Particle != "に" || fn.Params[1].Particle != "を" || fn.Params[1].Rhs == nil {
+		t.Fatalf("params : got=%s, %s\n", fn.Params[0], fn.Params[1])
+	}
+
+	call := program.Nodes[1]
+	if call.NodeKind != ast.CALL || call.Ident != "足す" {
+		t.Fatalf("call : got=%s\n", call)
+	}
+	if len(call.Params) != 2 {
+		t.Fatalf("args length : got=%d expect=%d\n", len(call.Params), 2)
+	}
+	if call.Params[0].NodeKind != ast.PARTICLE || call.Params[0].Ident != "に" || call.Params[0].Lhs.Ident != "x" {
+		t.Fatalf("first arg : got=%s\n", call.Params[0])
+	}
+	if call.Params[1].Ident != "を" || call.Params[1].Lhs.Num != 1 {
+		t.Fatalf("second arg : got=%s\n", call.Params[1])
+	}
+
+	if call := program.Nodes[2]; call.Ident != "表示する" || len(call.Params) != 1 {
+		t.Fatalf("call : got=%s\n", call)
+	}
+}
+
+func TestParticleCallWithoutVerb(t *testing.T) {
+	head := token.Tokenize("x に 1 を 2")
+	if _, errors := Parse(head); len(errors) == 0 {
+		t.Fatalf("expected an error\n")
+	}
+}
+
+func TestImportAndMember(t *testing.T) {
+	input := `
+	読み込む "./util.jpl"
+	util.値
+	util.倍(2)
+	x を util.表示する
+	`
+	head := token.Tokenize(input)
+	program, errors := Parse(head)
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	if node := program.Nodes[0]; node.NodeKind != ast.IMPORT || node.Str != "./util.jpl" {
+		t.Fatalf("import : got=%s\n", node)
+	}
+	if node := program.Nodes[1]; node.NodeKind != ast.MEMBER || node.Lhs.Ident != "util" || node.Ident != "値" {
+		t.Fatalf("member : got=%s\n", node)
+	}
+	if node := program.Nodes[2]; node.NodeKind != ast.CALL || node.Lhs.Ident != "util" || node.Ident != "倍" || len(node.Params) != 1 {
+		t.Fatalf("call : got=%s\n", node)
+	}
+	if node := program.Nodes[3]; node.NodeKind != ast.CALL || node.Lhs.Ident != "util" || node.Ident != "表示する" {
+		t.Fatalf("particle call : got=%s\n", node)
+	}
+}
+
+func TestImportWithoutName(t *testing.T) {
+	head := token.Tokenize("読み込む 数学")
+	if _, errors := Parse(head); len(errors) == 0 {
+		t.Fatalf("expected an error\n")
+	}
+}
+
+func TestNodePosition(t *testing.T) {
+	input := `x = 1
+関数 倍(n) {
+	n * 2 戻す
+}
+もし x < 2 ならば {
+	x を 表示する
+	m.値
+}`
+	program, errors := Parse(token.Tokenize(input))
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+
+	fn := program.Nodes[1]
+	ifNode := program.Nodes[2]
+	tests := []struct {
+		node   *ast.Node
+		line   int
+		column int
+	}{
+		{program.Nodes[0], 1, 1},
+		{program.Nodes[0].Rhs, 1, 5},
+		{fn, 2, 4},
+		{fn.Params[0], 2, 6},
+		{fn.Body, 2, 9},
+		{fn.Body.Stmts[0], 3, 2},
+		{fn.Body.Stmts[0].Lhs.Rhs, 3, 6},
+		{ifNode, 5, 1},
+		{ifNode.Condition, 5, 4},
+		{ifNode.Then.Stmts[0], 6, 6},
+		{ifNode.Then.Stmts[0].Params[0], 6, 2},
+		{ifNode.Then.Stmts[1], 7, 4},
+	}
+
+	for i, v := range tests {
+		if v.node.Line != v.line || v.node.Column != v.column {
+			t.Fatalf("test%d : got=%d:%d expect=%d:%d\n", i, v.node.Line, v.node.Column, v.line, v.column)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"x = (1 + 2", "括弧を閉じてください。", 1, 11},
+		{"x = 1\n関数 (a) {}", "\"関数\"キーワードの後には識別子が必要です。", 2, 4},
+		{"f(a: 1、2)", "名前付き引数の後に位置で指定する引数は置けません。", 1, 8},
+		{"x = [1 2 3]", "要素の間には\"、\"が必要です。", 1, 8},
+		{"x = {\"a\": 1 \"b\": 2}", "要素の間には\"、\"が必要です。", 1, 13},
+	}
+
+	for i, v := range tests {
+		_, errors := ParseWithPositions(token.Tokenize(v.input))
+		if len(errors) == 0 {
+			t.Fatalf("test%d : expected an error\n", i)
+		}
+		err := errors[0]
+		if err.Message != v.message || err.Line != v.line || err.Column != v.column {
+			t.Fatalf("test%d : got=%s %d:%d expect=%s %d:%d\n", i, err.Message, err.Line, err.Column, v.message, v.line, v.column)
+		}
+	}
+}
+
+func TestStatementStart(t *testing.T) {
+	input := `関数 f(a) {
+	もし a ならば {}
+	戻す a
+}
+(1) を 表示する`
+	program, _ := ParseWithPositions(token.Tokenize(input))
+	fn := program.Nodes[0]
+	tests := []struct {
+		node   *ast.Node
+		line   int
+		column int
+	}{
+		{fn, 1, 1},
+		{fn.Body.Stmts[0], 2, 2},
+		{fn.Body.Stmts[1], 3, 2},
+		{program.Nodes[1], 5, 1},
+	}
+
+	for i, v := range tests {
+		if v.node.StartLine != v.line || v.node.StartColumn != v.column {
+			t.Fatalf("test%d : got=%d:%d expect=%d:%d\n", i, v.node.StartLine, v.node.StartColumn, v.line, v.column)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 
 	"jpl/token"
 	"jpl/parser"
@@ -23,6 +25,9 @@ func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
 	env := object.NewEnvironment()
+	e := evaluator.New()
+	e.SearchPath = filepath.SplitList(os.Getenv("JPL_PATH"))
+	e.Out = out
 
 	for {
 		fmt.Print(PROMPT)
@@ -40,7 +45,7 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 		for _, v := range program.Nodes {
-			o := evaluator.Eval(v, env)
+			o := e.Eval(v, env)
 			if o.Type() != object.NULL {
 				fmt.Println(o.Inspect())
 			}
@@ -0,0 +1,425 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"jpl/ast"
+)
+
+type DiagnosticKind int
+
+const (
+	Undeclared DiagnosticKind = iota // 宣言されていない変数や関数を使っている
+	Unused                           // 代入した変数を使っていない
+)
+
+// 実行する前に見つかった問題
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Name    string
+	Message string
+	Node    *ast.Node // 名前を書いた節
+}
+
+type SymbolKind int
+
+const (
+	VariableSymbol SymbolKind = iota
+	FunctionSymbol
+	ParameterSymbol
+	ModuleSymbol
+)
+
+// 宣言した名前と、その名前を書いた場所。エディタの支援に使う
+type Symbol struct {
+	Name  string
+	Kind  SymbolKind
+	Decl  *ast.Node   // 最初に宣言した節。モジュールは読み込む文
+	Refs  []*ast.Node // 名前を書いた節。宣言も含む。呼び出しは名前に「する」が付いていることがある
+	Scope *Scope
+}
+
+// 名前の範囲
+type Scope struct {
+	Outer   *Scope
+	Node    *ast.Node // 範囲を作ったブロックか関数。一番外側では nil
+	Symbols []*Symbol // 宣言した順
+}
+
+// Analyze の結果
+type Analysis struct {
+	Global      *Scope
+	Scopes      []*Scope // 範囲を現れた順に並べたもの。最初は Global
+	Diagnostics []Diagnostic
+}
+
+type symbol struct {
+	name string
+	slot int  // 環境の中の位置。-1 なら名前で探す
+	hard bool // 仮引数や読み込んだモジュールのように、外側に同じ名前があってもこの環境に定義されるもの
+	used bool
+	info *Symbol
+}
+
+// 実行時の環境に対応する範囲。プログラム全体、ブロック、関数の呼び出しごとに一つずつある
+type scope struct {
+	outer   *scope
+	global  bool
+	names   map[string]*symbol
+	symbols []*symbol // 宣言した順
+	size    int
+	info    *Scope
+}
+
+func (r *resolver) newScope(outer *scope, node *ast.Node) *scope {
+	s := &scope{outer: outer, names: map[string]*symbol{}, info: &Scope{Node: node}}
+	if outer != nil {
+		s.info.Outer = outer.info
+	}
+	r.scopes = append(r.scopes, s.info)
+	return s
+}
+
+// 名前を宣言する。初めて宣言した時は種類と宣言した節を覚える
+func (s *scope) declareAt(name string, hard bool, kind SymbolKind, node *ast.Node) *symbol {
+	sym := s.declare(name, hard)
+	if sym.info.Decl == nil {
+		sym.info.Kind, sym.info.Decl = kind, node
+	}
+	return sym
+}
+
+func (s *scope) declare(name string, hard bool) *symbol {
+	if sym, ok := s.names[name]; ok {
+		sym.hard = sym.hard || hard
+		return sym
+	}
+	sym := &symbol{name: name, slot: -1, hard: hard, info: &Symbol{Name: name, Scope: s.info}}
+	s.info.Symbols = append(s.info.Symbols, sym.info)
+	// 一番外側の変数はモジュールの要素や組み込む側からも名前で参照されるので、位置は割り当てない
+	if !s.global {
+		sym.slot = s.size
+		s.size++
+	}
+	s.names[name] = sym
+	s.symbols = append(s.symbols, sym)
+	return sym
+}
+
+// 位置を割り当てた変数の名前を位置の順に返す。デバッガが変数を表示する時に使う
+func (s *scope) slotNames() []string {
+	names := make([]string, s.size)
+	for _, sym := range s.symbols {
+		if sym.slot >= 0 {
+			names[sym.slot] = sym.name
+		}
+	}
+	return names
+}
+
+// 読み込んだモジュールは名前で定義されるので、位置は割り当てない
+func (s *scope) declareByName(name string, node *ast.Node) {
+	sym := s.declareAt(name, true, ModuleSymbol, node)
+	sym.slot = -1
+}
+
+// 名前を書いた節を覚える
+func refer(sym *symbol, node *ast.Node) {
+	sym.info.Refs = append(sym.info.Refs, node)
+}
+
+type resolver struct {
+	known       func(string) bool
+	diagnostics []Diagnostic
+	scopes      []*Scope
+}
+
+// 識別子を変数の位置に結び付け、問題を報告する。構文木はその場で書き換える。
+// known には組み込み関数や実行前に定義済みの変数のように、プログラムの外で定義された名前かどうかを返す関数を渡す。
+//
+// 代入は実行時に外側の環境から同じ名前の変数を探すので、外側の範囲のどこかで代入される変数は、
+// 内側で先に代入されても外側の変数として扱う。
+// 一番外側の変数は名前で探すので、実行する順番で結果が変わることはない
+func Resolve(program *ast.Program, known func(string) bool) []Diagnostic {
+	return Analyze(program, known).Diagnostics
+}
+
+// Resolve と同じように解決し、宣言した名前とその名前を書いた場所も返す
+func Analyze(program *ast.Program, known func(string) bool) *Analysis {
+	r := &resolver{known: known}
+	global := r.newScope(nil, nil)
+	global.global = true
+	r.stmts(global, program.Nodes)
+	return &Analysis{Global: global.info, Scopes: r.scopes, Diagnostics: r.diagnostics}
+}
+
+func (r *resolver) report(kind DiagnosticKind, name string, format string, node *ast.Node) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{Kind: kind, Name: name, Message: fmt.Sprintf(format, name), Node: node})
+}
+
+// 範囲の中で代入される名前を先に宣言してから、文を解決する
+func (r *resolver) stmts(s *scope, nodes []*ast.Node) {
+	for _, v := range nodes {
+		r.declare(s, v)
+	}
+	for _, v := range nodes {
+		r.node(s, v)
+	}
+	r.reportUnused(s)
+}
+
+func (r *resolver) reportUnused(s *scope) {
+	if s.global {
+		return
+	}
+	for _, sym := range s.symbols {
+		if !sym.used && !sym.hard && !strings.HasPrefix(sym.name, "_") {
+			r.report(Unused, sym.name, "変数「%s」は使われていません。", sym.info.Decl)
+		}
+	}
+}
+
+// この範囲で代入される名前を宣言する。ブロックと関数の本体は別の範囲なので中には入らない
+func (r *resolver) declare(s *scope, node *ast.Node) {
+	if node == nil {
+		return
+	}
+
+	switch node.NodeKind {
+	case ast.ASSIGN:
+		r.declareTarget(s, node.Lhs)
+	case ast.FUNC:
+		// 関数は使われなくても報告しない
+		s.declareAt(node.Ident, false, FunctionSymbol, node).used = true
+	case ast.IMPORT:
+		s.declareByName(moduleName(node.Str), node)
+	case ast.IF, ast.FOR, ast.TERNARY:
+		for _, v := range []*ast.Node{node.Then, node.Else} {
+			if v != nil && v.NodeKind != ast.BLOCK {
+				r.declare(s, v)
+			}
+		}
+	}
+}
+
+func (r *resolver) declareTarget(s *scope, target *ast.Node) {
+	if target == nil {
+		return
+	}
+
+	switch target.NodeKind {
+	case ast.IDENT:
+		// 外側の変数への代入なら、この範囲には宣言しない
+		if sym, _ := lookUp(s, target.Ident); sym != nil && sym.info.Scope != s.info {
+			return
+		}
+		s.declareAt(target.Ident, false, VariableSymbol, target)
+	case ast.REST:
+		r.declareTarget(s, target.Lhs)
+	case ast.TUPLE, ast.ARRAY:
+		for _, v := range target.Params {
+			r.declareTarget(s, v)
+		}
+	}
+}
+
+// モジュールを読み込んだ時に定義される名前。ファイル名から拡張子を除いたもの
+func moduleName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// 名前を探す。外側から同じ名前に代入される変数は、一番外側のものを使う。
+// 仮引数のようにその環境に必ず定義されるものがあれば、それより外側は探さない
+func lookUp(s *scope, name string) (*symbol, int) {
+	var found *symbol
+	depth := 0
+	for cur, d := s, 0; cur != nil; cur, d = cur.outer, d+1 {
+		sym, ok := cur.names[name]
+		if !ok {
+			continue
+		}
+		found, depth = sym, d
+		if sym.hard {
+			break
+		}
+	}
+	return found, depth
+}
+
+func bind(node *ast.Node, sym *symbol, depth int) {
+	node.Binding = nil
+	if sym != nil && sym.slot >= 0 {
+		node.Binding = &ast.Binding{Depth: depth, Slot: sym.slot}
+	}
+}
+
+// 変数を読む
+func (r *resolver) use(s *scope, node *ast.Node) {
+	sym, depth := lookUp(s, node.Ident)
+	if sym == nil {
+		node.Binding = nil
+		if !r.known(node.Ident) {
+			r.report(Undeclared, node.Ident, "変数「%s」が宣言されていません。", node)
+		}
+		return
+	}
+	sym.used = true
+	refer(sym, node)
+	bind(node, sym, depth)
+}
+
+// 代入先を解決する
+func (r *resolver) target(s *scope, target *ast.Node) {
+	if target == nil {
+		return
+	}
+
+	switch target.NodeKind {
+	case ast.IDENT:
+		sym, depth := lookUp(s, target.Ident)
+		if sym != nil {
+			refer(sym, target)
+		}
+		bind(target, sym, depth)
+	case ast.REST:
+		r.target(s, target.Lhs)
+	case ast.TUPLE, ast.ARRAY:
+		for _, v := range target.Params {
+			r.target(s, v)
+		}
+	default:
+		r.node(s, target)
+	}
+}
+
+// 関数を探す。見つからなければ「する」を除いた名前でも探す
+func (r *resolver) call(s *scope, node *ast.Node) {
+	node.Binding = nil
+	if node.Lhs != nil {
+		r.node(s, node.Lhs)
+	} else {
+		names := []string{node.Ident}
+		if stem := strings.TrimSuffix(node.Ident, "する"); stem != node.Ident && stem != "" {
+			names = append(names, stem)
+		}
+		found := false
+		for _, name := range names {
+			if sym, depth := lookUp(s, name); sym != nil {
+				sym.used = true
+				refer(sym, node)
+				bind(node, sym, depth)
+				found = true
+				break
+			}
+			if r.known(name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.report(Undeclared, node.Ident, "関数「%s」が宣言されていません。", node)
+		}
+	}
+
+	for _, v := range node.Params {
+		if v == nil {
+			continue
+		}
+		switch v.NodeKind {
+		case ast.PAIR:
+			r.node(s, v.Rhs)
+		case ast.PARTICLE:
+			r.node(s, v.Lhs)
+		default:
+			r.node(s, v)
+		}
+	}
+}
+
+// 仮引数を先頭から順に位置に割り当てる。既定値はそれより前の仮引数を参照できる。
+// 書きかけの関数では仮引数や本体が欠けていることがある
+func (r *resolver) function(s *scope, node *ast.Node) {
+	fs := r.newScope(s, node)
+	for _, param := range node.Params {
+		if param == nil {
+			continue
+		}
+		ident := param
+		if param.NodeKind == ast.REST {
+			ident = param.Lhs
+		}
+		if param.Rhs != nil {
+			r.node(fs, param.Rhs)
+		}
+		if ident == nil {
+			continue
+		}
+		sym := fs.declareAt(ident.Ident, true, ParameterSymbol, ident)
+		refer(sym, ident)
+		bind(ident, sym, 0)
+	}
+
+	if node.Body == nil {
+		r.reportUnused(fs)
+		return
+	}
+	// 本体のブロックは呼び出しごとの環境で実行する
+	r.stmts(fs, node.Body.Stmts)
+	node.Body.Locals = fs.size
+	node.Body.Names = fs.slotNames()
+}
+
+func (r *resolver) node(s *scope, node *ast.Node) {
+	if node == nil {
+		return
+	}
+
+	switch node.NodeKind {
+	case ast.INTEGER, ast.FLOAT, ast.STRING, ast.IMPORT:
+		return
+	case ast.IDENT:
+		r.use(s, node)
+	case ast.ASSIGN:
+		r.node(s, node.Rhs)
+		r.target(s, node.Lhs)
+	case ast.ADD_ASSIGN, ast.SUB_ASSIGN, ast.MUL_ASSIGN, ast.DIV_ASSIGN:
+		if node.Lhs != nil && node.Lhs.NodeKind == ast.IDENT {
+			r.use(s, node.Lhs)
+		} else {
+			r.node(s, node.Lhs)
+		}
+		r.node(s, node.Rhs)
+	case ast.BLOCK:
+		bs := r.newScope(s, node)
+		r.stmts(bs, node.Stmts)
+		node.Locals = bs.size
+		node.Names = bs.slotNames()
+	case ast.FUNC:
+		sym, depth := lookUp(s, node.Ident)
+		if sym != nil {
+			refer(sym, node)
+		}
+		bind(node, sym, depth)
+		r.function(s, node)
+	case ast.CALL:
+		r.call(s, node)
+	case ast.MEMBER:
+		r.node(s, node.Lhs)
+	default:
+		r.node(s, node.Lhs)
+		r.node(s, node.Rhs)
+		r.node(s, node.Condition)
+		r.node(s, node.Then)
+		r.node(s, node.Else)
+		for _, v := range node.Params {
+			r.node(s, v)
+		}
+	}
+}
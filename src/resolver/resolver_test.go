@@ -0,0 +1,206 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"jpl/ast"
+	"jpl/parser"
+	"jpl/token"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	program, errors := parser.Parse(token.Tokenize(input))
+	if len(errors) > 0 {
+		t.Fatalf("%v\n", errors)
+	}
+	return program
+}
+
+func isBuiltin(name string) bool {
+	return name == "表示"
+}
+
+// 関数の本体から名前の識別子を探す
+func findIdent(node *ast.Node, name string) *ast.Node {
+	if node == nil {
+		return nil
+	}
+	if node.NodeKind == ast.IDENT && node.Ident == name {
+		return node
+	}
+	children := []*ast.Node{node.Lhs, node.Rhs, node.Condition, node.Then, node.Else, node.Body}
+	children = append(children, node.Params...)
+	children = append(children, node.Stmts...)
+	for _, v := range children {
+		if found := findIdent(v, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestResolveBindings(t *testing.T) {
+	tests := []struct {
+		input  string
+		name   string
+		expect string // 「深さ,位置」か、名前で探すなら「名前」
+	}{
+		{"a = 1 a", "a", "名前"},
+		{"関数 f(x) { x }", "x", "0,0"},
+		{"関数 f(x、y) { y }", "y", "0,1"},
+		{"関数 f(x) { 合計 = x 合計 }", "合計", "0,1"},
+		{"関数 f(x) { { x } }", "x", "1,0"},
+		{"関数 f(x) { { y = x y } }", "y", "0,0"},
+		{"関数 f() { 合計 = 1 } 合計 = 0", "合計", "名前"},
+		{"関数 f(x) { { x = 2 } }", "x", "1,0"},
+		{"関数 f(…残り) { 残り }", "残り", "0,0"},
+		{"関数 f(x) { 表示(x) }", "x", "0,0"},
+		{"関数 f() { 読み込む \"数学\" 数学 }", "数学", "名前"},
+	}
+
+	for i, v := range tests {
+		program := parse(t, v.input)
+		Resolve(program, isBuiltin)
+
+		var ident *ast.Node
+		for _, node := range program.Nodes {
+			// 関数の本体の中で最後に使われている識別子を調べる
+			if node.NodeKind == ast.FUNC {
+				stmts := node.Body.Stmts
+				ident = findIdent(stmts[len(stmts)-1], v.name)
+			}
+		}
+		if ident == nil {
+			ident = findIdent(program.Nodes[len(program.Nodes)-1], v.name)
+		}
+		if ident == nil {
+			t.Fatalf("test%d : %sが見つかりません。\n", i, v.name)
+		}
+
+		got := "名前"
+		if ident.Binding != nil {
+			got = fmt.Sprintf("%d,%d", ident.Binding.Depth, ident.Binding.Slot)
+		}
+		if got != v.expect {
+			t.Fatalf("test%d : got=%s expect=%s\n", i, got, v.expect)
+		}
+	}
+}
+
+func TestResolveLocals(t *testing.T) {
+	program := parse(t, "関数 f(a、b) { c = a { d = b d } c }")
+	Resolve(program, isBuiltin)
+
+	body := program.Nodes[0].Body
+	if body.Locals != 3 {
+		t.Fatalf("function locals : got=%d expect=%d\n", body.Locals, 3)
+	}
+	if block := body.Stmts[1]; block.Locals != 1 {
+		t.Fatalf("block locals : got=%d expect=%d\n", block.Locals, 1)
+	}
+	if names := strings.Join(body.Names, ","); names != "a,b,c" {
+		t.Fatalf("function names : got=%s expect=%s\n", names, "a,b,c")
+	}
+	if names := strings.Join(body.Stmts[1].Names, ","); names != "d" {
+		t.Fatalf("block names : got=%s expect=%s\n", names, "d")
+	}
+
+	// 外側の変数に代入するだけのブロックは位置を使わない
+	program = parse(t, "関数 f() { y = 1 { y = 2 } y }")
+	Resolve(program, isBuiltin)
+	if block := program.Nodes[0].Body.Stmts[1]; block.Locals != 0 {
+		t.Fatalf("outer assignment locals : got=%d expect=%d\n", block.Locals, 0)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"a = 1 表示(a)", []string{}},
+		{"表示(b)", []string{"変数「b」が宣言されていません。"}},
+		{"b += 1", []string{"変数「b」が宣言されていません。"}},
+		{"未定義(1)", []string{"関数「未定義」が宣言されていません。"}},
+		{"表示する(1)", []string{}},
+		{"関数 f() { g() } 関数 g() { 1 }", []string{}},
+		{"関数 f() { 合計 } 合計 = 1", []string{}},
+		{"関数 f(x) { 一時 = x 1 }", []string{"変数「一時」は使われていません。"}},
+		{"関数 f(x) { _一時 = x 1 }", []string{}},
+		{"関数 f(使わない) { 1 }", []string{}},
+		{"未使用 = 1", []string{}},
+		{"{ 中 = 1 }", []string{"変数「中」は使われていません。"}},
+		{"読み込む \"数学\" 数学.円周率", []string{}},
+		{"読み込む \"./lib/便利.jpl\" 便利.x", []string{}},
+		{"f = 表示 f(1)", []string{}},
+		{"関数 g() { y = 1 { y = 2 } y 戻す }", []string{}},
+		{"関数 f(n) { 合計 = 0 i = 0 i < n ならば 繰り返す { 合計 = 合計 + i i += 1 } 合計 }", []string{}},
+		{"x = 0 { x = 1 }", []string{}},
+		{"関数 f(x) { { x = 1 } x }", []string{}},
+	}
+
+	for i, v := range tests {
+		diagnostics := Resolve(parse(t, v.input), isBuiltin)
+		got := []string{}
+		for _, d := range diagnostics {
+			got = append(got, d.Message)
+		}
+		if strings.Join(got, " ") != strings.Join(v.expect, " ") {
+			t.Fatalf("test%d : got=%v expect=%v\n", i, got, v.expect)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	input := `関数 倍(n) {
+	m = n * 2
+	m 戻す
+}
+x = 倍(1)
+x を 倍する
+{ 未使用 = x }`
+	analysis := Analyze(parse(t, input), isBuiltin)
+
+	symbols := map[string]*Symbol{}
+	for _, scope := range analysis.Scopes {
+		for _, sym := range scope.Symbols {
+			symbols[sym.Name] = sym
+		}
+	}
+	tests := []struct {
+		name  string
+		kind  SymbolKind
+		refs  string // 名前を書いた位置
+		scope int    // 範囲の深さ
+	}{
+		{"倍", FunctionSymbol, "1:4 5:5 6:5", 0},
+		{"n", ParameterSymbol, "1:6 2:6", 1},
+		{"m", VariableSymbol, "2:2 3:2", 1},
+		{"x", VariableSymbol, "5:1 6:1 7:9", 0},
+		{"未使用", VariableSymbol, "7:3", 1},
+	}
+
+	for i, v := range tests {
+		sym, ok := symbols[v.name]
+		if !ok {
+			t.Fatalf("test%d : %sがありません。\n", i, v.name)
+		}
+		refs := []string{}
+		for _, ref := range sym.Refs {
+			refs = append(refs, fmt.Sprintf("%d:%d", ref.Line, ref.Column))
+		}
+		depth := 0
+		for scope := sym.Scope; scope.Outer != nil; scope = scope.Outer {
+			depth++
+		}
+		if sym.Kind != v.kind || strings.Join(refs, " ") != v.refs || depth != v.scope || sym.Decl == nil {
+			t.Fatalf("test%d : got=%d %v %d expect=%d %s %d\n", i, sym.Kind, refs, depth, v.kind, v.refs, v.scope)
+		}
+	}
+
+	if len(analysis.Diagnostics) != 1 || analysis.Diagnostics[0].Node.Line != 7 || analysis.Diagnostics[0].Node.Column != 3 {
+		t.Fatalf("diagnostics : %+v\n", analysis.Diagnostics)
+	}
+}
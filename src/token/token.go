@@ -1,9 +1,13 @@
 package token
 
+import "sort"
+
 type TokenKind int
 
 const (
 	INTEGER TokenKind = iota
+	STRING // "文字列", ＂文字列＂
+	FLOAT // 3.14, ３．１４
 
 	IDENT //識別子
 
@@ -12,6 +16,10 @@ const (
 	SLASH    // /,／,÷
 	ASTERISK // *,＊,×
 	ASSIGN // =
+	PLUS_ASSIGN // +=, ＋＝
+	MINUS_ASSIGN // -=, ー＝
+	ASTERISK_ASSIGN // *=, ＊＝, ×＝
+	SLASH_ASSIGN // /=, ／＝, ÷＝
 
 	GT // <, ＜
 	LT // >, ＞
@@ -25,18 +33,30 @@ const (
 	RPAREN // ),）
 	LBRACE // {, ｛
 	RBRACE // }, ｝
+	LBRACKET // [, ［
+	RBRACKET // ], ］
 
 	COMMA //, 、
+	COLON // :, ：
+	ELLIPSIS // ..., …
+	DOT // ., ．
 
 	RETURN
 	IF
 	ELSE
 	THEN
 	FOR
+	TERNARY_THEN // なら
+	TERNARY_ELSE // でなければ
 	FUNC
+	INCREMENT // 増やす
+	DECREMENT // 減らす
+	PARTICLE // を, に, で, から, と
+	IMPORT // 読み込む
 
 	EOF
 	ILLEGAL
+	COMMENT // 注釈。TokenizeWithComments だけが作る
 )
 
 var keywords = map[string]TokenKind{
@@ -45,13 +65,25 @@ var keywords = map[string]TokenKind{
 	"それ以外" : ELSE,
 	"ならば" : THEN,
 	"繰り返す" : FOR,
+	"なら" : TERNARY_THEN,
+	"でなければ" : TERNARY_ELSE,
 	"関数" : FUNC,
+	"増やす" : INCREMENT,
+	"減らす" : DECREMENT,
+	"を" : PARTICLE,
+	"に" : PARTICLE,
+	"で" : PARTICLE,
+	"から" : PARTICLE,
+	"と" : PARTICLE,
+	"読み込む" : IMPORT,
 }
 
 type Token struct {
 	Kind    TokenKind
 	Next    *Token
 	Literal string
+	Line    int // 1から数える
+	Column  int // 1から数える。文字(rune)単位
 }
 
 func newToken(kind TokenKind, cur *Token, literal string) *Token {
@@ -60,9 +92,14 @@ func newToken(kind TokenKind, cur *Token, literal string) *Token {
 	return token
 }
 
-func newIntegerToken(cur *Token, literal string) *Token {
-	token := newToken(INTEGER, cur, literal)
-	return token
+// 予約語を返す。エディタの補完に使う
+func Keywords() []string {
+	words := []string{}
+	for k := range keywords {
+		words = append(words, k)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func lookUpIdent(key string) TokenKind {
@@ -10,10 +10,13 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           rune
+	line         int // ch の行
+	column       int // ch の列
+	comments     []*Token
 }
 
 func newLexer(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+	l := &Lexer{input: []rune(input), line: 1}
 	l.readChar()
 	return l
 }
@@ -26,6 +29,14 @@ func (l *Lexer) peekChar() rune {
 	}
 }
 
+func (l *Lexer) peekSecondChar() rune {
+	if l.readPosition+1 >= len(l.input) {
+		return 0
+	} else {
+		return l.input[l.readPosition+1]
+	}
+}
+
 func (l *Lexer) skipSpecialChar() {
 	for l.ch == '\n' || l.ch == '\t' || l.ch == ' ' || l.ch == '　' {
 		l.readChar()
@@ -36,20 +47,18 @@ func isNum(ch rune) bool {
 	return ('0' <= ch && ch <= '9') || ('０' <= ch && ch <= '９')
 }
 
+// 文字ごとにコンパイルしないよう、あらかじめコンパイルしておく
+var (
+	hiraganaRegexp = regexp.MustCompile("[\u3041-\u3096]")
+	katakanaRegexp = regexp.MustCompile("[\u30a1-\u30fc]")
+)
+
 func isHiragana(ch rune) bool {
-	match, err := regexp.MatchString("[\u3041-\u3096]", string(ch))
-	if err != nil {
-		panic(err)
-	}
-	return match
+	return hiraganaRegexp.MatchString(string(ch))
 }
 
 func isKatakana(ch rune) bool {
-	match, err := regexp.MatchString("[\u30a1-\u30fc]", string(ch))
-	if err != nil {
-		panic(err)
-	}
-	return match
+	return katakanaRegexp.MatchString(string(ch))
 }
 
 func isKanji(ch rune) bool {
@@ -65,6 +74,11 @@ func isAlphabet(ch rune) bool {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -82,45 +96,186 @@ func (l *Lexer) readNum() string {
 	return string(l.input[position:l.position])
 }
 
+// 数を読む。小数点の後に数字が続けば小数として読む
+func (l *Lexer) readNumber() (TokenKind, string) {
+	position := l.position
+	l.readNum()
+	if (l.ch == '.' || l.ch == '．') && isNum(l.peekChar()) {
+		l.readChar()
+		l.readNum()
+		return FLOAT, string(l.input[position:l.position])
+	}
+	return INTEGER, string(l.input[position:l.position])
+}
+
+func isIdentStart(ch rune) bool {
+	return isAlphabet(ch) || isJapanese(ch) || ch == '_' || ch == '＿'
+}
+
+// 長いものから照合する
+var particles = [][]rune{[]rune("から"), []rune("を"), []rune("に"), []rune("で"), []rune("と")}
+
+// i から始まる、前後の語に付けて書いた助詞の長さ。助詞でなければ0。
+// 「メッセージを表示する」のように、前後がひらがなでない時だけ助詞として切り離す。
+// 「表示する」の送り仮名のように、ひらがなの続く中にある文字は助詞と見なさない
+func (l *Lexer) particleAt(i int) int {
+	if i > 0 && isHiragana(l.input[i-1]) {
+		return 0
+	}
+	for _, p := range particles {
+		end := i + len(p)
+		if end > len(l.input) || string(l.input[i:end]) != string(p) {
+			continue
+		}
+		if end < len(l.input) && isHiragana(l.input[end]) {
+			return 0
+		}
+		return len(p)
+	}
+	return 0
+}
+
 func (l *Lexer) readString() string {
 	position := l.position
-	if !isAlphabet(l.ch) && !isJapanese(l.ch) {
+	if !isIdentStart(l.ch) {
 		return ""
 	}
+	if n := l.particleAt(position); n > 0 {
+		for i := 0; i < n; i++ {
+			l.readChar()
+		}
+		return string(l.input[position:l.position])
+	}
 	l.readChar()
 
 	for isAlphabet(l.ch) || isJapanese(l.ch) || isNum(l.ch) ||
 		l.ch == '_' || l.ch == '＿' {
+		if l.particleAt(l.position) > 0 {
+			break
+		}
 		l.readChar()
 	}
 	return string(l.input[position:l.position])
 }
 
+// 「」で囲んだ後に助詞が続けば、「こんにちは」を表示する のように文字列として読む。
+// 囲んだ中身を返し、文字列でなければokはfalseになる。字句は読み進めない
+func (l *Lexer) peekQuoted() (str string, ok bool) {
+	depth := 0
+	for i := l.position; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '「':
+			depth++
+		case '」':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			j := i + 1
+			for j < len(l.input) && (l.input[j] == ' ' || l.input[j] == '\t' || l.input[j] == '　') {
+				j++
+			}
+			if j == len(l.input) || l.particleAt(j) == 0 {
+				return "", false
+			}
+			return string(l.input[l.position+1 : i]), true
+		case '\n':
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// 文字列リテラルを読む。開いた時と同じ引用符で閉じる。閉じる引用符が見つからなければokはfalseになる
+func (l *Lexer) readStringLiteral() (str string, ok bool) {
+	var runes []rune
+	quote := l.ch
+	l.readChar()
+
+	for l.ch != quote {
+		if l.ch == 0 {
+			return string(runes), false
+		}
+		if l.ch == '\\' {
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				runes = append(runes, '\n')
+			case 't':
+				runes = append(runes, '\t')
+			case 0:
+				return string(runes), false
+			default:
+				runes = append(runes, l.ch)
+			}
+		} else {
+			runes = append(runes, l.ch)
+		}
+		l.readChar()
+	}
+	return string(runes), true
+}
+
+func (l *Lexer) addComment(text string, line, column int) {
+	tok := &Token{Kind: COMMENT, Literal: text, Line: line, Column: column}
+	l.comments = append(l.comments, tok)
+}
+
 func Tokenize(input string) *Token {
+	return newLexer(input).tokenize()
+}
+
+// Tokenize と同じように字句に分け、読み飛ばした注釈も現れた順に返す。
+// 注釈は字句の列にはつながない
+func TokenizeWithComments(input string) (*Token, []*Token) {
 	l := newLexer(input)
+	head := l.tokenize()
+	return head, l.comments
+}
 
+func (l *Lexer) tokenize() *Token {
 	head := &Token{}
 	cur := head
 
 	for l.ch != 0 {
 		l.skipSpecialChar()
 
+		// 字句の位置は読み始めた文字の位置にする
+		prev, line, column := cur, l.line, l.column
 		switch l.ch {
 		case '+', '＋':
-			cur = newToken(PLUS, cur, string(l.ch))
+			if ch := l.peekChar(); ch == '=' || ch == '＝' {
+				cur = newToken(PLUS_ASSIGN, cur, string([]rune{l.ch, ch}))
+				l.readChar()
+			} else {
+				cur = newToken(PLUS, cur, string(l.ch))
+			}
 		case '-', 'ー':
-			cur = newToken(MINUS, cur, string(l.ch))
+			if ch := l.peekChar(); ch == '=' || ch == '＝' {
+				cur = newToken(MINUS_ASSIGN, cur, string([]rune{l.ch, ch}))
+				l.readChar()
+			} else {
+				cur = newToken(MINUS, cur, string(l.ch))
+			}
 		case '*', '＊', '×':
-			cur = newToken(ASTERISK, cur, string(l.ch))
+			if ch := l.peekChar(); ch == '=' || ch == '＝' {
+				cur = newToken(ASTERISK_ASSIGN, cur, string([]rune{l.ch, ch}))
+				l.readChar()
+			} else {
+				cur = newToken(ASTERISK, cur, string(l.ch))
+			}
 		case '/', '／':
 			if ch := l.peekChar(); ch == '/' || ch == '／' {
+				position := l.position
 				for l.ch != '\n' {
 					if l.ch == 0 {
 						break
 					}
 					l.readChar()
 				}
+				l.addComment(string(l.input[position:l.position]), line, column)
 			} else if ch := l.peekChar(); ch == '*' || ch == '＊' {
+				position := l.position
 				for !(l.ch == '*' || l.ch == '＊') || !(l.peekChar() == '/' || l.peekChar() == '／') {
 					if l.ch == 0 {
 						break
@@ -128,12 +283,35 @@ func Tokenize(input string) *Token {
 					l.readChar()
 				}
 				l.readChar()
+				end := l.position + 1
+				if end > len(l.input) {
+					end = len(l.input)
+				}
+				l.addComment(string(l.input[position:end]), line, column)
+			} else if ch := l.peekChar(); ch == '=' || ch == '＝' {
+				cur = newToken(SLASH_ASSIGN, cur, string([]rune{l.ch, ch}))
+				l.readChar()
 			} else {
 				cur = newToken(SLASH, cur, string(l.ch))
 			}
 		case '÷':
-			cur = newToken(SLASH, cur, string(l.ch))
-		case '(', '（', '「':
+			if ch := l.peekChar(); ch == '=' || ch == '＝' {
+				cur = newToken(SLASH_ASSIGN, cur, string([]rune{l.ch, ch}))
+				l.readChar()
+			} else {
+				cur = newToken(SLASH, cur, string(l.ch))
+			}
+		case '「':
+			if str, ok := l.peekQuoted(); ok {
+				cur = newToken(STRING, cur, str)
+				// 閉じる「」」まで読み進める
+				for i := 0; i <= len([]rune(str)); i++ {
+					l.readChar()
+				}
+			} else {
+				cur = newToken(LPAREN, cur, string(l.ch))
+			}
+		case '(', '（':
 			cur = newToken(LPAREN, cur, string(l.ch))
 		case ')', '）', '」':
 			cur = newToken(RPAREN, cur, string(l.ch))
@@ -141,6 +319,10 @@ func Tokenize(input string) *Token {
 			cur = newToken(LBRACE, cur, string(l.ch))
 		case '}', '｝':
 			cur = newToken(RBRACE, cur, string(l.ch))
+		case '[', '［':
+			cur = newToken(LBRACKET, cur, string(l.ch))
+		case ']', '］':
+			cur = newToken(RBRACKET, cur, string(l.ch))
 		case '<', '＜':
 			if ch := l.peekChar(); ch == '=' || ch == '＝' {
 				cur = newToken(GE, cur, string([]rune{l.ch, ch}))
@@ -171,24 +353,51 @@ func Tokenize(input string) *Token {
 			}
 		case ',', '、':
 			cur = newToken(COMMA, cur, string(l.ch))
+		case ':', '：':
+			cur = newToken(COLON, cur, string(l.ch))
+		case '…':
+			cur = newToken(ELLIPSIS, cur, string(l.ch))
+		case '.':
+			if l.peekChar() == '.' && l.peekSecondChar() == '.' {
+				cur = newToken(ELLIPSIS, cur, "...")
+				l.readChar()
+				l.readChar()
+			} else {
+				cur = newToken(DOT, cur, string(l.ch))
+			}
+		case '．':
+			cur = newToken(DOT, cur, string(l.ch))
+		case '"', '＂':
+			if str, ok := l.readStringLiteral(); ok {
+				cur = newToken(STRING, cur, str)
+			} else {
+				cur = newToken(ILLEGAL, cur, str)
+			}
 		case 0:
 			cur = newToken(EOF, cur, "")
 		default:
 			if isNum(l.ch) {
-				cur = newIntegerToken(cur, l.readNum())
+				kind, num := l.readNumber()
+				cur = newToken(kind, cur, num)
+				cur.Line, cur.Column = line, column
 				continue
-			} else if isJapanese(l.ch) || isAlphabet(l.ch) {
+			} else if isIdentStart(l.ch) {
 				str := l.readString()
 				kind := lookUpIdent(str)
 				cur = newToken(kind, cur, str)
+				cur.Line, cur.Column = line, column
 				continue
 			} else {
 				cur = newToken(ILLEGAL, cur, string(l.ch))
 			}
 		}
+		if cur != prev {
+			cur.Line, cur.Column = line, column
+		}
 		l.readChar()
 	}
 
 	cur = newToken(EOF, cur, "")
+	cur.Line, cur.Column = l.line, l.column
 	return head.Next
 }
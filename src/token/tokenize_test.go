@@ -1,6 +1,7 @@
 package token
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -41,6 +42,40 @@ func tokenKindToString(kind TokenKind) string {
 		res = "IDENT"
 	case COMMA:
 		res = "COMMA"
+	case PLUS_ASSIGN:
+		res = "PLUS_ASSIGN"
+	case MINUS_ASSIGN:
+		res = "MINUS_ASSIGN"
+	case ASTERISK_ASSIGN:
+		res = "ASTERISK_ASSIGN"
+	case SLASH_ASSIGN:
+		res = "SLASH_ASSIGN"
+	case LBRACKET:
+		res = "LBRACKET"
+	case RBRACKET:
+		res = "RBRACKET"
+	case COLON:
+		res = "COLON"
+	case INCREMENT:
+		res = "INCREMENT"
+	case DECREMENT:
+		res = "DECREMENT"
+	case PARTICLE:
+		res = "PARTICLE"
+	case TERNARY_THEN:
+		res = "TERNARY_THEN"
+	case TERNARY_ELSE:
+		res = "TERNARY_ELSE"
+	case ELLIPSIS:
+		res = "ELLIPSIS"
+	case STRING:
+		res = "STRING"
+	case FLOAT:
+		res = "FLOAT"
+	case DOT:
+		res = "DOT"
+	case IMPORT:
+		res = "IMPORT"
 	default:
 		res = "ILLEGAL"
 	}
@@ -128,3 +163,352 @@ func TestToken(t *testing.T) {
 		token = token.Next
 	}
 }
+
+func TestCompoundAssignToken(t *testing.T) {
+	input := `
+	+= ＋＝ -= ー＝ *= ＊＝ ×＝ /= ／＝ ÷＝ [] ［］ : ： 増やす 減らす を ... …
+	`
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{PLUS_ASSIGN, "+="},
+		{PLUS_ASSIGN, "＋＝"},
+		{MINUS_ASSIGN, "-="},
+		{MINUS_ASSIGN, "ー＝"},
+		{ASTERISK_ASSIGN, "*="},
+		{ASTERISK_ASSIGN, "＊＝"},
+		{ASTERISK_ASSIGN, "×＝"},
+		{SLASH_ASSIGN, "/="},
+		{SLASH_ASSIGN, "／＝"},
+		{SLASH_ASSIGN, "÷＝"},
+		{LBRACKET, "["},
+		{RBRACKET, "]"},
+		{LBRACKET, "［"},
+		{RBRACKET, "］"},
+		{COLON, ":"},
+		{COLON, "："},
+		{INCREMENT, "増やす"},
+		{DECREMENT, "減らす"},
+		{PARTICLE, "を"},
+		{ELLIPSIS, "..."},
+		{ELLIPSIS, "…"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind {
+			t.Fatalf("test%d : got=%s expected=%s\n", i, tokenKindToString(token.Kind), tokenKindToString(v.expectedTokenKind))
+		}
+
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+
+		if token.Next == nil {
+			break
+		}
+		token = token.Next
+	}
+}
+
+func TestTernaryToken(t *testing.T) {
+	input := "x > 0 なら 1 でなければ ならば"
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{IDENT, "x"},
+		{LT, ">"},
+		{INTEGER, "0"},
+		{TERNARY_THEN, "なら"},
+		{INTEGER, "1"},
+		{TERNARY_ELSE, "でなければ"},
+		{THEN, "ならば"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind {
+			t.Fatalf("test%d : got=%s expected=%s\n", i, tokenKindToString(token.Kind), tokenKindToString(v.expectedTokenKind))
+		}
+
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+
+		if token.Next == nil {
+			break
+		}
+		token = token.Next
+	}
+}
+
+func TestParticleToken(t *testing.T) {
+	input := "x に 1 を 足す 3 と 4 で から でなければ なら"
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{IDENT, "x"},
+		{PARTICLE, "に"},
+		{INTEGER, "1"},
+		{PARTICLE, "を"},
+		{IDENT, "足す"},
+		{INTEGER, "3"},
+		{PARTICLE, "と"},
+		{INTEGER, "4"},
+		{PARTICLE, "で"},
+		{PARTICLE, "から"},
+		{TERNARY_ELSE, "でなければ"},
+		{TERNARY_THEN, "なら"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind {
+			t.Fatalf("test%d : got=%s expected=%s\n", i, tokenKindToString(token.Kind), tokenKindToString(v.expectedTokenKind))
+		}
+
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+
+		if token.Next == nil {
+			break
+		}
+		token = token.Next
+	}
+}
+
+func TestAttachedParticleToken(t *testing.T) {
+	input := "「こんにちは」を表示する x に1を足す メッセージから 表示する 「x」 + 「「入れ子」」と でなければ"
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{STRING, "こんにちは"},
+		{PARTICLE, "を"},
+		{IDENT, "表示する"},
+		{IDENT, "x"},
+		{PARTICLE, "に"},
+		{INTEGER, "1"},
+		{PARTICLE, "を"},
+		{IDENT, "足す"},
+		{IDENT, "メッセージ"},
+		{PARTICLE, "から"},
+		{IDENT, "表示する"},
+		{LPAREN, "「"},
+		{IDENT, "x"},
+		{RPAREN, "」"},
+		{PLUS, "+"},
+		{STRING, "「入れ子」"},
+		{PARTICLE, "と"},
+		{TERNARY_ELSE, "でなければ"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind || token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=%s \"%s\" expected=%s \"%s\"\n", i, tokenKindToString(token.Kind), token.Literal, tokenKindToString(v.expectedTokenKind), v.expectedLiteral)
+		}
+		token = token.Next
+	}
+}
+
+func TestStringAndModuleToken(t *testing.T) {
+	input := `読み込む "./util.jpl" util.倍 ＂全角＂ "\"\n" _秘密 ＿秘密 util．値`
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{IMPORT, "読み込む"},
+		{STRING, "./util.jpl"},
+		{IDENT, "util"},
+		{DOT, "."},
+		{IDENT, "倍"},
+		{STRING, "全角"},
+		{STRING, "\"\n"},
+		{IDENT, "_秘密"},
+		{IDENT, "＿秘密"},
+		{IDENT, "util"},
+		{DOT, "．"},
+		{IDENT, "値"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind {
+			t.Fatalf("test%d : got=%s expected=%s\n", i, tokenKindToString(token.Kind), tokenKindToString(v.expectedTokenKind))
+		}
+
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+
+		if token.Next == nil {
+			break
+		}
+		token = token.Next
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	token := Tokenize(`"閉じていない`)
+	if token.Kind != ILLEGAL {
+		t.Fatalf("got=%s expected=%s\n", tokenKindToString(token.Kind), "ILLEGAL")
+	}
+}
+
+// 文字列は開いた時と同じ引用符で閉じる
+func TestMixedQuoteString(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{`"a＂b"`, STRING, "a＂b"},
+		{`＂a"b＂`, STRING, `a"b`},
+		{`"abc＂`, ILLEGAL, "abc＂"},
+		{`＂abc"`, ILLEGAL, `abc"`},
+	}
+
+	for i, v := range tests {
+		token := Tokenize(v.input)
+		if token.Kind != v.expectedTokenKind || token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=%s \"%s\" expected=%s \"%s\"\n", i, tokenKindToString(token.Kind), token.Literal, tokenKindToString(v.expectedTokenKind), v.expectedLiteral)
+		}
+		if token.Next == nil || token.Next.Kind != EOF {
+			t.Fatalf("test%d : 文字列の後に字句が残っています。\n", i)
+		}
+	}
+}
+
+func TestFloatToken(t *testing.T) {
+	input := "3.14 ３．１４ 1. 5"
+
+	tests := []struct {
+		expectedTokenKind TokenKind
+		expectedLiteral   string
+	}{
+		{FLOAT, "3.14"},
+		{FLOAT, "３．１４"},
+		{INTEGER, "1"},
+		{DOT, "."},
+		{INTEGER, "5"},
+		{EOF, ""},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Kind != v.expectedTokenKind {
+			t.Fatalf("test%d : got=%s expected=%s\n", i, tokenKindToString(token.Kind), tokenKindToString(v.expectedTokenKind))
+		}
+
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+
+		if token.Next == nil {
+			break
+		}
+		token = token.Next
+	}
+}
+
+func TestTokenPosition(t *testing.T) {
+	input := "x = 10\n// コメント\n関数 f(値) {\n　　\"文字\" + 値 戻す\n}"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"x", 1, 1},
+		{"=", 1, 3},
+		{"10", 1, 5},
+		{"関数", 3, 1},
+		{"f", 3, 4},
+		{"(", 3, 5},
+		{"値", 3, 6},
+		{")", 3, 7},
+		{"{", 3, 9},
+		{"文字", 4, 3},
+		{"+", 4, 8},
+		{"値", 4, 10},
+		{"戻す", 4, 12},
+		{"}", 5, 1},
+		{"", 5, 2},
+	}
+
+	token := Tokenize(input)
+	for i, v := range tests {
+		if token.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, token.Literal, v.expectedLiteral)
+		}
+		if token.Line != v.expectedLine || token.Column != v.expectedColumn {
+			t.Fatalf("test%d : got=%d:%d expected=%d:%d\n", i, token.Line, token.Column, v.expectedLine, v.expectedColumn)
+		}
+		token = token.Next
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "a = 1 // 一行\n／＊ 複数\n行 ＊／ b = 2\n/* 閉じない"
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"// 一行", 1, 7},
+		{"／＊ 複数\n行 ＊／", 2, 1},
+		{"/* 閉じない", 4, 1},
+	}
+
+	head, comments := TokenizeWithComments(input)
+	if len(comments) != len(tests) {
+		t.Fatalf("got=%d comments expected=%d\n", len(comments), len(tests))
+	}
+	for i, v := range tests {
+		c := comments[i]
+		if c.Kind != COMMENT || c.Literal != v.expectedLiteral {
+			t.Fatalf("test%d : got=\"%s\" expected=\"%s\"\n", i, c.Literal, v.expectedLiteral)
+		}
+		if c.Line != v.expectedLine || c.Column != v.expectedColumn {
+			t.Fatalf("test%d : got=%d:%d expected=%d:%d\n", i, c.Line, c.Column, v.expectedLine, v.expectedColumn)
+		}
+	}
+
+	// 字句の列は Tokenize と同じ
+	literals := []string{}
+	for tok := head; tok != nil; tok = tok.Next {
+		literals = append(literals, tok.Literal)
+	}
+	if got := strings.Join(literals, " "); got != "a = 1 b = 2 " {
+		t.Fatalf("got=\"%s\"\n", got)
+	}
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	input := `
+	関数 階乗(n) {
+		もし n <= 1 ならば { 戻す 1 }
+		戻す n * 階乗(n - 1)
+	}
+	結果 = 階乗(１０)
+	ひらがな = "カタカナ"
+	`
+	for i := 0; i < b.N; i++ {
+		Tokenize(input)
+	}
+}
@@ -20,3 +20,132 @@ var numConv = unicode.SpecialCase{
 func ToLower(str string) string {
 	return strings.ToLowerSpecial(numConv, str)
 }
+
+const widthDelta = 0xff01 - 0x0021
+
+// 半角カナと全角カナの対応。濁点・半濁点付きの文字は半角では二文字になる。
+// 句読点・かぎ括弧・中点は日本語の文で使うものなので、どちらにも変えない
+var halfKana = []string{
+	"ｦ", "ｧ", "ｨ", "ｩ", "ｪ", "ｫ", "ｬ", "ｭ", "ｮ", "ｯ", "ｰ",
+	"ｱ", "ｲ", "ｳ", "ｴ", "ｵ", "ｶ", "ｷ", "ｸ", "ｹ", "ｺ", "ｻ", "ｼ", "ｽ", "ｾ", "ｿ",
+	"ﾀ", "ﾁ", "ﾂ", "ﾃ", "ﾄ", "ﾅ", "ﾆ", "ﾇ", "ﾈ", "ﾉ", "ﾊ", "ﾋ", "ﾌ", "ﾍ", "ﾎ",
+	"ﾏ", "ﾐ", "ﾑ", "ﾒ", "ﾓ", "ﾔ", "ﾕ", "ﾖ", "ﾗ", "ﾘ", "ﾙ", "ﾚ", "ﾛ", "ﾜ", "ﾝ", "ﾞ", "ﾟ",
+	"ｶﾞ", "ｷﾞ", "ｸﾞ", "ｹﾞ", "ｺﾞ", "ｻﾞ", "ｼﾞ", "ｽﾞ", "ｾﾞ", "ｿﾞ",
+	"ﾀﾞ", "ﾁﾞ", "ﾂﾞ", "ﾃﾞ", "ﾄﾞ", "ﾊﾞ", "ﾋﾞ", "ﾌﾞ", "ﾍﾞ", "ﾎﾞ",
+	"ﾊﾟ", "ﾋﾟ", "ﾌﾟ", "ﾍﾟ", "ﾎﾟ", "ｳﾞ",
+}
+
+var fullKana = []string{
+	"ヲ", "ァ", "ィ", "ゥ", "ェ", "ォ", "ャ", "ュ", "ョ", "ッ", "ー",
+	"ア", "イ", "ウ", "エ", "オ", "カ", "キ", "ク", "ケ", "コ", "サ", "シ", "ス", "セ", "ソ",
+	"タ", "チ", "ツ", "テ", "ト", "ナ", "ニ", "ヌ", "ネ", "ノ", "ハ", "ヒ", "フ", "ヘ", "ホ",
+	"マ", "ミ", "ム", "メ", "モ", "ヤ", "ユ", "ヨ", "ラ", "リ", "ル", "レ", "ロ", "ワ", "ン", "゛", "゜",
+	"ガ", "ギ", "グ", "ゲ", "ゴ", "ザ", "ジ", "ズ", "ゼ", "ゾ",
+	"ダ", "ヂ", "ヅ", "デ", "ド", "バ", "ビ", "ブ", "ベ", "ボ",
+	"パ", "ピ", "プ", "ペ", "ポ", "ヴ",
+}
+
+var halfToFullKana, fullToHalfKana *strings.Replacer
+
+func init() {
+	// 濁点付きの二文字を先に照合するため後ろから並べる
+	var toFull, toHalf []string
+	for i := len(halfKana) - 1; i >= 0; i-- {
+		toFull = append(toFull, halfKana[i], fullKana[i])
+		toHalf = append(toHalf, fullKana[i], halfKana[i])
+	}
+	halfToFullKana = strings.NewReplacer(toFull...)
+	fullToHalfKana = strings.NewReplacer(toHalf...)
+}
+
+// 英数字・記号・空白・カタカナを全角にする。「｡｢｣､･」は変えない
+func ToFullWidth(str string) string {
+	str = strings.Map(func(ch rune) rune {
+		switch {
+		case ch == ' ':
+			return '　'
+		case 0x0021 <= ch && ch <= 0x007e:
+			return ch + widthDelta
+		}
+		return ch
+	}, str)
+	return halfToFullKana.Replace(str)
+}
+
+// 英数字・記号・空白・カタカナを半角にする。ToLowerと違い数字以外も変換する。
+// 「。「」、・」は変えない
+func ToHalfWidth(str string) string {
+	str = strings.Map(func(ch rune) rune {
+		switch {
+		case ch == '　':
+			return ' '
+		case 0xff01 <= ch && ch <= 0xff5e:
+			return ch - widthDelta
+		}
+		return ch
+	}, str)
+	return fullToHalfKana.Replace(str)
+}
+
+const kanaDelta = 'ァ' - 'ぁ'
+
+// ひらがなをカタカナにする
+func ToKatakana(str string) string {
+	return strings.Map(func(ch rune) rune {
+		if ('ぁ' <= ch && ch <= 'ゖ') || ch == 'ゝ' || ch == 'ゞ' {
+			return ch + kanaDelta
+		}
+		return ch
+	}, str)
+}
+
+// カタカナをひらがなにする。対応するひらがながない文字(ヷなど)はそのまま
+func ToHiragana(str string) string {
+	return strings.Map(func(ch rune) rune {
+		if ('ァ' <= ch && ch <= 'ヶ') || ch == 'ヽ' || ch == 'ヾ' {
+			return ch - kanaDelta
+		}
+		return ch
+	}, str)
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// 書記素クラスタ(見た目の一文字)に分ける。
+// 結合文字・異体字セレクタ・肌の色・ゼロ幅接合子でつながる絵文字・国旗は一文字として扱う
+func Graphemes(str string) []string {
+	res := []string{}
+	runes := []rune(str)
+
+	for i := 0; i < len(runes); {
+		start := i
+		i++
+		if isRegionalIndicator(runes[start]) && i < len(runes) && isRegionalIndicator(runes[i]) {
+			i++
+		}
+		for i < len(runes) {
+			if runes[i] == zeroWidthJoiner && i+1 < len(runes) {
+				i += 2
+			} else if isExtend(runes[i]) || (runes[i-1] == '\r' && runes[i] == '\n') {
+				i++
+			} else {
+				break
+			}
+		}
+		res = append(res, string(runes[start:i]))
+	}
+	return res
+}
+
+func isRegionalIndicator(ch rune) bool {
+	return 0x1f1e6 <= ch && ch <= 0x1f1ff
+}
+
+func isExtend(ch rune) bool {
+	return unicode.In(ch, unicode.Mn, unicode.Me) ||
+		(0xfe00 <= ch && ch <= 0xfe0f) ||
+		(0xe0100 <= ch && ch <= 0xe01ef) ||
+		(0x1f3fb <= ch && ch <= 0x1f3ff) ||
+		ch == 0xff9e || ch == 0xff9f || // 半角の濁点と半濁点
+		ch == zeroWidthJoiner
+}
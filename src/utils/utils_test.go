@@ -19,3 +19,80 @@ func TestToLower(t *testing.T) {
 		}
 	}
 }
+
+func TestWidthConversion(t *testing.T) {
+	tests := []struct {
+		input string
+		full  string
+		half  string
+	}{
+		{"abc 123", "ａｂｃ　１２３", "abc 123"},
+		{"ＪＰＬ！", "ＪＰＬ！", "JPL!"},
+		{"ｶﾞｷﾞｸﾞ ﾊﾟﾝ", "ガギグ　パン", "ｶﾞｷﾞｸﾞ ﾊﾟﾝ"},
+		{"ヴァイオリン", "ヴァイオリン", "ｳﾞｧｲｵﾘﾝ"},
+		{"ひらがな漢字", "ひらがな漢字", "ひらがな漢字"},
+		{"「ア、イ。ウ・エ」", "「ア、イ。ウ・エ」", "「ｱ、ｲ。ｳ・ｴ」"},
+		{"｢ｱ､ｲ｡｣", "｢ア､イ｡｣", "｢ｱ､ｲ｡｣"},
+	}
+
+	for i, v := range tests {
+		if res := ToFullWidth(v.input); res != v.full {
+			t.Fatalf("test%d : ToFullWidth got=%s expect=%s\n", i, res, v.full)
+		}
+		if res := ToHalfWidth(v.input); res != v.half {
+			t.Fatalf("test%d : ToHalfWidth got=%s expect=%s\n", i, res, v.half)
+		}
+	}
+}
+
+func TestKanaConversion(t *testing.T) {
+	tests := []struct {
+		input    string
+		katakana string
+		hiragana string
+	}{
+		{"ひらがな", "ヒラガナ", "ひらがな"},
+		{"カタカナ", "カタカナ", "かたかな"},
+		{"いすゞとヽ", "イスヾトヽ", "いすゞとゝ"},
+		{"ゔぁ ヴァ ヷ", "ヴァ ヴァ ヷ", "ゔぁ ゔぁ ヷ"},
+		{"漢字abc", "漢字abc", "漢字abc"},
+	}
+
+	for i, v := range tests {
+		if res := ToKatakana(v.input); res != v.katakana {
+			t.Fatalf("test%d : ToKatakana got=%s expect=%s\n", i, res, v.katakana)
+		}
+		if res := ToHiragana(v.input); res != v.hiragana {
+			t.Fatalf("test%d : ToHiragana got=%s expect=%s\n", i, res, v.hiragana)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"日本語", []string{"日", "本", "語"}},
+		{"か\u3099き", []string{"か\u3099", "き"}},
+		{"👍🏽!", []string{"👍🏽", "!"}},
+		{"👨‍👩‍👧", []string{"👨‍👩‍👧"}},
+		{"🇯🇵🇺🇸", []string{"🇯🇵", "🇺🇸"}},
+		{"葛\U000E0100城", []string{"葛\U000E0100", "城"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"ｶﾞｷﾞﾊﾟ", []string{"ｶﾞ", "ｷﾞ", "ﾊﾟ"}},
+		{"", []string{}},
+	}
+
+	for i, v := range tests {
+		res := Graphemes(v.input)
+		if len(res) != len(v.expect) {
+			t.Fatalf("test%d : got=%q expect=%q\n", i, res, v.expect)
+		}
+		for j := range res {
+			if res[j] != v.expect[j] {
+				t.Fatalf("test%d : got=%q expect=%q\n", i, res, v.expect)
+			}
+		}
+	}
+}
@@ -0,0 +1,571 @@
+package vm
+
+import (
+	"fmt"
+
+	"jpl/ast"
+	"jpl/compiler"
+	"jpl/object"
+)
+
+// 評価器と共有する処理。演算や引数の束縛、モジュールの読み込みは評価器と同じ規則で行う
+type Runtime interface {
+	// 変数か組み込み関数を探す
+	LookUp(name string, env *object.Environment) (object.Object, bool)
+	// 呼び出す関数を探す。binding があれば位置で、module が nil でなければモジュールの中から探す。見つからなければエラーを返す
+	Callee(name string, binding *ast.Binding, module object.Object, env *object.Environment) object.Object
+	// 実引数を仮引数に束縛した、呼び出しごとの環境を作る
+	Bind(fn *object.Function, args []object.Object, named map[string]object.Object, particles []Particle) (*object.Environment, object.Object)
+	Infix(op ast.NodeKind, left object.Object, right object.Object) object.Object
+	// 数値だけの演算。複合代入で使う
+	Arithmetic(op ast.NodeKind, left object.Object, right object.Object) object.Object
+	IsTruthy(obj object.Object) bool
+	Index(left object.Object, index object.Object) object.Object
+	SetIndex(left object.Object, index object.Object, val object.Object) object.Object
+	// 分割代入の代入先ごとの値を返す。残りを受け取る代入先には配列を返す
+	Destructure(targets []*ast.Node, val object.Object) ([]object.Object, object.Object)
+	Import(name string, env *object.Environment) object.Object
+	// 繰り返しと関数呼び出しのたびに呼ぶ。上限や期限に達していればエラーを返す
+	Step() object.Object
+	// 作った値を数える。上限を超えたらエラーを、そうでなければ値をそのまま返す
+	Allocate(obj object.Object) object.Object
+	Member(module object.Object, name string) object.Object
+}
+
+// 助詞の付いた引数
+type Particle struct {
+	Particle string
+	Value    object.Object
+}
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// よく使う小さな整数は作り直さずに使い回す
+const (
+	minCachedInt = -128
+	maxCachedInt = 1023
+)
+
+var cachedInts = func() []*object.Integer {
+	ints := make([]*object.Integer, maxCachedInt-minCachedInt+1)
+	for i := range ints {
+		ints[i] = &object.Integer{Value: i + minCachedInt}
+	}
+	return ints
+}()
+
+func newInteger(v int) *object.Integer {
+	if minCachedInt <= v && v <= maxCachedInt {
+		return cachedInts[v-minCachedInt]
+	}
+	return &object.Integer{Value: v}
+}
+
+func newBoolean(v bool) *object.Boolean {
+	if v {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func newDepthError() *object.Error {
+	return &object.Error{Message: "再帰が深すぎます。", Kind: object.LimitError}
+}
+
+type VM struct {
+	// 関数呼び出しの深さの上限。末尾呼び出しは深さに数えない
+	MaxDepth int
+
+	rt    Runtime
+	depth int // 今の関数呼び出しの深さ。組み込み関数から呼ばれて入れ子になった実行も含める
+	// コンパイルした関数の本体。本体の節をキーにする
+	functions map[*ast.Node]*compiled
+}
+
+// コンパイルした命令列と、名前で探した変数の入れ物。入れ物は Names と Calls と同じ順に並ぶ
+type compiled struct {
+	code     *compiler.Bytecode
+	refs     []object.Ref
+	callRefs []object.Ref
+}
+
+func newCompiled(code *compiler.Bytecode) *compiled {
+	return &compiled{code: code, refs: make([]object.Ref, len(code.Names)), callRefs: make([]object.Ref, len(code.Calls))}
+}
+
+func New(rt Runtime) *VM {
+	return &VM{rt: rt, functions: map[*ast.Node]*compiled{}}
+}
+
+// プログラムをコンパイルして実行する
+func (vm *VM) Run(program *ast.Program, env *object.Environment) object.Object {
+	code, err := compiler.Compile(program)
+	if err != nil {
+		return newError("%s", err)
+	}
+	return vm.run(&frame{compiled: newCompiled(code), env: env})
+}
+
+// 関数を呼び出す。組み込み関数からスクリプトの関数を呼ぶときに使う
+func (vm *VM) Call(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return fn.Fn(args...)
+	case *object.Function:
+		env, err := vm.rt.Bind(fn, args, nil, nil)
+		if err != nil {
+			return err
+		}
+		code, err := vm.function(fn)
+		if err != nil {
+			return err
+		}
+		if vm.depth >= vm.MaxDepth {
+			return newDepthError()
+		}
+		vm.depth++
+		defer func() { vm.depth-- }()
+		return vm.run(&frame{compiled: code, env: env})
+	default:
+		return newError("%sは関数ではありません。", fn.Inspect())
+	}
+}
+
+// 関数の本体を初めて呼び出すときにコンパイルする
+func (vm *VM) function(fn *object.Function) (*compiled, object.Object) {
+	if c, ok := vm.functions[fn.Body]; ok {
+		return c, nil
+	}
+	code, err := compiler.CompileFunction(fn.Body)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	c := newCompiled(code)
+	vm.functions[fn.Body] = c
+	return c, nil
+}
+
+type frame struct {
+	*compiled
+	ip   int
+	env  *object.Environment
+	base int // 呼び出した時のスタックの高さ
+}
+
+type machine struct {
+	vm     *VM
+	stack  []object.Object
+	frames []*frame
+}
+
+func (m *machine) push(obj object.Object) {
+	m.stack = append(m.stack, obj)
+}
+
+func (m *machine) pop() object.Object {
+	obj := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return obj
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func (vm *VM) run(start *frame) object.Object {
+	m := &machine{vm: vm, frames: []*frame{start}}
+	rt := vm.rt
+	depth := vm.depth
+	defer func() { vm.depth = depth }()
+
+	for {
+		f := m.frames[len(m.frames)-1]
+		ins := f.code.Instructions
+		op := compiler.Opcode(ins[f.ip])
+		f.ip++
+
+		switch op {
+		case compiler.OpConstant:
+			m.push(f.code.Constants[compiler.ReadUint16(ins[f.ip:])])
+			f.ip += 2
+		case compiler.OpNull:
+			m.push(NULL)
+		case compiler.OpPop:
+			m.pop()
+		case compiler.OpDup2:
+			n := len(m.stack)
+			m.push(m.stack[n-2])
+			m.push(m.stack[n-1])
+
+		case compiler.OpBinary, compiler.OpArithmetic:
+			kind := ast.NodeKind(ins[f.ip])
+			f.ip++
+			right := m.pop()
+			left := m.pop()
+			res := m.binary(op, kind, left, right)
+			if isError(res) {
+				return res
+			}
+			m.push(res)
+
+		case compiler.OpNegate:
+			val := m.pop()
+			var res object.Object
+			if integer, ok := val.(*object.Integer); ok {
+				res = newInteger(-integer.Value)
+			} else {
+				res = rt.Infix(ast.SUB, newInteger(0), val)
+			}
+			if isError(res) {
+				return res
+			}
+			m.push(res)
+
+		case compiler.OpJump:
+			pos := int(compiler.ReadUint16(ins[f.ip:]))
+			// 後ろに飛ぶのは繰り返しの時だけ
+			if pos < f.ip {
+				if err := rt.Step(); err != nil {
+					return err
+				}
+			}
+			f.ip = pos
+		case compiler.OpJumpNotTruthy:
+			pos := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			condition := m.pop()
+			if b, ok := condition.(*object.Boolean); ok {
+				if !b.Value {
+					f.ip = pos
+				}
+			} else if !rt.IsTruthy(condition) {
+				f.ip = pos
+			}
+
+		case compiler.OpGetName, compiler.OpGetVar:
+			i := compiler.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			obj, ok := f.env.GetRef(f.code.Names[i], &f.refs[i])
+			if !ok && op == compiler.OpGetName {
+				// 変数がなければ組み込み関数を探す
+				obj, ok = rt.LookUp(f.code.Names[i], f.env)
+			}
+			if !ok {
+				return newError("変数が宣言されていません")
+			}
+			m.push(obj)
+		case compiler.OpSetName:
+			i := compiler.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			f.env.SetRef(f.code.Names[i], m.pop(), &f.refs[i])
+
+		case compiler.OpGetLocal:
+			depth := int(compiler.ReadUint16(ins[f.ip:]))
+			slot := int(compiler.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+			obj, ok := f.env.GetAt(depth, slot)
+			if !ok {
+				return newError("変数が宣言されていません")
+			}
+			m.push(obj)
+		case compiler.OpSetLocal:
+			depth := int(compiler.ReadUint16(ins[f.ip:]))
+			slot := int(compiler.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+			f.env.SetAt(depth, slot, m.pop())
+
+		case compiler.OpEnterBlock:
+			f.env = object.NewScopeEnvironment(f.env, int(compiler.ReadUint16(ins[f.ip:])))
+			f.ip += 2
+		case compiler.OpLeaveBlock:
+			f.env = f.env.Outer()
+
+		case compiler.OpFunction:
+			node := f.code.Functions[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+			fn := &object.Function{Name: node.Ident, Body: node.Body, Env: f.env}
+			fn.Params = append(fn.Params, node.Params...)
+			m.push(fn)
+
+		case compiler.OpResolveFunc:
+			i := compiler.ReadUint16(ins[f.ip:])
+			info := f.code.Calls[i]
+			f.ip += 2
+			var module object.Object
+			if info.HasModule {
+				module = m.pop()
+			} else if info.Binding == nil {
+				// 同じ名前の関数の変数があれば、評価器に聞かずにそれを呼ぶ
+				if fn, ok := f.env.GetRef(info.Name, &f.callRefs[i]); ok && fn.Type() == object.FUNCTION {
+					m.push(fn)
+					continue
+				}
+			}
+			fn := rt.Callee(info.Name, info.Binding, module, f.env)
+			if isError(fn) {
+				return fn
+			}
+			m.push(fn)
+		case compiler.OpCall, compiler.OpTailCall:
+			info := f.code.Calls[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+			if err := m.call(info, op == compiler.OpTailCall); err != nil {
+				return err
+			}
+
+		case compiler.OpReturnValue, compiler.OpReturn:
+			res := m.pop()
+			if len(m.frames) == 1 {
+				return res
+			}
+			m.stack = m.stack[:f.base]
+			m.frames = m.frames[:len(m.frames)-1]
+			vm.depth--
+			m.push(res)
+
+		case compiler.OpArray, compiler.OpTuple:
+			n := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, m.stack[len(m.stack)-n:])
+			m.stack = m.stack[:len(m.stack)-n]
+			var res object.Object
+			if op == compiler.OpArray {
+				res = rt.Allocate(&object.Array{Elements: elements})
+			} else {
+				res = rt.Allocate(&object.Tuple{Elements: elements})
+			}
+			if isError(res) {
+				return res
+			}
+			m.push(res)
+		case compiler.OpHash:
+			n := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			pairs := m.stack[len(m.stack)-n*2:]
+			hash := object.NewHash()
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(object.Hashable)
+				if !ok {
+					return newError("ハッシュのキーとして使えません。")
+				}
+				hash.Set(key, pairs[i+1])
+			}
+			m.stack = m.stack[:len(m.stack)-n*2]
+			if res := rt.Allocate(hash); isError(res) {
+				return res
+			}
+			m.push(hash)
+
+		case compiler.OpIndex:
+			index := m.pop()
+			left := m.pop()
+			res := m.index(left, index)
+			if isError(res) {
+				return res
+			}
+			m.push(res)
+		case compiler.OpSetIndex:
+			order := ins[f.ip]
+			f.ip++
+			var left, index, val object.Object
+			if order == 0 {
+				index, left, val = m.pop(), m.pop(), m.pop()
+			} else {
+				val, index, left = m.pop(), m.pop(), m.pop()
+			}
+			if res := rt.SetIndex(left, index, val); isError(res) {
+				return res
+			}
+		case compiler.OpDestructure:
+			targets := f.code.Targets[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+			values, err := rt.Destructure(targets, m.pop())
+			if err != nil {
+				return err
+			}
+			for i := len(values) - 1; i >= 0; i-- {
+				m.push(values[i])
+			}
+
+		case compiler.OpImport:
+			name := f.code.Names[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+			if res := rt.Import(name, f.env); isError(res) {
+				return res
+			}
+			m.push(NULL)
+		case compiler.OpMember:
+			name := f.code.Names[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+			res := rt.Member(m.pop(), name)
+			if isError(res) {
+				return res
+			}
+			m.push(res)
+
+		case compiler.OpError:
+			return f.code.Constants[compiler.ReadUint16(ins[f.ip:])]
+		default:
+			return newError("命令%dは定義されていません。", op)
+		}
+	}
+}
+
+// 数値同士の演算はここで行い、それ以外は評価器に任せる
+func (m *machine) binary(op compiler.Opcode, kind ast.NodeKind, left object.Object, right object.Object) object.Object {
+	l, ok := left.(*object.Integer)
+	r, ok2 := right.(*object.Integer)
+	if ok && ok2 {
+		switch kind {
+		case ast.ADD:
+			return newInteger(l.Value + r.Value)
+		case ast.SUB:
+			return newInteger(l.Value - r.Value)
+		case ast.MUL:
+			return newInteger(l.Value * r.Value)
+		case ast.DIV:
+			if r.Value == 0 {
+				return newError("0で割ることはできません。")
+			}
+			return newInteger(l.Value / r.Value)
+		case ast.EQ:
+			return newBoolean(l.Value == r.Value)
+		case ast.NOT_EQ:
+			return newBoolean(l.Value != r.Value)
+		case ast.GT:
+			return newBoolean(l.Value < r.Value)
+		case ast.GE:
+			return newBoolean(l.Value <= r.Value)
+		}
+	}
+
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok && (left.Type() == object.FLOAT || right.Type() == object.FLOAT) {
+			switch kind {
+			case ast.ADD:
+				return &object.Float{Value: l + r}
+			case ast.SUB:
+				return &object.Float{Value: l - r}
+			case ast.MUL:
+				return &object.Float{Value: l * r}
+			case ast.DIV:
+				if r == 0 {
+					return newError("0で割ることはできません。")
+				}
+				return &object.Float{Value: l / r}
+			case ast.EQ:
+				return newBoolean(l == r)
+			case ast.NOT_EQ:
+				return newBoolean(l != r)
+			case ast.GT:
+				return newBoolean(l < r)
+			case ast.GE:
+				return newBoolean(l <= r)
+			}
+		}
+	}
+
+	if op == compiler.OpArithmetic {
+		return m.vm.rt.Arithmetic(kind, left, right)
+	}
+	return m.vm.rt.Allocate(m.vm.rt.Infix(kind, left, right))
+}
+
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
+
+func (m *machine) index(left object.Object, index object.Object) object.Object {
+	if array, ok := left.(*object.Array); ok {
+		if i, ok := index.(*object.Integer); ok && 0 <= i.Value && i.Value < len(array.Elements) {
+			return array.Elements[i.Value]
+		}
+	}
+	return m.vm.rt.Index(left, index)
+}
+
+// スタックに積まれた関数と引数を取り出して呼び出す。スクリプトの関数なら新しいフレームを積む。
+// 末尾呼び出しなら今のフレームを置き換えるので、深さは変わらない
+func (m *machine) call(info *compiler.CallInfo, tail bool) object.Object {
+	n := len(info.Kinds)
+	values := m.stack[len(m.stack)-n:]
+	callee := m.stack[len(m.stack)-n-1]
+
+	args := make([]object.Object, 0, n)
+	var named map[string]object.Object
+	var particles []Particle
+	for i, kind := range info.Kinds {
+		switch kind {
+		case compiler.ArgPositional:
+			args = append(args, values[i])
+		case compiler.ArgNamed:
+			if named == nil {
+				named = map[string]object.Object{}
+			}
+			if _, ok := named[info.Labels[i]]; ok {
+				return newError("引数%sが重複して指定されています。", info.Labels[i])
+			}
+			named[info.Labels[i]] = values[i]
+		case compiler.ArgParticle:
+			particles = append(particles, Particle{Particle: info.Labels[i], Value: values[i]})
+		}
+	}
+	m.stack = m.stack[:len(m.stack)-n-1]
+
+	switch fn := callee.(type) {
+	case *object.Builtin:
+		if len(named) > 0 {
+			return newError("組み込み関数%sには名前付き引数を使えません。", fn.Name)
+		}
+		for _, v := range particles {
+			args = append(args, v.Value)
+		}
+		res := m.vm.rt.Allocate(fn.Fn(args...))
+		if isError(res) {
+			return res
+		}
+		m.push(res)
+	case *object.Function:
+		if err := m.vm.rt.Step(); err != nil {
+			return err
+		}
+		env, err := m.vm.rt.Bind(fn, args, named, particles)
+		if err != nil {
+			return err
+		}
+		code, err := m.vm.function(fn)
+		if err != nil {
+			return err
+		}
+		if tail {
+			f := m.frames[len(m.frames)-1]
+			m.stack = m.stack[:f.base]
+			f.compiled, f.ip, f.env = code, 0, env
+			return nil
+		}
+		if m.vm.depth >= m.vm.MaxDepth {
+			return newDepthError()
+		}
+		m.vm.depth++
+		m.frames = append(m.frames, &frame{compiled: code, env: env, base: len(m.stack)})
+	default:
+		return newError("関数が宣言されていません。")
+	}
+	return nil
+}